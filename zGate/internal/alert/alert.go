@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+const (
+	webhooksEnvVar = "ZGATE_ALERT_WEBHOOKS"
+	// sendTimeout bounds how long one webhook delivery may take
+	sendTimeout = 10 * time.Second
+)
+
+var (
+	webhooks     []string
+	webhooksOnce sync.Once
+	client       = &http.Client{Timeout: sendTimeout}
+)
+
+// getWebhooks returns the comma-separated webhook URLs configured in ZGATE_ALERT_WEBHOOKS
+func getWebhooks() []string {
+	webhooksOnce.Do(func() {
+		for _, url := range strings.Split(os.Getenv(webhooksEnvVar), ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				webhooks = append(webhooks, url)
+			}
+		}
+	})
+	return webhooks
+}
+
+// Event is a security-relevant occurrence operators must hear about right away
+type Event struct {
+	Kind    string         `json:"event"`
+	Text    string         `json:"text"` // one-line summary; chat webhooks such as Slack display it as is
+	Details map[string]any `json:"details"`
+	Time    time.Time      `json:"time"`
+}
+
+// Send posts event as JSON to every configured webhook without waiting for delivery.
+// Failed deliveries are logged, never retried.
+func Send(event Event) {
+	urls := getWebhooks()
+	if len(urls) == 0 {
+		utils.Logger.Warn("no alert webhooks configured", "event", event.Kind, "text", event.Text)
+		return
+	}
+	SendTo(urls, event)
+}
+
+// SendTo posts event as JSON to urls, such as a database's owner webhooks, the same way Send does
+func SendTo(urls []string, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		utils.Logger.Error("failed to encode alert", "event", event.Kind, "error", err)
+		return
+	}
+	for _, url := range urls {
+		go func() {
+			if err := post(url, body); err != nil {
+				utils.Logger.Error("failed to deliver alert", "event", event.Kind, "webhook", url, "error", err)
+			}
+		}()
+	}
+}
+
+// post delivers one alert body to url
+func post(url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
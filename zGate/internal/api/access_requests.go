@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// maxAccessRequestMinutes caps the duration of a just-in-time grant (7 days)
+const maxAccessRequestMinutes = 7 * 24 * 60
+
+// AccessRequestRequest represents a just-in-time access request payload
+type AccessRequestRequest struct {
+	DatabaseName  string `json:"database_name"`
+	Level         string `json:"level"`
+	Minutes       int    `json:"minutes"`
+	Justification string `json:"justification"`
+}
+
+// AccessDecisionRequest represents an approver's decision payload
+type AccessDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+// handleCreateAccessRequest handles POST /api/access-requests
+func (s *Server) handleCreateAccessRequest(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+
+	var req AccessRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	accessReq := &store.AccessRequest{
+		Username:      claims.Username,
+		Database:      req.DatabaseName,
+		Level:         req.Level,
+		Minutes:       req.Minutes,
+		Justification: strings.TrimSpace(req.Justification),
+	}
+	if err := s.validateAccessRequest(accessReq); err != nil {
+		writeAdminError(w, "create access request", err)
+		return
+	}
+
+	if err := s.store.CreateAccessRequest(accessReq); err != nil {
+		writeAdminError(w, "create access request", err)
+		return
+	}
+
+	utils.Logger.Info("access requested",
+		"request_id", accessReq.ID,
+		"username", claims.Username,
+		"database", accessReq.Database,
+		"level", accessReq.Level,
+		"minutes", accessReq.Minutes,
+		"justification", accessReq.Justification,
+	)
+
+	writeJSON(w, http.StatusCreated, accessReq)
+}
+
+// handleListMyAccessRequests handles GET /api/access-requests
+// It lists the caller's own requests, optionally filtered by status
+func (s *Server) handleListMyAccessRequests(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+
+	requests, err := s.store.ListAccessRequests(store.AccessRequestFilter{
+		Username: claims.Username,
+		Status:   r.URL.Query().Get("status"),
+	})
+	if err != nil {
+		writeAdminError(w, "list access requests", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// handleCancelAccessRequest handles DELETE /api/access-requests/{id}
+// Users may withdraw their own requests while they are pending
+func (s *Server) handleCancelAccessRequest(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.CancelAccessRequest(id, claims.Username); err != nil {
+		writeAdminError(w, "cancel access request", err)
+		return
+	}
+
+	utils.Logger.Info("access request cancelled", "request_id", id, "username", claims.Username)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Access request cancelled",
+	})
+}
+
+// handleListAccessRequests handles GET /api/admin/access-requests
+// Optional username, database and status query parameters filter the list
+func (s *Server) handleListAccessRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	requests, err := s.store.ListAccessRequests(store.AccessRequestFilter{
+		Username: query.Get("username"),
+		Database: query.Get("database"),
+		Status:   query.Get("status"),
+	})
+	if err != nil {
+		writeAdminError(w, "list access requests", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// handleApproveAccessRequest handles POST /api/admin/access-requests/{id}/approve
+// The grant starts now and lasts the requested minutes
+func (s *Server) handleApproveAccessRequest(w http.ResponseWriter, r *http.Request) {
+	s.decideAccessRequest(w, r, true, adminUsername(r), nil)
+}
+
+// handleDenyAccessRequest handles POST /api/admin/access-requests/{id}/deny
+func (s *Server) handleDenyAccessRequest(w http.ResponseWriter, r *http.Request) {
+	s.decideAccessRequest(w, r, false, adminUsername(r), nil)
+}
+
+// decideAccessRequest records an approver's decision on a pending request
+// mayDecide, when set, refuses approvers who may not decide this request
+func (s *Server) decideAccessRequest(w http.ResponseWriter, r *http.Request, approve bool, approver string, mayDecide func(*store.AccessRequest) error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AccessDecisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pending, err := s.store.GetAccessRequest(id)
+	if err != nil {
+		writeAdminError(w, "decide access request", err)
+		return
+	}
+	if mayDecide != nil {
+		if err := mayDecide(pending); err != nil {
+			writeAdminError(w, "decide access request", err)
+			return
+		}
+	}
+
+	// The database may have dropped the level since the request was made
+	if approve {
+		if err := s.validateAccessRequest(pending); err != nil {
+			writeAdminError(w, "approve access request", err)
+			return
+		}
+	}
+
+	decided, err := s.store.DecideAccessRequest(id, approve, approver, strings.TrimSpace(req.Note))
+	if err != nil {
+		writeAdminError(w, "decide access request", err)
+		return
+	}
+
+	utils.Logger.Info("access request decided",
+		"approver", approver,
+		"request_id", id,
+		"username", decided.Username,
+		"database", decided.Database,
+		"level", decided.Level,
+		"status", decided.Status,
+		"expires_at", decided.ExpiresAt,
+	)
+
+	if decided.Status == store.AccessApproved {
+		s.notifyOwners(decided.Database, "user:"+decided.Username, decided.Level, approver, map[string]any{
+			"source":        policy.RequestSource(decided.ID),
+			"justification": decided.Justification,
+			"expires_at":    decided.ExpiresAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, decided)
+}
+
+// handleRevokeAccessRequest handles POST /api/admin/access-requests/{id}/revoke
+// It ends an approved grant early; live sessions relying on it are re-evaluated
+func (s *Server) handleRevokeAccessRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.RevokeAccessRequest(id); err != nil {
+		writeAdminError(w, "revoke access request", err)
+		return
+	}
+
+	utils.Logger.Info("access request revoked", "admin", adminUsername(r), "request_id", id)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Access grant revoked",
+	})
+}
+
+// validateAccessRequest checks that a request names an offered level and a bounded duration
+func (s *Server) validateAccessRequest(req *store.AccessRequest) error {
+	if req.Justification == "" {
+		return invalidf("justification is required")
+	}
+	if len(req.Justification) > maxJustificationLength {
+		return invalidf("justification must be at most %d characters", maxJustificationLength)
+	}
+	if req.Minutes < 1 || req.Minutes > maxAccessRequestMinutes {
+		return invalidf("minutes must be between 1 and %d", maxAccessRequestMinutes)
+	}
+
+	db, err := s.store.GetDatabase(req.Database)
+	if err != nil {
+		if store.IsNotFound(err) {
+			return invalidf("unknown database %q", req.Database)
+		}
+		return err
+	}
+	if !slices.Contains(db.AvailablePermissions, req.Level) {
+		return invalidf("level %q is not available on database %q (available: %s)",
+			req.Level, req.Database, strings.Join(db.AvailablePermissions, ", "))
+	}
+	return nil
+}
@@ -1,72 +1,222 @@
-package api
-
-import (
-	"net/http"
-)
-
-// handleAdminLogin handles admin login requests
-func (s *Server) handleAdminLogin(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement admin login logic
-	// This will check if the provided credentials are admin credentials
-	// If yes, authenticate and allow access to admin pages
-	// If no, redirect to non-admin page
-	w.WriteHeader(http.StatusNotImplemented)
-	w.Write([]byte(`{"error": "Admin login not yet implemented"}`))
-}
-
-// TODO: Uncomment and implement these handlers as needed
-
-// func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement list users logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement create user logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement update user logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleRevokeUser(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement revoke user logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleListRoles(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement list roles logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleCreateRole(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement create role logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleUpdateRole(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement update role logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleRevokeRole(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement revoke role logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleCreateDatabase(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement create database logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleUpdateDatabase(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement update database logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
-
-// func (s *Server) handleRevokeDatabase(w http.ResponseWriter, r *http.Request) {
-// 	// TODO: Implement revoke database logic
-// 	w.WriteHeader(http.StatusNotImplemented)
-// }
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// AdminLoginRequest represents admin login request payload
+type AdminLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AdminLoginResponse represents admin login response payload
+type AdminLoginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // seconds
+	Username    string `json:"username"`
+}
+
+// handleAdminLogin handles POST /api/admin/login
+func (s *Server) handleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	var req AdminLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Logger.Error("invalid admin login request", "error", err)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info("admin login attempt", "username", req.Username)
+
+	valid, err := s.store.VerifyAdminPassword(req.Username, req.Password)
+	if err != nil || !valid {
+		utils.Logger.Warn("admin authentication failed", "username", req.Username, "error", err)
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, expiresAt, err := auth.GenerateAdminToken(req.Username)
+	if err != nil {
+		utils.Logger.Error("failed to generate admin access token", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.store.UpdateAdminLastLogin(req.Username); err != nil {
+		utils.Logger.Warn("failed to update admin last login", "username", req.Username, "error", err)
+	}
+
+	utils.Logger.Info("admin login successful", "username", req.Username, "ip", getClientIP(r))
+
+	writeJSON(w, http.StatusOK, AdminLoginResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		Username:    req.Username,
+	})
+}
+
+// adminUsername returns the admin performing the request (set by adminMiddleware)
+func adminUsername(r *http.Request) string {
+	if claims, ok := r.Context().Value("claims").(*auth.Claims); ok {
+		return claims.Username
+	}
+	return ""
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// validationError marks a client error in an admin request
+type validationError struct {
+	msg string
+}
+
+func (e *validationError) Error() string {
+	return e.msg
+}
+
+// invalidf builds a validationError
+func invalidf(format string, args ...any) error {
+	return &validationError{msg: fmt.Sprintf(format, args...)}
+}
+
+// writeAdminError maps an admin handler error to an HTTP response
+func writeAdminError(w http.ResponseWriter, action string, err error) {
+	var verr *validationError
+	switch {
+	case errors.As(err, &verr):
+		http.Error(w, verr.msg, http.StatusBadRequest)
+	case store.IsNotFound(err):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, errNotOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, store.ErrRequestState), errors.Is(err, store.ErrSoDViolation):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, store.ErrRoleCycle), errors.Is(err, store.ErrGroupCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		utils.Logger.Error("admin request failed", "action", action, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// validatePermissions checks that every permission references an existing database
+// and a level that database makes available
+func (s *Server) validatePermissions(perms []store.Permission) error {
+	seen := make(map[string]bool, len(perms))
+	for _, perm := range perms {
+		if perm.Database == "" {
+			return invalidf("permission database is required")
+		}
+		if seen[perm.Database] {
+			return invalidf("duplicate permission for database %q", perm.Database)
+		}
+		seen[perm.Database] = true
+
+		db, err := s.store.GetDatabase(perm.Database)
+		if err != nil {
+			if store.IsNotFound(err) {
+				return invalidf("unknown database %q", perm.Database)
+			}
+			return err
+		}
+
+		if err := validateValidity(perm.Validity); err != nil {
+			return invalidf("database %q: %v", perm.Database, err)
+		}
+
+		// Deny entries block the whole database, so they carry no level, objects or limits
+		if perm.Deny {
+			if perm.Level != "" || len(perm.Objects) > 0 || perm.Limits != nil || perm.ElevateTo != "" || perm.Conditions != nil {
+				return invalidf("deny entry for database %q must not set level, objects, limits, elevate_to or conditions", perm.Database)
+			}
+			continue
+		}
+
+		if !slices.Contains(db.AvailablePermissions, perm.Level) {
+			return invalidf("level %q is not available on database %q (available: %s)",
+				perm.Level, perm.Database, strings.Join(db.AvailablePermissions, ", "))
+		}
+
+		for _, obj := range perm.Objects {
+			if err := validateObjectGrant(perm, obj); err != nil {
+				return err
+			}
+		}
+
+		// Elevation must go up; levels rank by their position in available_permissions
+		if perm.ElevateTo != "" {
+			rank := db.Rank(perm.ElevateTo)
+			if rank < 0 {
+				return invalidf("elevate_to level %q is not available on database %q", perm.ElevateTo, perm.Database)
+			}
+			if rank <= db.Rank(perm.Level) {
+				return invalidf("elevate_to level %q must outrank level %q on database %q", perm.ElevateTo, perm.Level, perm.Database)
+			}
+		}
+
+		if err := protocol.ValidateLimits(db.Type, perm.Limits); err != nil {
+			return invalidf("database %q: %v", perm.Database, err)
+		}
+
+		if err := policy.ValidateConditions(perm.Conditions); err != nil {
+			return invalidf("database %q: conditions: %v", perm.Database, err)
+		}
+	}
+	return nil
+}
+
+// validateValidity checks that a validity window is not empty or already over
+func validateValidity(v store.Validity) error {
+	if v.ValidFrom != nil && v.ValidUntil != nil && !v.ValidUntil.After(*v.ValidFrom) {
+		return fmt.Errorf("valid_until must be after valid_from")
+	}
+	if v.ValidUntil != nil && !v.ValidUntil.After(time.Now()) {
+		return fmt.Errorf("valid_until must be in the future")
+	}
+	return nil
+}
+
+// validateObjectGrant checks an object grant's shape and that its privileges fit the permission level
+func validateObjectGrant(perm store.Permission, obj store.ObjectGrant) error {
+	if len(obj.Columns) > 0 && obj.Table == "" {
+		return invalidf("database %q: column grants need a table", perm.Database)
+	}
+	if len(obj.Privileges) == 0 {
+		return invalidf("database %q: object grant needs at least one privilege", perm.Database)
+	}
+
+	ceiling, capped := protocol.LevelPrivileges[perm.Level]
+	for _, priv := range obj.Privileges {
+		priv = strings.ToUpper(priv)
+		if !slices.Contains(protocol.ObjectPrivileges, priv) {
+			return invalidf("database %q: unsupported privilege %q (allowed: %s)",
+				perm.Database, priv, strings.Join(protocol.ObjectPrivileges, ", "))
+		}
+		if capped && !slices.Contains(ceiling, priv) {
+			return invalidf("database %q: privilege %s exceeds level %q", perm.Database, priv, perm.Level)
+		}
+		if priv == "DELETE" && len(obj.Columns) > 0 {
+			return invalidf("database %q: DELETE cannot be granted on columns", perm.Database)
+		}
+	}
+	return nil
+}
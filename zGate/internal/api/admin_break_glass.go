@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// BreakGlassReviewRequest represents an admin's sign-off on a break-glass session
+type BreakGlassReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// handleListBreakGlassSessions handles GET /api/admin/break-glass
+// status=unreviewed narrows the list to sessions still awaiting review
+func (s *Server) handleListBreakGlassSessions(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "unreviewed" {
+		http.Error(w, "status must be unreviewed or empty", http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := s.store.ListBreakGlassSessions(status == "unreviewed")
+	if err != nil {
+		writeAdminError(w, "list break-glass sessions", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// handleReviewBreakGlassSession handles POST /api/admin/break-glass/{id}/review
+// It records that an admin reviewed a break-glass session once it is over
+func (s *Server) handleReviewBreakGlassSession(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid break-glass session ID", http.StatusBadRequest)
+		return
+	}
+
+	var req BreakGlassReviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	reviewed, err := s.store.ReviewBreakGlassSession(id, adminUsername(r), strings.TrimSpace(req.Note))
+	if err != nil {
+		writeAdminError(w, "review break-glass session", err)
+		return
+	}
+
+	utils.Logger.Info("break-glass session reviewed",
+		"admin", adminUsername(r),
+		"break_glass_id", id,
+		"username", reviewed.Username,
+		"database", reviewed.Database,
+		"incident", reviewed.Incident,
+	)
+
+	writeJSON(w, http.StatusOK, reviewed)
+}
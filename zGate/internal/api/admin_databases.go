@@ -0,0 +1,442 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// provisioningTestTimeout bounds a provisioning dry run so it finishes within the server write timeout
+const provisioningTestTimeout = 8 * time.Second
+
+// DatabaseRequest represents a database definition in admin create/update requests
+type DatabaseRequest struct {
+	Name                 string   `json:"name"`
+	Type                 string   `json:"type"`
+	Description          string   `json:"description"`
+	BackendAddr          string   `json:"backend_addr"`
+	AdminUsername        string   `json:"admin_username"`
+	AdminPassword        string   `json:"admin_password"`
+	AvailablePermissions []string `json:"available_permissions"`
+	TargetDatabase       string   `json:"target_database"`
+	TargetSchema         string   `json:"target_schema"`
+	UsernameTemplate     string   `json:"username_template"`
+	MaxSessionMinutes    int      `json:"max_session_minutes"`
+	// Tags label the database for policies, e.g. {"env":"prod"}
+	Tags map[string]string `json:"tags"`
+	// Owners approve access requests for the database and audit its connections
+	Owners store.DatabaseOwners `json:"owners"`
+	// IdentityTrigger lets zGate install a server-wide logon trigger on an MSSQL backend
+	// that copies each temp login's zGate identity into its session context
+	IdentityTrigger bool `json:"identity_trigger"`
+}
+
+// toDatabase converts the request into a store definition named name
+func (req *DatabaseRequest) toDatabase(name string) *store.Database {
+	return &store.Database{
+		Name:                 name,
+		Type:                 req.Type,
+		Description:          req.Description,
+		BackendAddr:          req.BackendAddr,
+		AdminUsername:        req.AdminUsername,
+		AdminPassword:        req.AdminPassword,
+		AvailablePermissions: req.AvailablePermissions,
+		TargetDatabase:       req.TargetDatabase,
+		TargetSchema:         req.TargetSchema,
+		UsernameTemplate:     req.UsernameTemplate,
+		MaxSessionMinutes:    req.MaxSessionMinutes,
+		Tags:                 req.Tags,
+		Owners:               req.Owners,
+		IdentityTrigger:      req.IdentityTrigger,
+	}
+}
+
+// DatabaseResponse represents a database definition returned to admins
+// The backend admin password is never included
+type DatabaseResponse struct {
+	Name                 string                  `json:"name"`
+	Type                 string                  `json:"type"`
+	Description          string                  `json:"description"`
+	BackendAddr          string                  `json:"backend_addr"`
+	AdminUsername        string                  `json:"admin_username"`
+	AvailablePermissions []string                `json:"available_permissions"`
+	TargetDatabase       string                  `json:"target_database"`
+	TargetSchema         string                  `json:"target_schema"`
+	UsernameTemplate     string                  `json:"username_template"`
+	MaxSessionMinutes    int                     `json:"max_session_minutes"`
+	Tags                 map[string]string       `json:"tags,omitempty"`
+	Owners               store.DatabaseOwners    `json:"owners"`
+	IdentityTrigger      bool                    `json:"identity_trigger"`
+	Levels               []store.PermissionLevel `json:"levels"`
+	CreatedAt            time.Time               `json:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at"`
+}
+
+func newDatabaseResponse(db *store.Database) DatabaseResponse {
+	return DatabaseResponse{
+		Name:                 db.Name,
+		Type:                 db.Type,
+		Description:          db.Description,
+		BackendAddr:          db.BackendAddr,
+		AdminUsername:        db.AdminUsername,
+		AvailablePermissions: db.AvailablePermissions,
+		TargetDatabase:       db.TargetDatabase,
+		TargetSchema:         db.TargetSchema,
+		UsernameTemplate:     db.UsernameTemplate,
+		MaxSessionMinutes:    db.MaxSessionMinutes,
+		Tags:                 db.Tags,
+		Owners:               nonNilOwners(db.Owners),
+		IdentityTrigger:      db.IdentityTrigger,
+		Levels:               nonNilLevels(db.Levels),
+		CreatedAt:            db.CreatedAt,
+		UpdatedAt:            db.UpdatedAt,
+	}
+}
+
+// handleAdminListDatabases handles GET /api/admin/databases
+func (s *Server) handleAdminListDatabases(w http.ResponseWriter, r *http.Request) {
+	databases, err := s.store.ListDatabases()
+	if err != nil {
+		writeAdminError(w, "list databases", err)
+		return
+	}
+
+	resp := make([]DatabaseResponse, 0, len(databases))
+	for i := range databases {
+		resp = append(resp, newDatabaseResponse(&databases[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAdminGetDatabase handles GET /api/admin/databases/{name}
+func (s *Server) handleAdminGetDatabase(w http.ResponseWriter, r *http.Request) {
+	db, err := s.store.GetDatabase(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "get database", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newDatabaseResponse(db))
+}
+
+// handleCreateDatabase handles POST /api/admin/databases
+func (s *Server) handleCreateDatabase(w http.ResponseWriter, r *http.Request) {
+	var req DatabaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetDatabase(req.Name); err == nil {
+		http.Error(w, "database already exists", http.StatusConflict)
+		return
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "create database", err)
+		return
+	}
+
+	if req.AdminPassword == "" {
+		http.Error(w, "admin_password is required", http.StatusBadRequest)
+		return
+	}
+
+	db := req.toDatabase(req.Name)
+	if err := validateDatabase(db); err != nil {
+		writeAdminError(w, "create database", err)
+		return
+	}
+	if err := s.validateOwners(db.Owners); err != nil {
+		writeAdminError(w, "create database", err)
+		return
+	}
+
+	if err := s.store.SaveDatabase(db); err != nil {
+		writeAdminError(w, "create database", err)
+		return
+	}
+
+	utils.Logger.Info("database created", "admin", adminUsername(r), "database", db.Name, "type", db.Type)
+
+	saved, err := s.store.GetDatabase(db.Name)
+	if err != nil {
+		writeAdminError(w, "create database", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newDatabaseResponse(saved))
+}
+
+// handleUpdateDatabase handles PUT /api/admin/databases/{name}
+// An empty admin_password keeps the stored one
+func (s *Server) handleUpdateDatabase(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req DatabaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		http.Error(w, "database name cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.store.GetDatabase(name)
+	if err != nil {
+		writeAdminError(w, "update database", err)
+		return
+	}
+
+	db := req.toDatabase(name)
+	db.Levels = existing.Levels
+	if db.AdminPassword == "" {
+		db.AdminPassword = existing.AdminPassword
+	}
+	if err := validateDatabase(db); err != nil {
+		writeAdminError(w, "update database", err)
+		return
+	}
+	if err := s.validateOwners(db.Owners); err != nil {
+		writeAdminError(w, "update database", err)
+		return
+	}
+
+	// Refuse to drop levels that roles or users still reference
+	for _, level := range existing.AvailablePermissions {
+		if slices.Contains(db.AvailablePermissions, level) {
+			continue
+		}
+		if err := s.checkLevelUnused(name, level); err != nil {
+			writeAdminError(w, "update database", err)
+			return
+		}
+	}
+
+	if err := s.store.SaveDatabase(db); err != nil {
+		writeAdminError(w, "update database", err)
+		return
+	}
+
+	utils.Logger.Info("database updated", "admin", adminUsername(r), "database", name)
+
+	saved, err := s.store.GetDatabase(name)
+	if err != nil {
+		writeAdminError(w, "update database", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newDatabaseResponse(saved))
+}
+
+// handleRevokeDatabase handles DELETE /api/admin/databases/{name}
+func (s *Server) handleRevokeDatabase(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := s.store.DeleteDatabase(name); err != nil {
+		writeAdminError(w, "delete database", err)
+		return
+	}
+
+	utils.Logger.Info("database deleted", "admin", adminUsername(r), "database", name)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Database deleted successfully",
+	})
+}
+
+// handleTestDatabase handles POST /api/admin/databases/{name}/test
+// It runs a provisioning dry run against a stored database definition
+func (s *Server) handleTestDatabase(w http.ResponseWriter, r *http.Request) {
+	db, err := s.store.GetDatabase(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "test database", err)
+		return
+	}
+
+	s.runProvisioningTest(w, r, db)
+}
+
+// handleTestDatabaseDefinition handles POST /api/admin/databases/test
+// It runs a provisioning dry run against an unsaved database definition
+func (s *Server) handleTestDatabaseDefinition(w http.ResponseWriter, r *http.Request) {
+	var req DatabaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	db := req.toDatabase(req.Name)
+
+	// Allow re-testing a stored definition without resending its password
+	if existing, err := s.store.GetDatabase(db.Name); err == nil {
+		db.Levels = existing.Levels
+		if db.AdminPassword == "" {
+			db.AdminPassword = existing.AdminPassword
+		}
+	}
+
+	if err := validateDatabase(db); err != nil {
+		writeAdminError(w, "test database", err)
+		return
+	}
+
+	s.runProvisioningTest(w, r, db)
+}
+
+func (s *Server) runProvisioningTest(w http.ResponseWriter, r *http.Request, db *store.Database) {
+	utils.Logger.Info("provisioning dry run requested", "admin", adminUsername(r), "database", db.Name)
+
+	ctx, cancel := context.WithTimeout(r.Context(), provisioningTestTimeout)
+	defer cancel()
+
+	report := protocol.TestProvisioning(ctx, *db)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// validateOwners checks that owners name existing users and groups, each once, and http(s) webhooks
+func (s *Server) validateOwners(owners store.DatabaseOwners) error {
+	checks := []struct {
+		kind   string
+		values []string
+		lookup func(string) error
+	}{
+		{"owner user", owners.Users, func(name string) error { _, err := s.store.GetUser(name); return err }},
+		{"owner group", owners.Groups, func(name string) error { _, err := s.store.GetGroup(name); return err }},
+	}
+	for _, check := range checks {
+		seen := make(map[string]bool, len(check.values))
+		for _, value := range check.values {
+			if seen[value] {
+				return invalidf("duplicate %s %q", check.kind, value)
+			}
+			seen[value] = true
+
+			if err := check.lookup(value); err != nil {
+				if store.IsNotFound(err) {
+					return invalidf("unknown %s %q", check.kind, value)
+				}
+				return err
+			}
+		}
+	}
+
+	for _, webhook := range owners.Webhooks {
+		u, err := url.Parse(webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalidf("owner webhook %q must be an http or https URL", webhook)
+		}
+	}
+	return nil
+}
+
+func nonNilOwners(owners store.DatabaseOwners) store.DatabaseOwners {
+	owners.Users = nonNilStrings(owners.Users)
+	owners.Groups = nonNilStrings(owners.Groups)
+	return owners
+}
+
+// validateDatabase checks a database definition before it is saved
+func validateDatabase(db *store.Database) error {
+	if db.Name == "" {
+		return invalidf("name is required")
+	}
+	if !protocol.IsSupportedType(db.Type) {
+		return invalidf("unsupported database type %q", db.Type)
+	}
+	if _, _, err := net.SplitHostPort(db.BackendAddr); err != nil {
+		return invalidf("backend_addr must be host:port")
+	}
+	if db.AdminUsername == "" {
+		return invalidf("admin_username is required")
+	}
+	// Temp MySQL users are only created with a lock event, which lives in the target database
+	if db.Type == "mysql" && db.TargetDatabase == "" {
+		return invalidf("mysql databases need a target_database to hold temp users' account lock events")
+	}
+	if db.TargetSchema != "" {
+		switch db.Type {
+		case "mysql":
+			if db.TargetSchema != db.TargetDatabase {
+				return invalidf("mysql has no schemas separate from databases; set target_database only")
+			}
+		case "mssql":
+			if db.TargetDatabase == "" {
+				return invalidf("target_schema requires target_database")
+			}
+		}
+	}
+	if db.UsernameTemplate != "" {
+		if err := protocol.ValidateUsernameTemplate(db.Type, db.UsernameTemplate); err != nil {
+			return invalidf("%v", err)
+		}
+	}
+	if db.IdentityTrigger && db.Type != "mssql" {
+		return invalidf("identity_trigger is only supported on mssql")
+	}
+	if db.MaxSessionMinutes < 0 {
+		return invalidf("max_session_minutes must not be negative")
+	}
+	if len(db.AvailablePermissions) == 0 {
+		return invalidf("available_permissions must not be empty")
+	}
+	// The order of available_permissions ranks levels when grants are merged and
+	// picks the break-glass level, so the built-in levels must stay least to most
+	// privileged and no custom level may outrank admin
+	builtinRank := -1
+	for i, level := range db.AvailablePermissions {
+		if slices.Contains(db.AvailablePermissions[:i], level) {
+			return invalidf("available_permissions lists %q twice", level)
+		}
+		if _, custom := db.Level(level); custom {
+			if builtinRank == len(protocol.DefaultPermissionLevels)-1 {
+				return invalidf("custom level %q must not outrank %s", level, protocol.DefaultPermissionLevels[builtinRank])
+			}
+			continue
+		}
+		rank := slices.Index(protocol.DefaultPermissionLevels, level)
+		if rank < 0 {
+			return invalidf("unknown permission level %q", level)
+		}
+		if rank <= builtinRank {
+			return invalidf("available_permissions must list %s from least to most privileged",
+				strings.Join(protocol.DefaultPermissionLevels, ", "))
+		}
+		builtinRank = rank
+	}
+	return nil
+}
+
+// checkLevelUnused returns a validation error if any role or user grants level on database or may elevate to it
+func (s *Server) checkLevelUnused(database, level string) error {
+	roles, err := s.store.ListRoles()
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		for _, perm := range role.Permissions {
+			if perm.Database == database && (perm.Level == level || perm.ElevateTo == level) {
+				return invalidf("level %q is still granted by role %q", level, role.Name)
+			}
+		}
+	}
+
+	users, err := s.store.ListUsers()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		for _, perm := range user.CustomPermissions {
+			if perm.Database == database && (perm.Level == level || perm.ElevateTo == level) {
+				return invalidf("level %q is still granted to user %q", level, user.Username)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+func TestValidateDatabaseLevelOrder(t *testing.T) {
+	tests := []struct {
+		levels []string
+		ok     bool
+	}{
+		{[]string{"read", "write", "admin"}, true},
+		{[]string{"read"}, true},
+		{[]string{"read", "reporting", "write", "admin"}, true},
+		{[]string{"reporting", "read", "admin"}, true},
+		{[]string{"read", "write", "reporting"}, true},
+		{[]string{"write", "read", "admin"}, false},
+		{[]string{"admin", "read"}, false},
+		{[]string{"read", "write", "admin", "reporting"}, false},
+		{[]string{"read", "read", "admin"}, false},
+		{[]string{"read", "reporting", "reporting", "admin"}, false},
+		{[]string{"read", "owner"}, false},
+		{[]string{}, false},
+	}
+	for _, tt := range tests {
+		db := &store.Database{
+			Name:                 "orders",
+			Type:                 "mysql",
+			BackendAddr:          "db.internal:3306",
+			AdminUsername:        "zgate",
+			TargetDatabase:       "orders",
+			AvailablePermissions: tt.levels,
+			Levels:               []store.PermissionLevel{{Database: "orders", Name: "reporting"}},
+		}
+		if err := validateDatabase(db); (err == nil) != tt.ok {
+			t.Errorf("validateDatabase with available_permissions %q = %v; want ok %v", tt.levels, err, tt.ok)
+		}
+	}
+}
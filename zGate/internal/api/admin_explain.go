@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// ExplainResponse is the decision trace for one database plus the statements a
+// connection would run to provision the resulting access
+// TempUsername is an example only; every session draws a fresh random suffix
+type ExplainResponse struct {
+	*policy.Explanation
+	TempUsername    string   `json:"temp_username,omitempty"`
+	Statements      []string `json:"statements,omitempty"`
+	StatementsError string   `json:"statements_error,omitempty"`
+}
+
+// SimulationRequest describes unsaved changes to evaluate for a user
+// Roles and CustomPermissions replace the user's current ones when present;
+// RoleDefinitions replace or add roles by name for the simulation only
+type SimulationRequest struct {
+	Database          string                    `json:"database"`
+	IP                string                    `json:"ip"`
+	Time              *time.Time                `json:"time"`
+	Roles             []string                  `json:"roles"`
+	RoleValidity      map[string]store.Validity `json:"role_validity"`
+	CustomPermissions []store.Permission        `json:"custom_permissions"`
+	RoleDefinitions   []RoleRequest             `json:"role_definitions"`
+}
+
+// SimulationResult compares a user's access to one database before and after the changes
+type SimulationResult struct {
+	Database string           `json:"database"`
+	Changed  bool             `json:"changed"`
+	Before   *ExplainResponse `json:"before"`
+	After    *ExplainResponse `json:"after"`
+}
+
+// handleExplainUser handles GET /api/admin/users/{username}/explain
+// It traces how a user's access to each database is decided: the groups and roles
+// considered, every grant and condition, the stored policies and the GRANT statements
+// a connection would run. The optional database query parameter limits the result to
+// one database; ip and time (RFC 3339) set the request context, defaulting to no
+// address and now
+func (s *Server) handleExplainUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	query := r.URL.Query()
+
+	rc, err := explainContext(query.Get("ip"), query.Get("time"))
+	if err != nil {
+		writeAdminError(w, "explain user", err)
+		return
+	}
+
+	explanations, err := s.policyEngine.Explain(username, query.Get("database"), rc, nil)
+	if err != nil {
+		writeAdminError(w, "explain user", err)
+		return
+	}
+
+	responses, err := s.explainResponses(username, explanations)
+	if err != nil {
+		writeAdminError(w, "explain user", err)
+		return
+	}
+	if query.Get("database") != "" {
+		writeJSON(w, http.StatusOK, responses[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, responses)
+}
+
+// handleSimulateUser handles POST /api/admin/users/{username}/simulate
+// It explains a user's access as it would be with the requested role and permission
+// changes, next to the current access, without saving anything
+func (s *Server) handleSimulateUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	var req SimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	rc, err := explainContext(req.IP, "")
+	if err != nil {
+		writeAdminError(w, "simulate user", err)
+		return
+	}
+	if req.Time != nil {
+		rc.Time = *req.Time
+	}
+
+	changes, err := s.simulationChanges(&req)
+	if err != nil {
+		writeAdminError(w, "simulate user", err)
+		return
+	}
+
+	before, err := s.policyEngine.Explain(username, req.Database, rc, nil)
+	if err != nil {
+		writeAdminError(w, "simulate user", err)
+		return
+	}
+	after, err := s.policyEngine.Explain(username, req.Database, rc, changes)
+	if err != nil {
+		writeAdminError(w, "simulate user", err)
+		return
+	}
+
+	beforeResponses, err := s.explainResponses(username, before)
+	if err != nil {
+		writeAdminError(w, "simulate user", err)
+		return
+	}
+	afterResponses, err := s.explainResponses(username, after)
+	if err != nil {
+		writeAdminError(w, "simulate user", err)
+		return
+	}
+
+	results := make([]SimulationResult, 0, len(afterResponses))
+	for i, resp := range afterResponses {
+		if i >= len(beforeResponses) || beforeResponses[i].Database != resp.Database {
+			continue // database added or removed between the two passes
+		}
+		results = append(results, SimulationResult{
+			Database: resp.Database,
+			Changed:  !policy.SameAccess(beforeResponses[i].Resolution, resp.Resolution),
+			Before:   beforeResponses[i],
+			After:    resp,
+		})
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// explainContext builds the request context for an explanation from optional
+// ip and RFC 3339 time values
+func explainContext(ip, at string) (policy.RequestContext, error) {
+	rc := policy.RequestContext{Time: time.Now()}
+	if ip != "" {
+		if rc.ClientIP = net.ParseIP(ip); rc.ClientIP == nil {
+			return rc, invalidf("invalid ip %q", ip)
+		}
+	}
+	if at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return rc, invalidf("time must be RFC 3339, e.g. 2025-01-02T15:04:05Z")
+		}
+		rc.Time = t
+	}
+	return rc, nil
+}
+
+// explainResponses adds the planned GRANT statements to each explanation that allows access
+func (s *Server) explainResponses(username string, explanations []*policy.Explanation) ([]*ExplainResponse, error) {
+	responses := make([]*ExplainResponse, 0, len(explanations))
+	for _, exp := range explanations {
+		resp := &ExplainResponse{Explanation: exp}
+		responses = append(responses, resp)
+		if !exp.Allowed {
+			continue
+		}
+
+		db, err := s.store.GetDatabase(exp.Database)
+		if err != nil {
+			return nil, err
+		}
+		resp.TempUsername, err = protocol.GenerateTempUsername(*db, username)
+		if err != nil {
+			resp.StatementsError = err.Error()
+			continue
+		}
+		resp.Statements, err = protocol.PlanGrants(*db, resp.TempUsername, []store.Permission{*exp.Permission()})
+		if err != nil {
+			resp.StatementsError = err.Error()
+		}
+	}
+	return responses, nil
+}
+
+// simulationChanges validates a simulation request the way saving the same
+// user and roles would, and converts it to policy changes
+func (s *Server) simulationChanges(req *SimulationRequest) (*policy.Changes, error) {
+	changes := &policy.Changes{
+		Roles:             req.Roles,
+		RoleValidity:      req.RoleValidity,
+		CustomPermissions: req.CustomPermissions,
+		RoleDefinitions:   make(map[string]*store.Role, len(req.RoleDefinitions)),
+	}
+
+	for _, def := range req.RoleDefinitions {
+		if def.Name == "" {
+			return nil, invalidf("role definition name is required")
+		}
+		if changes.RoleDefinitions[def.Name] != nil {
+			return nil, invalidf("duplicate role definition %q", def.Name)
+		}
+		if err := s.validatePermissions(def.Permissions); err != nil {
+			return nil, err
+		}
+		changes.RoleDefinitions[def.Name] = &store.Role{
+			Name:        def.Name,
+			Description: def.Description,
+			Parents:     def.Parents,
+			Permissions: def.Permissions,
+		}
+	}
+
+	roleExists := func(name string) (bool, error) {
+		if changes.RoleDefinitions[name] != nil {
+			return true, nil
+		}
+		if _, err := s.store.GetRole(name); err != nil {
+			if store.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	for _, def := range changes.RoleDefinitions {
+		seen := make(map[string]bool, len(def.Parents))
+		for _, parent := range def.Parents {
+			if seen[parent] {
+				return nil, invalidf("role %q: duplicate parent role %q", def.Name, parent)
+			}
+			seen[parent] = true
+			if ok, err := roleExists(parent); err != nil {
+				return nil, err
+			} else if !ok {
+				return nil, invalidf("role %q: unknown parent role %q", def.Name, parent)
+			}
+		}
+	}
+	if err := changes.CheckRoleCycles(s.store); err != nil {
+		return nil, err
+	}
+
+	if req.Roles == nil && req.RoleValidity != nil {
+		return nil, invalidf("role_validity needs roles")
+	}
+	seen := make(map[string]bool, len(req.Roles))
+	for _, role := range req.Roles {
+		if seen[role] {
+			return nil, invalidf("duplicate role %q", role)
+		}
+		seen[role] = true
+		if ok, err := roleExists(role); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, invalidf("unknown role %q", role)
+		}
+	}
+	for role, window := range req.RoleValidity {
+		if !seen[role] {
+			return nil, invalidf("role_validity names role %q, which is not assigned", role)
+		}
+		if err := validateValidity(window); err != nil {
+			return nil, invalidf("role %q: %v", role, err)
+		}
+	}
+
+	if err := s.validatePermissions(req.CustomPermissions); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// GroupRequest represents a group in admin create/update requests
+// Parents name the groups this group is nested in; its members belong to those too
+type GroupRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Parents     []string `json:"parents"`
+	Members     []string `json:"members"`
+	Roles       []string `json:"roles"`
+}
+
+func newGroupResponse(group *store.Group) *store.Group {
+	group.Parents = nonNilStrings(group.Parents)
+	group.Members = nonNilStrings(group.Members)
+	group.Roles = nonNilStrings(group.Roles)
+	return group
+}
+
+// handleListGroups handles GET /api/admin/groups
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.store.ListGroups()
+	if err != nil {
+		writeAdminError(w, "list groups", err)
+		return
+	}
+
+	resp := make([]*store.Group, 0, len(groups))
+	for i := range groups {
+		resp = append(resp, newGroupResponse(&groups[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetGroup handles GET /api/admin/groups/{name}
+func (s *Server) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := s.store.GetGroup(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "get group", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newGroupResponse(group))
+}
+
+// handleCreateGroup handles POST /api/admin/groups
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetGroup(req.Name); err == nil {
+		http.Error(w, "group already exists", http.StatusConflict)
+		return
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "create group", err)
+		return
+	}
+
+	s.saveGroup(w, r, &store.Group{
+		Name:        req.Name,
+		Description: req.Description,
+		Parents:     req.Parents,
+		Members:     req.Members,
+		Roles:       req.Roles,
+	}, http.StatusCreated)
+}
+
+// handleUpdateGroup handles PUT /api/admin/groups/{name}
+// Parents, members and roles in the request replace the group's current ones
+func (s *Server) handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		http.Error(w, "group name cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetGroup(name); err != nil {
+		writeAdminError(w, "update group", err)
+		return
+	}
+
+	s.saveGroup(w, r, &store.Group{
+		Name:        name,
+		Description: req.Description,
+		Parents:     req.Parents,
+		Members:     req.Members,
+		Roles:       req.Roles,
+	}, http.StatusOK)
+}
+
+// handleAddGroupMember handles PUT /api/admin/groups/{name}/members/{username}
+func (s *Server) handleAddGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group, err := s.store.GetGroup(vars["name"])
+	if err != nil {
+		writeAdminError(w, "add group member", err)
+		return
+	}
+	if !slices.Contains(group.Members, vars["username"]) {
+		group.Members = append(group.Members, vars["username"])
+	}
+	s.saveGroup(w, r, group, http.StatusOK)
+}
+
+// handleRemoveGroupMember handles DELETE /api/admin/groups/{name}/members/{username}
+func (s *Server) handleRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group, err := s.store.GetGroup(vars["name"])
+	if err != nil {
+		writeAdminError(w, "remove group member", err)
+		return
+	}
+	if !slices.Contains(group.Members, vars["username"]) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	group.Members = slices.DeleteFunc(group.Members, func(member string) bool {
+		return member == vars["username"]
+	})
+	s.saveGroup(w, r, group, http.StatusOK)
+}
+
+// handleRevokeGroup handles DELETE /api/admin/groups/{name}
+func (s *Server) handleRevokeGroup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := s.store.DeleteGroup(name); err != nil {
+		writeAdminError(w, "delete group", err)
+		return
+	}
+
+	utils.Logger.Info("group deleted", "admin", adminUsername(r), "group", name)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Group deleted successfully",
+	})
+}
+
+// saveGroup validates and persists a group, then writes it back to the client
+// The store rejects parents that would nest the group in itself
+func (s *Server) saveGroup(w http.ResponseWriter, r *http.Request, group *store.Group, status int) {
+	if err := s.validateGroup(group); err != nil {
+		writeAdminError(w, "save group", err)
+		return
+	}
+
+	if err := s.store.SaveGroup(group); err != nil {
+		writeAdminError(w, "save group", err)
+		return
+	}
+
+	utils.Logger.Info("group saved", "admin", adminUsername(r), "group", group.Name,
+		"parents", group.Parents, "members", len(group.Members), "roles", group.Roles)
+
+	saved, err := s.store.GetGroup(group.Name)
+	if err != nil {
+		writeAdminError(w, "save group", err)
+		return
+	}
+	writeJSON(w, status, newGroupResponse(saved))
+}
+
+// validateGroup checks that a group's parents, members and roles exist and are listed once
+func (s *Server) validateGroup(group *store.Group) error {
+	checks := []struct {
+		kind   string
+		values []string
+		lookup func(string) error
+	}{
+		{"parent group", group.Parents, func(name string) error { _, err := s.store.GetGroup(name); return err }},
+		{"member", group.Members, func(name string) error { _, err := s.store.GetUser(name); return err }},
+		{"role", group.Roles, func(name string) error { _, err := s.store.GetRole(name); return err }},
+	}
+	for _, check := range checks {
+		seen := make(map[string]bool, len(check.values))
+		for _, value := range check.values {
+			if seen[value] {
+				return invalidf("duplicate %s %q", check.kind, value)
+			}
+			seen[value] = true
+
+			if err := check.lookup(value); err != nil {
+				if store.IsNotFound(err) {
+					return invalidf("unknown %s %q", check.kind, value)
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// levelNamePattern restricts custom level names to simple identifiers
+var levelNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// PermissionLevelRequest represents a custom permission level in admin requests
+type PermissionLevelRequest struct {
+	Description string                `json:"description"`
+	Statements  []string              `json:"statements"`
+	NativeRoles []string              `json:"native_roles"`
+	Objects     []store.ObjectGrant   `json:"objects"`
+	Limits      *store.ResourceLimits `json:"limits"`
+	// Above names the level this one ranks directly above in available_permissions.
+	// It is required for a new level; for an existing one it moves the level.
+	Above string `json:"above"`
+}
+
+// handleListPermissionLevels handles GET /api/admin/databases/{name}/levels
+func (s *Server) handleListPermissionLevels(w http.ResponseWriter, r *http.Request) {
+	db, err := s.store.GetDatabase(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "list permission levels", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNilLevels(db.Levels))
+}
+
+// handleSavePermissionLevel handles PUT /api/admin/databases/{name}/levels/{level}
+// Saving a level also makes it available on the database, ranked directly above the level named by above
+func (s *Server) handleSavePermissionLevel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req PermissionLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	db, err := s.store.GetDatabase(vars["name"])
+	if err != nil {
+		writeAdminError(w, "save permission level", err)
+		return
+	}
+
+	level := &store.PermissionLevel{
+		Database:    db.Name,
+		Name:        vars["level"],
+		Description: req.Description,
+		Statements:  req.Statements,
+		NativeRoles: req.NativeRoles,
+		Objects:     req.Objects,
+		Limits:      req.Limits,
+	}
+	if err := validatePermissionLevel(db.Type, level); err != nil {
+		writeAdminError(w, "save permission level", err)
+		return
+	}
+
+	ranked, err := rankLevel(db, level, req.Above)
+	if err != nil {
+		writeAdminError(w, "save permission level", err)
+		return
+	}
+
+	if err := s.store.SavePermissionLevel(level, ranked); err != nil {
+		writeAdminError(w, "save permission level", err)
+		return
+	}
+
+	utils.Logger.Info("permission level saved", "admin", adminUsername(r), "database", db.Name, "level", level.Name)
+
+	saved, err := s.store.GetDatabase(db.Name)
+	if err != nil {
+		writeAdminError(w, "save permission level", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newDatabaseResponse(saved))
+}
+
+// handleDeletePermissionLevel handles DELETE /api/admin/databases/{name}/levels/{level}
+// The level is also removed from the database's available permissions
+func (s *Server) handleDeletePermissionLevel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	db, err := s.store.GetDatabase(vars["name"])
+	if err != nil {
+		writeAdminError(w, "delete permission level", err)
+		return
+	}
+	if _, ok := db.Level(vars["level"]); !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.checkLevelUnused(db.Name, vars["level"]); err != nil {
+		writeAdminError(w, "delete permission level", err)
+		return
+	}
+
+	if err := s.store.DeletePermissionLevel(db.Name, vars["level"]); err != nil {
+		writeAdminError(w, "delete permission level", err)
+		return
+	}
+
+	utils.Logger.Info("permission level deleted", "admin", adminUsername(r), "database", db.Name, "level", vars["level"])
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Permission level deleted successfully",
+	})
+}
+
+// rankLevel returns the database's available permissions with level placed
+// directly above the level named by above, or where it already is when above is
+// empty, and checks that the result is a valid ranking
+func rankLevel(db *store.Database, level *store.PermissionLevel, above string) ([]string, error) {
+	ranked := slices.Clone(db.AvailablePermissions)
+	if above == "" {
+		if !slices.Contains(ranked, level.Name) {
+			return nil, invalidf("above is required to rank a new level (available: %s)", strings.Join(ranked, ", "))
+		}
+		return ranked, nil
+	}
+	if above == level.Name {
+		return nil, invalidf("level %q cannot rank above itself", level.Name)
+	}
+
+	ranked = slices.DeleteFunc(ranked, func(name string) bool { return name == level.Name })
+	i := slices.Index(ranked, above)
+	if i < 0 {
+		return nil, invalidf("above level %q is not available on database %q", above, db.Name)
+	}
+	ranked = slices.Insert(ranked, i+1, level.Name)
+
+	// Check the ranking as the database will have it, with this level defined
+	candidate := *db
+	candidate.AvailablePermissions = ranked
+	candidate.Levels = append(slices.DeleteFunc(slices.Clone(db.Levels), func(l store.PermissionLevel) bool {
+		return l.Name == level.Name
+	}), *level)
+	if err := validateDatabase(&candidate); err != nil {
+		return nil, err
+	}
+	return ranked, nil
+}
+
+// validatePermissionLevel checks a custom level definition
+func validatePermissionLevel(dbType string, level *store.PermissionLevel) error {
+	if !levelNamePattern.MatchString(level.Name) {
+		return invalidf("level name must match %s", levelNamePattern)
+	}
+	if len(level.Statements) == 0 && len(level.NativeRoles) == 0 && len(level.Objects) == 0 {
+		return invalidf("level must define statements, native_roles or objects")
+	}
+
+	// Statements may only grant to the level's principal, so a level cannot run arbitrary DDL
+	for i, stmt := range level.Statements {
+		if err := protocol.ValidateLevelStatement(dbType, stmt); err != nil {
+			return invalidf("statement %d: %v", i+1, err)
+		}
+	}
+
+	for _, role := range level.NativeRoles {
+		if strings.TrimSpace(role) == "" {
+			return invalidf("native role names must not be empty")
+		}
+	}
+
+	perm := store.Permission{Database: level.Database, Level: level.Name}
+	for _, obj := range level.Objects {
+		if err := validateObjectGrant(perm, obj); err != nil {
+			return err
+		}
+	}
+
+	if err := protocol.ValidateLimits(dbType, level.Limits); err != nil {
+		return invalidf("level %q: %v", level.Name, err)
+	}
+	return nil
+}
+
+func nonNilLevels(levels []store.PermissionLevel) []store.PermissionLevel {
+	if levels == nil {
+		return []store.PermissionLevel{}
+	}
+	return levels
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+func TestRankLevel(t *testing.T) {
+	db := &store.Database{
+		Name:                 "orders",
+		Type:                 "mysql",
+		BackendAddr:          "db.internal:3306",
+		AdminUsername:        "zgate",
+		TargetDatabase:       "orders",
+		AvailablePermissions: []string{"read", "reporting", "write", "admin"},
+		Levels:               []store.PermissionLevel{{Database: "orders", Name: "reporting"}},
+	}
+
+	tests := []struct {
+		name  string
+		level string
+		above string
+		want  []string // nil when the ranking is refused
+	}{
+		{"new level", "audit", "read", []string{"read", "audit", "reporting", "write", "admin"}},
+		{"new level below admin", "audit", "write", []string{"read", "reporting", "write", "audit", "admin"}},
+		{"existing level keeps its rank", "reporting", "", []string{"read", "reporting", "write", "admin"}},
+		{"existing level moves", "reporting", "write", []string{"read", "write", "reporting", "admin"}},
+		{"new level without a rank", "audit", "", nil},
+		{"above admin", "audit", "admin", nil},
+		{"existing level above admin", "reporting", "admin", nil},
+		{"above itself", "reporting", "reporting", nil},
+		{"above an unknown level", "audit", "owner", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := &store.PermissionLevel{Database: "orders", Name: tt.level}
+			got, err := rankLevel(db, level, tt.above)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("rankLevel = %q; want an error", got)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Fatalf("rankLevel = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+	if want := []string{"read", "reporting", "write", "admin"}; !slices.Equal(db.AvailablePermissions, want) {
+		t.Fatalf("rankLevel changed the database to %q", db.AvailablePermissions)
+	}
+}
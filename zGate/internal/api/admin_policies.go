@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// policyNamePattern restricts policy names to simple identifiers
+var policyNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// maxDryRunCases bounds how many cases one dry run evaluates
+const maxDryRunCases = 100
+
+// PolicyRequest represents a policy in admin create/update requests
+// Enabled defaults to true when omitted
+type PolicyRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Expression  string `json:"expression"`
+	Enabled     *bool  `json:"enabled"`
+}
+
+// PolicyRollbackRequest names the version a policy is restored to
+type PolicyRollbackRequest struct {
+	Version int `json:"version"`
+}
+
+// PolicyDryRunRequest evaluates an expression, or a stored policy by name,
+// against example inputs without saving anything
+type PolicyDryRunRequest struct {
+	Expression string             `json:"expression"`
+	Policy     string             `json:"policy"`
+	Cases      []PolicyDryRunCase `json:"cases"`
+}
+
+// PolicyDryRunCase is one input with, optionally, the result it should produce
+type PolicyDryRunCase struct {
+	Name   string         `json:"name"`
+	Input  map[string]any `json:"input"`
+	Expect *bool          `json:"expect,omitempty"`
+}
+
+// PolicyDryRunResult reports what one case evaluated to
+type PolicyDryRunResult struct {
+	Name    string `json:"name"`
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+	Passed  *bool  `json:"passed,omitempty"` // set when the case had an expectation
+}
+
+// PolicyDryRunResponse reports a dry run; Passed is false when any case missed its expectation
+type PolicyDryRunResponse struct {
+	Expression string               `json:"expression"`
+	Passed     bool                 `json:"passed"`
+	Results    []PolicyDryRunResult `json:"results"`
+}
+
+// handleListPolicies handles GET /api/admin/policies
+func (s *Server) handleListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := s.store.ListPolicies()
+	if err != nil {
+		writeAdminError(w, "list policies", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, policies)
+}
+
+// handleGetPolicy handles GET /api/admin/policies/{name}
+func (s *Server) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	p, err := s.store.GetPolicy(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "get policy", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// handleListPolicyVersions handles GET /api/admin/policies/{name}/versions
+func (s *Server) handleListPolicyVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.store.ListPolicyVersions(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "list policy versions", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// handleCreatePolicy handles POST /api/admin/policies
+func (s *Server) handleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var req PolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if !policyNamePattern.MatchString(req.Name) {
+		http.Error(w, "name must be lowercase letters, digits, '-' or '_' and start with a letter", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetPolicy(req.Name); err == nil {
+		http.Error(w, "policy already exists", http.StatusConflict)
+		return
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "create policy", err)
+		return
+	}
+
+	s.savePolicy(w, r, req.Name, &req, http.StatusCreated)
+}
+
+// handleUpdatePolicy handles PUT /api/admin/policies/{name}
+// Each update is stored as a new version
+func (s *Server) handleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req PolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		http.Error(w, "name cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetPolicy(name); err != nil {
+		writeAdminError(w, "update policy", err)
+		return
+	}
+
+	s.savePolicy(w, r, name, &req, http.StatusOK)
+}
+
+// handleRollbackPolicy handles POST /api/admin/policies/{name}/rollback
+// The chosen version's content is saved as a new version, so history only grows
+func (s *Server) handleRollbackPolicy(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req PolicyRollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	old, err := s.store.GetPolicyVersion(name, req.Version)
+	if err != nil {
+		writeAdminError(w, "roll back policy", err)
+		return
+	}
+	if old.Deleted {
+		http.Error(w, "cannot roll back to a deletion", http.StatusBadRequest)
+		return
+	}
+
+	s.savePolicy(w, r, name, &PolicyRequest{
+		Description: old.Description,
+		Expression:  old.Expression,
+		Enabled:     &old.Enabled,
+	}, http.StatusOK)
+}
+
+// handleDeletePolicy handles DELETE /api/admin/policies/{name}
+// The deletion is recorded as a version; earlier versions stay listed
+func (s *Server) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := s.store.DeletePolicy(name, adminUsername(r)); err != nil {
+		writeAdminError(w, "delete policy", err)
+		return
+	}
+
+	utils.Logger.Info("policy deleted", "admin", adminUsername(r), "policy", name)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Policy deleted successfully",
+	})
+}
+
+// handlePolicyDryRun handles POST /api/admin/policies/dry-run
+// It evaluates an expression, or the stored policy named by policy, against each case's input
+func (s *Server) handlePolicyDryRun(w http.ResponseWriter, r *http.Request) {
+	var req PolicyDryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if (req.Expression == "") == (req.Policy == "") {
+		http.Error(w, "exactly one of expression or policy is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Cases) == 0 || len(req.Cases) > maxDryRunCases {
+		http.Error(w, "between 1 and 100 cases are required", http.StatusBadRequest)
+		return
+	}
+
+	expression := req.Expression
+	if req.Policy != "" {
+		p, err := s.store.GetPolicy(req.Policy)
+		if err != nil {
+			writeAdminError(w, "dry-run policy", err)
+			return
+		}
+		expression = p.Expression
+	}
+	program, err := policy.CompileExpression(expression)
+	if err != nil {
+		writeAdminError(w, "dry-run policy", invalidf("expression: %v", err))
+		return
+	}
+
+	resp := PolicyDryRunResponse{Expression: expression, Passed: true, Results: []PolicyDryRunResult{}}
+	for _, c := range req.Cases {
+		result := PolicyDryRunResult{Name: c.Name}
+		allowed, err := policy.EvaluateProgram(program, dryRunInput(c.Input))
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Allowed = allowed
+		if c.Expect != nil {
+			passed := err == nil && allowed == *c.Expect
+			result.Passed = &passed
+			resp.Passed = resp.Passed && passed
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dryRunInput fills in the top-level user, database and request maps a case leaves out,
+// so expressions fail on the fields they read rather than on a missing variable.
+// An RFC 3339 request.time becomes a timestamp, as it is for real requests.
+func dryRunInput(input map[string]any) map[string]any {
+	out := map[string]any{"user": map[string]any{}, "database": map[string]any{}, "request": map[string]any{}}
+	for key, value := range input {
+		out[key] = value
+	}
+	if request, ok := out["request"].(map[string]any); ok {
+		if text, ok := request["time"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, text); err == nil {
+				request["time"] = t
+			}
+		}
+	}
+	return out
+}
+
+// savePolicy validates req and stores it as the next version of the policy called name
+func (s *Server) savePolicy(w http.ResponseWriter, r *http.Request, name string, req *PolicyRequest, status int) {
+	if req.Expression == "" {
+		http.Error(w, "expression is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := policy.CompileExpression(req.Expression); err != nil {
+		writeAdminError(w, "save policy", invalidf("expression: %v", err))
+		return
+	}
+
+	p := &store.Policy{
+		Name:        name,
+		Description: req.Description,
+		Expression:  req.Expression,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		CreatedBy:   adminUsername(r),
+	}
+	if err := s.store.SavePolicy(p); err != nil {
+		writeAdminError(w, "save policy", err)
+		return
+	}
+
+	utils.Logger.Info("policy saved", "admin", adminUsername(r), "policy", name, "version", p.Version, "enabled", p.Enabled)
+
+	writeJSON(w, status, p)
+}
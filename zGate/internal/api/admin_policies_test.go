@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dryRun posts body to the policy dry-run handler and decodes a successful response
+func dryRun(t *testing.T, body string) (int, PolicyDryRunResponse) {
+	t.Helper()
+	s := &Server{}
+	w := httptest.NewRecorder()
+	s.handlePolicyDryRun(w, httptest.NewRequest("POST", "/api/admin/policies/dry-run", strings.NewReader(body)))
+
+	var resp PolicyDryRunResponse
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestPolicyDryRun(t *testing.T) {
+	status, resp := dryRun(t, `{
+		"expression": "request.level != \"admin\" || request.time < timestamp(\"2026-01-01T00:00:00Z\")",
+		"cases": [
+			{"name": "read", "input": {"request": {"level": "read", "time": "2026-10-16T12:00:00Z"}}, "expect": true},
+			{"name": "admin", "input": {"request": {"level": "admin", "time": "2026-10-16T12:00:00Z"}}, "expect": false},
+			{"name": "admin last year", "input": {"request": {"level": "admin", "time": "2025-10-16T12:00:00Z"}}},
+			{"name": "missing level", "input": {"user": {"name": "alice"}}, "expect": false}
+		]
+	}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d; want 200", status)
+	}
+
+	type want struct {
+		allowed bool
+		failed  bool
+		passed  *bool
+	}
+	yes, no := true, false
+	wants := []want{
+		{allowed: true, passed: &yes},
+		{allowed: false, passed: &yes},
+		{allowed: true},
+		// An input the expression cannot evaluate never meets an expectation, even a denial
+		{allowed: false, failed: true, passed: &no},
+	}
+	if len(resp.Results) != len(wants) {
+		t.Fatalf("%d results; want %d", len(resp.Results), len(wants))
+	}
+	for i, w := range wants {
+		got := resp.Results[i]
+		if got.Allowed != w.allowed || (got.Error != "") != w.failed {
+			t.Errorf("case %q: allowed %v, error %q; want allowed %v, error %v", got.Name, got.Allowed, got.Error, w.allowed, w.failed)
+		}
+		if (got.Passed == nil) != (w.passed == nil) || (got.Passed != nil && *got.Passed != *w.passed) {
+			t.Errorf("case %q: passed %v; want %v", got.Name, got.Passed, w.passed)
+		}
+	}
+	if resp.Passed {
+		t.Errorf("dry run passed with a failed case")
+	}
+}
+
+func TestPolicyDryRunRejectsInvalidRequests(t *testing.T) {
+	tests := map[string]string{
+		"no expression":       `{"cases": [{"input": {}}]}`,
+		"expression and name": `{"expression": "true", "policy": "p", "cases": [{"input": {}}]}`,
+		"no cases":            `{"expression": "true", "cases": []}`,
+		"does not compile":    `{"expression": "user.name ==", "cases": [{"input": {}}]}`,
+		"not a bool":          `{"expression": "1 + 2", "cases": [{"input": {}}]}`,
+	}
+	for name, body := range tests {
+		if status, _ := dryRun(t, body); status != http.StatusBadRequest {
+			t.Errorf("%s: status = %d; want 400", name, status)
+		}
+	}
+}
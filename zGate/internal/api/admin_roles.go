@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// RoleRequest represents a role in admin create/update requests
+// Parents name the roles whose permissions this role inherits
+type RoleRequest struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Parents     []string           `json:"parents"`
+	Permissions []store.Permission `json:"permissions"`
+}
+
+// RoleResponse represents a role returned to admins
+// Permissions are assigned to the role directly; EffectivePermissions adds those
+// inherited from its parents, each with the role it comes from. Users lists everyone
+// holding the role, including through a role that inherits from it
+type RoleResponse struct {
+	Name                 string             `json:"name"`
+	Description          string             `json:"description"`
+	Parents              []string           `json:"parents"`
+	Permissions          []store.Permission `json:"permissions"`
+	EffectivePermissions []policy.Grant     `json:"effective_permissions"`
+	Users                []string           `json:"users"`
+}
+
+func (s *Server) newRoleResponse(role *store.Role) (RoleResponse, error) {
+	users, err := s.store.GetUsersForRole(role.Name)
+	if err != nil {
+		return RoleResponse{}, err
+	}
+
+	effective := []policy.Grant{}
+	for _, perm := range role.Permissions {
+		effective = append(effective, policy.Grant{Source: policy.RoleSource(role.Name), Permission: perm})
+	}
+	ancestors, err := s.store.RoleAncestors(role.Name)
+	if err != nil {
+		return RoleResponse{}, err
+	}
+	for _, name := range ancestors {
+		ancestor, err := s.store.GetRole(name)
+		if err != nil {
+			return RoleResponse{}, err
+		}
+		for _, perm := range ancestor.Permissions {
+			effective = append(effective, policy.Grant{Source: policy.RoleSource(name), Permission: perm})
+		}
+	}
+
+	return RoleResponse{
+		Name:                 role.Name,
+		Description:          role.Description,
+		Parents:              nonNilStrings(role.Parents),
+		Permissions:          nonNilPermissions(role.Permissions),
+		EffectivePermissions: effective,
+		Users:                nonNilStrings(users),
+	}, nil
+}
+
+// handleListRoles handles GET /api/admin/roles
+func (s *Server) handleListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := s.store.ListRoles()
+	if err != nil {
+		writeAdminError(w, "list roles", err)
+		return
+	}
+
+	resp := make([]RoleResponse, 0, len(roles))
+	for i := range roles {
+		role, err := s.newRoleResponse(&roles[i])
+		if err != nil {
+			writeAdminError(w, "list roles", err)
+			return
+		}
+		resp = append(resp, role)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetRole handles GET /api/admin/roles/{name}
+func (s *Server) handleGetRole(w http.ResponseWriter, r *http.Request) {
+	role, err := s.store.GetRole(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "get role", err)
+		return
+	}
+
+	resp, err := s.newRoleResponse(role)
+	if err != nil {
+		writeAdminError(w, "get role", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreateRole handles POST /api/admin/roles
+func (s *Server) handleCreateRole(w http.ResponseWriter, r *http.Request) {
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetRole(req.Name); err == nil {
+		http.Error(w, "role already exists", http.StatusConflict)
+		return
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "create role", err)
+		return
+	}
+
+	s.saveRole(w, r, &store.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Parents:     req.Parents,
+		Permissions: req.Permissions,
+	}, http.StatusCreated)
+}
+
+// handleUpdateRole handles PUT /api/admin/roles/{name}
+// Permissions in the request replace the role's current permissions
+func (s *Server) handleUpdateRole(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		http.Error(w, "role name cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetRole(name); err != nil {
+		writeAdminError(w, "update role", err)
+		return
+	}
+
+	s.saveRole(w, r, &store.Role{
+		Name:        name,
+		Description: req.Description,
+		Parents:     req.Parents,
+		Permissions: req.Permissions,
+	}, http.StatusOK)
+}
+
+// saveRole validates and persists a role, then writes it back to the client
+// The store rejects parents that would make the role inherit from itself
+func (s *Server) saveRole(w http.ResponseWriter, r *http.Request, role *store.Role, status int) {
+	if err := s.validateRoleParents(role); err != nil {
+		writeAdminError(w, "save role", err)
+		return
+	}
+	if err := s.validatePermissions(role.Permissions); err != nil {
+		writeAdminError(w, "save role", err)
+		return
+	}
+
+	var before []store.Permission
+	if existing, err := s.store.GetRole(role.Name); err == nil {
+		before = existing.Permissions
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "save role", err)
+		return
+	}
+
+	if err := s.store.SaveRole(role); err != nil {
+		writeAdminError(w, "save role", err)
+		return
+	}
+
+	utils.Logger.Info("role saved", "admin", adminUsername(r), "role", role.Name,
+		"parents", role.Parents, "permissions", len(role.Permissions))
+	s.notifyNewGrants(before, role.Permissions, policy.RoleSource(role.Name), adminUsername(r))
+
+	saved, err := s.store.GetRole(role.Name)
+	if err != nil {
+		writeAdminError(w, "save role", err)
+		return
+	}
+	resp, err := s.newRoleResponse(saved)
+	if err != nil {
+		writeAdminError(w, "save role", err)
+		return
+	}
+	writeJSON(w, status, resp)
+}
+
+// handleRevokeRole handles DELETE /api/admin/roles/{name}
+func (s *Server) handleRevokeRole(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := s.store.DeleteRole(name); err != nil {
+		writeAdminError(w, "delete role", err)
+		return
+	}
+
+	utils.Logger.Info("role deleted", "admin", adminUsername(r), "role", name)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Role deleted successfully",
+	})
+}
+
+// validateRoleParents checks that a role's parents exist and are listed once
+func (s *Server) validateRoleParents(role *store.Role) error {
+	seen := make(map[string]bool, len(role.Parents))
+	for _, parent := range role.Parents {
+		if seen[parent] {
+			return invalidf("duplicate parent role %q", parent)
+		}
+		seen[parent] = true
+
+		if _, err := s.store.GetRole(parent); err != nil {
+			if store.IsNotFound(err) {
+				return invalidf("unknown parent role %q", parent)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func nonNilPermissions(perms []store.Permission) []store.Permission {
+	if perms == nil {
+		return []store.Permission{}
+	}
+	return perms
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
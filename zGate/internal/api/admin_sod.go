@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// SoDRuleRequest represents a separation-of-duties rule in admin create/update requests
+// No user may hold two or more of its roles and permissions at once
+type SoDRuleRequest struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Roles       []string              `json:"roles"`
+	Permissions []store.SoDPermission `json:"permissions"`
+}
+
+// SoDRuleResponse is a rule with the users currently violating it
+type SoDRuleResponse struct {
+	store.SoDRule
+	Violations []store.SoDViolation `json:"violations"`
+}
+
+// handleListSoDRules handles GET /api/admin/sod-rules
+func (s *Server) handleListSoDRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.store.ListSoDRules()
+	if err != nil {
+		writeAdminError(w, "list sod rules", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handleGetSoDRule handles GET /api/admin/sod-rules/{name}
+func (s *Server) handleGetSoDRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := s.store.GetSoDRule(mux.Vars(r)["name"])
+	if err != nil {
+		writeAdminError(w, "get sod rule", err)
+		return
+	}
+	s.writeSoDRule(w, rule, http.StatusOK)
+}
+
+// handleCreateSoDRule handles POST /api/admin/sod-rules
+func (s *Server) handleCreateSoDRule(w http.ResponseWriter, r *http.Request) {
+	var req SoDRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if !policyNamePattern.MatchString(req.Name) {
+		http.Error(w, "name must be lowercase letters, digits, '-' or '_' and start with a letter", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetSoDRule(req.Name); err == nil {
+		http.Error(w, "sod rule already exists", http.StatusConflict)
+		return
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "create sod rule", err)
+		return
+	}
+
+	s.saveSoDRule(w, r, req.Name, &req, http.StatusCreated)
+}
+
+// handleUpdateSoDRule handles PUT /api/admin/sod-rules/{name}
+func (s *Server) handleUpdateSoDRule(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req SoDRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" && req.Name != name {
+		http.Error(w, "name cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetSoDRule(name); err != nil {
+		writeAdminError(w, "update sod rule", err)
+		return
+	}
+
+	s.saveSoDRule(w, r, name, &req, http.StatusOK)
+}
+
+// handleDeleteSoDRule handles DELETE /api/admin/sod-rules/{name}
+func (s *Server) handleDeleteSoDRule(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := s.store.DeleteSoDRule(name); err != nil {
+		writeAdminError(w, "delete sod rule", err)
+		return
+	}
+
+	utils.Logger.Info("sod rule deleted", "admin", adminUsername(r), "rule", name)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "SoD rule deleted successfully",
+	})
+}
+
+// handleListSoDViolations handles GET /api/admin/sod-violations
+// It lists every user holding conflicting entries of a rule, optionally only for the rule query parameter
+func (s *Server) handleListSoDViolations(w http.ResponseWriter, r *http.Request) {
+	violations, err := s.store.ListSoDViolations()
+	if err != nil {
+		writeAdminError(w, "list sod violations", err)
+		return
+	}
+	if rule := r.URL.Query().Get("rule"); rule != "" {
+		violations = slices.DeleteFunc(violations, func(v store.SoDViolation) bool { return v.Rule != rule })
+	}
+	writeJSON(w, http.StatusOK, violations)
+}
+
+// saveSoDRule validates req and stores it as the rule called name
+// Saving never changes existing assignments; users already in violation are reported back
+func (s *Server) saveSoDRule(w http.ResponseWriter, r *http.Request, name string, req *SoDRuleRequest, status int) {
+	rule := &store.SoDRule{
+		Name:        name,
+		Description: req.Description,
+		Roles:       req.Roles,
+		Permissions: req.Permissions,
+	}
+	if err := s.validateSoDRule(rule); err != nil {
+		writeAdminError(w, "save sod rule", err)
+		return
+	}
+
+	if err := s.store.SaveSoDRule(rule); err != nil {
+		writeAdminError(w, "save sod rule", err)
+		return
+	}
+
+	utils.Logger.Info("sod rule saved", "admin", adminUsername(r), "rule", name, "roles", rule.Roles, "permissions", len(rule.Permissions))
+
+	saved, err := s.store.GetSoDRule(name)
+	if err != nil {
+		writeAdminError(w, "save sod rule", err)
+		return
+	}
+	s.writeSoDRule(w, saved, status)
+}
+
+// writeSoDRule writes a rule back to the client with its current violations
+func (s *Server) writeSoDRule(w http.ResponseWriter, rule *store.SoDRule, status int) {
+	violations, err := s.store.ListSoDViolations()
+	if err != nil {
+		writeAdminError(w, "list sod violations", err)
+		return
+	}
+	violations = slices.DeleteFunc(violations, func(v store.SoDViolation) bool { return v.Rule != rule.Name })
+
+	rule.Roles = nonNilStrings(rule.Roles)
+	if rule.Permissions == nil {
+		rule.Permissions = []store.SoDPermission{}
+	}
+	writeJSON(w, status, SoDRuleResponse{SoDRule: *rule, Violations: violations})
+}
+
+// validateSoDRule checks that a rule names at least two existing entries, each once
+func (s *Server) validateSoDRule(rule *store.SoDRule) error {
+	if len(rule.Roles)+len(rule.Permissions) < 2 {
+		return invalidf("a rule needs at least two roles or permissions to keep apart")
+	}
+
+	seen := make(map[string]bool, len(rule.Roles))
+	for _, role := range rule.Roles {
+		if seen[role] {
+			return invalidf("duplicate role %q", role)
+		}
+		seen[role] = true
+
+		if _, err := s.store.GetRole(role); err != nil {
+			if store.IsNotFound(err) {
+				return invalidf("unknown role %q", role)
+			}
+			return err
+		}
+	}
+
+	seen = make(map[string]bool, len(rule.Permissions))
+	for _, perm := range rule.Permissions {
+		if perm.Database == "" {
+			return invalidf("permission database is required")
+		}
+		if seen[perm.String()] {
+			return invalidf("duplicate permission %q", perm.String())
+		}
+		seen[perm.String()] = true
+
+		db, err := s.store.GetDatabase(perm.Database)
+		if err != nil {
+			if store.IsNotFound(err) {
+				return invalidf("unknown database %q", perm.Database)
+			}
+			return err
+		}
+		if perm.Level != "" && !slices.Contains(db.AvailablePermissions, perm.Level) {
+			return invalidf("level %q is not available on database %q (available: %s)",
+				perm.Level, perm.Database, strings.Join(db.AvailablePermissions, ", "))
+		}
+	}
+	return nil
+}
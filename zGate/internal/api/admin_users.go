@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// UserRequest represents a user in admin create/update requests
+// Password is required on create; on update an empty password keeps the current one
+type UserRequest struct {
+	Username          string             `json:"username"`
+	Password          string             `json:"password"`
+	Roles             []string           `json:"roles"`
+	CustomPermissions []store.Permission `json:"custom_permissions"`
+	// RoleValidity optionally limits when each role assignment applies
+	RoleValidity map[string]store.Validity `json:"role_validity,omitempty"`
+	// Attributes are free-form properties policies can match on, e.g. {"team":"payments"}
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// UserResponse represents a user returned to admins
+type UserResponse struct {
+	Username          string                    `json:"username"`
+	Roles             []string                  `json:"roles"`
+	CustomPermissions []store.Permission        `json:"custom_permissions"`
+	RoleValidity      map[string]store.Validity `json:"role_validity,omitempty"`
+	Attributes        map[string]string         `json:"attributes,omitempty"`
+	CreatedAt         time.Time                 `json:"created_at"`
+}
+
+func newUserResponse(user *store.User) UserResponse {
+	return UserResponse{
+		Username:          user.Username,
+		Roles:             nonNilStrings(user.Roles),
+		CustomPermissions: nonNilPermissions(user.CustomPermissions),
+		RoleValidity:      user.RoleValidity,
+		Attributes:        user.Attributes,
+		CreatedAt:         user.CreatedAt,
+	}
+}
+
+// handleListUsers handles GET /api/admin/users
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.store.ListUsers()
+	if err != nil {
+		writeAdminError(w, "list users", err)
+		return
+	}
+
+	resp := make([]UserResponse, 0, len(users))
+	for i := range users {
+		resp = append(resp, newUserResponse(&users[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetUser handles GET /api/admin/users/{username}
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := s.store.GetUser(mux.Vars(r)["username"])
+	if err != nil {
+		writeAdminError(w, "get user", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// handleCreateUser handles POST /api/admin/users
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetUser(req.Username); err == nil {
+		http.Error(w, "user already exists", http.StatusConflict)
+		return
+	} else if !store.IsNotFound(err) {
+		writeAdminError(w, "create user", err)
+		return
+	}
+
+	if err := s.validateUserRequest(&req); err != nil {
+		writeAdminError(w, "create user", err)
+		return
+	}
+
+	user := &store.User{
+		Username:          req.Username,
+		Roles:             req.Roles,
+		CustomPermissions: req.CustomPermissions,
+		RoleValidity:      req.RoleValidity,
+		Attributes:        req.Attributes,
+	}
+	if err := s.store.CreateUserWithPassword(user, req.Password); err != nil {
+		writeAdminError(w, "create user", err)
+		return
+	}
+
+	utils.Logger.Info("user created", "admin", adminUsername(r), "username", req.Username, "roles", req.Roles)
+	s.notifyNewGrants(nil, user.CustomPermissions, "user:"+user.Username, adminUsername(r))
+
+	s.writeUser(w, req.Username, http.StatusCreated)
+}
+
+// handleUpdateUser handles PUT /api/admin/users/{username}
+// Roles and custom permissions in the request replace the user's current ones
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Username != "" && req.Username != username {
+		http.Error(w, "username cannot be changed", http.StatusBadRequest)
+		return
+	}
+	req.Username = username
+
+	user, err := s.store.GetUser(username)
+	if err != nil {
+		writeAdminError(w, "update user", err)
+		return
+	}
+
+	if err := s.validateUserRequest(&req); err != nil {
+		writeAdminError(w, "update user", err)
+		return
+	}
+
+	before := user.CustomPermissions
+	user.Roles = req.Roles
+	user.CustomPermissions = req.CustomPermissions
+	user.RoleValidity = req.RoleValidity
+	user.Attributes = req.Attributes
+	// A new password is saved with the rest of the user, so neither is kept without the other
+	if req.Password != "" {
+		err = s.store.SaveUserWithPassword(user, req.Password)
+	} else {
+		err = s.store.SaveUser(user)
+	}
+	if err != nil {
+		writeAdminError(w, "update user", err)
+		return
+	}
+
+	utils.Logger.Info("user updated", "admin", adminUsername(r), "username", username,
+		"roles", req.Roles, "password_changed", req.Password != "")
+	s.notifyNewGrants(before, user.CustomPermissions, "user:"+username, adminUsername(r))
+
+	s.writeUser(w, username, http.StatusOK)
+}
+
+// handleRevokeUser handles DELETE /api/admin/users/{username}
+// Deleting a user revokes its refresh tokens and stops its proxy sessions
+func (s *Server) handleRevokeUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	if err := s.store.DeleteUser(username); err != nil {
+		writeAdminError(w, "delete user", err)
+		return
+	}
+
+	stopped := s.proxyManager.StopSessionsForUser(username)
+
+	utils.Logger.Info("user deleted", "admin", adminUsername(r), "username", username, "sessions_stopped", stopped)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "User deleted successfully",
+	})
+}
+
+// validateUserRequest checks that roles exist and custom permissions are valid
+func (s *Server) validateUserRequest(req *UserRequest) error {
+	seen := make(map[string]bool, len(req.Roles))
+	for _, role := range req.Roles {
+		if seen[role] {
+			return invalidf("duplicate role %q", role)
+		}
+		seen[role] = true
+
+		if _, err := s.store.GetRole(role); err != nil {
+			if store.IsNotFound(err) {
+				return invalidf("unknown role %q", role)
+			}
+			return err
+		}
+	}
+
+	for role, window := range req.RoleValidity {
+		if !seen[role] {
+			return invalidf("role_validity names role %q, which is not assigned", role)
+		}
+		if err := validateValidity(window); err != nil {
+			return invalidf("role %q: %v", role, err)
+		}
+	}
+	return s.validatePermissions(req.CustomPermissions)
+}
+
+// writeUser reloads a user from the store and writes it back to the client
+func (s *Server) writeUser(w http.ResponseWriter, username string, status int) {
+	user, err := s.store.GetUser(username)
+	if err != nil {
+		writeAdminError(w, "get user", err)
+		return
+	}
+	writeJSON(w, status, newUserResponse(user))
+}
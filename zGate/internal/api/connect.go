@@ -1,104 +1,188 @@
-package api
-
-import (
-	"encoding/json"
-	"net/http"
-
-	"github.com/zGate-Team/zGate-Platform/internal/auth"
-	"github.com/zGate-Team/zGate-Platform/internal/utils"
-)
-
-// ConnectRequest represents connect request payload
-type ConnectRequest struct {
-	DatabaseName string `json:"database_name"`
-}
-
-// ConnectResponse represents connect response payload
-type ConnectResponse struct {
-	Port         int    `json:"port"`
-	DatabaseName string `json:"database_name"`
-	Message      string `json:"message"`
-	TempUsername string `json:"temp_username"`
-	TempPassword string `json:"temp_password"`
-}
-
-// handleConnect handles POST /api/connect
-func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("claims").(*auth.Claims)
-	token := r.Context().Value("token").(string)
-
-	var req ConnectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
-		return
-	}
-
-	utils.Logger.Info("connect request", "username", claims.Username, "database", req.DatabaseName)
-
-	// Check permission
-	if !s.policyEngine.CanAccess(claims, req.DatabaseName) {
-		utils.Logger.Warn("access denied", "username", claims.Username, "database", req.DatabaseName)
-		http.Error(w, "access denied", http.StatusForbidden)
-		return
-	}
-
-	// Start proxy session (creates temp DB user)
-	session, err := s.proxyManager.StartSession(token, claims, req.DatabaseName)
-	if err != nil {
-		utils.Logger.Error("failed to start session", "error", err)
-		http.Error(w, "failed to start proxy", http.StatusInternalServerError)
-		return
-	}
-
-	utils.Logger.Info("proxy session started",
-		"username", claims.Username,
-		"database", req.DatabaseName,
-		"port", session.Port,
-		"temp_user", session.TempCredentials.Username,
-	)
-
-	// Return connection info with temp credentials
-	resp := ConnectResponse{
-		Port:         session.Port,
-		DatabaseName: req.DatabaseName,
-		Message:      "Proxy started successfully",
-		TempUsername: session.TempCredentials.Username,
-		TempPassword: session.TempCredentials.Password,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-}
-
-// DisconnectRequest represents disconnect request payload
-type DisconnectRequest struct {
-	DatabaseName string `json:"database_name"`
-}
-
-// handleDisconnect handles POST /api/disconnect
-func (s *Server) handleDisconnect(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("claims").(*auth.Claims)
-	token := r.Context().Value("token").(string)
-
-	var req DisconnectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
-		return
-	}
-
-	utils.Logger.Info("disconnect request", "username", claims.Username, "database", req.DatabaseName)
-
-	// Stop session (deletes temp user)
-	if err := s.proxyManager.StopSession(token); err != nil {
-		utils.Logger.Error("failed to stop session", "error", err)
-		http.Error(w, "failed to stop proxy", http.StatusInternalServerError)
-		return
-	}
-
-	utils.Logger.Info("proxy session stopped", "username", claims.Username, "database", req.DatabaseName)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Disconnected successfully",
-	})
-}
\ No newline at end of file
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/audit"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/privileges"
+	"github.com/zGate-Team/zGate-Platform/internal/proxy"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// ConnectRequest represents connect request payload
+type ConnectRequest struct {
+	DatabaseName string `json:"database_name"`
+	Ticket       string `json:"ticket,omitempty"` // optional change/incident reference passed to backend audit
+	// BreakGlass requests emergency access without a grant; it needs the incident
+	// reference in Ticket and a Justification
+	BreakGlass    bool   `json:"break_glass,omitempty"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// ConnectResponse represents connect response payload
+type ConnectResponse struct {
+	SessionID    string    `json:"session_id"`
+	Port         int       `json:"port"`
+	DatabaseName string    `json:"database_name"`
+	Message      string    `json:"message"`
+	TempUsername string    `json:"temp_username"`
+	TempPassword string    `json:"temp_password"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	// Level and BreakGlassID are set for break-glass sessions
+	Level        string `json:"level,omitempty"`
+	BreakGlassID int64  `json:"break_glass_id,omitempty"`
+	// IdentityWarning is set when backend audit cannot name the user behind the temp user
+	IdentityWarning string `json:"identity_warning,omitempty"`
+}
+
+// handleConnect handles POST /api/connect
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	token := r.Context().Value("token").(string)
+
+	var req ConnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info("connect request", "username", claims.Username, "database", req.DatabaseName)
+
+	if len(req.Ticket) > audit.MaxTicketLength {
+		http.Error(w, fmt.Sprintf("ticket must be at most %d characters", audit.MaxTicketLength), http.StatusBadRequest)
+		return
+	}
+
+	rc := requestContext(r)
+	var session *proxy.Session
+	var err error
+	if req.BreakGlass {
+		if msg := validateBreakGlass(&req); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		// Break-glass skips the grant check; eligibility and policies are checked when the session starts
+		utils.Logger.Warn("break-glass connect request",
+			"username", claims.Username,
+			"database", req.DatabaseName,
+			"incident", req.Ticket,
+			"justification", req.Justification,
+			"client_ip", rc.ClientIP,
+		)
+		session, err = s.proxyManager.StartBreakGlassSession(token, claims, req.DatabaseName, req.Ticket, req.Justification, rc.ClientIP)
+	} else {
+		// Check permission, including conditions on the client address and time
+		if allowed, reason := s.policyEngine.CanAccess(claims, req.DatabaseName, rc); !allowed {
+			utils.Logger.Warn("access denied", "username", claims.Username, "database", req.DatabaseName, "client_ip", rc.ClientIP, "reason", reason)
+			http.Error(w, "access denied: "+reason, http.StatusForbidden)
+			return
+		}
+
+		// Start proxy session (creates temp DB user)
+		session, err = s.proxyManager.StartSession(token, claims, req.DatabaseName, req.Ticket, rc.ClientIP)
+	}
+	if errors.Is(err, proxy.ErrBreakGlassDisabled) || errors.Is(err, proxy.ErrBreakGlassDenied) {
+		utils.Logger.Warn("break-glass denied", "username", claims.Username, "database", req.DatabaseName, "reason", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		utils.Logger.Error("failed to start session", "error", err)
+
+		// Tell the user when the backend did not apply the grants policy asked for
+		var mismatch *privileges.MismatchError
+		if errors.As(err, &mismatch) {
+			http.Error(w, "database user provisioning failed: "+mismatch.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "failed to start proxy", http.StatusInternalServerError)
+		return
+	}
+
+	utils.Logger.Info("proxy session started",
+		"username", claims.Username,
+		"database", req.DatabaseName,
+		"port", session.Port,
+		"temp_user", session.TempCredentials.Username,
+	)
+
+	// Return connection info with temp credentials
+	resp := ConnectResponse{
+		SessionID:    session.ID,
+		Port:         session.Port,
+		DatabaseName: req.DatabaseName,
+		Message:      "Proxy started successfully",
+		TempUsername: session.TempCredentials.Username,
+		TempPassword: session.TempCredentials.Password,
+		ExpiresAt:    session.ExpiresAt,
+	}
+	if session.BreakGlass != nil {
+		resp.Level = session.Permission.Level
+		resp.BreakGlassID = session.BreakGlass.ID
+		resp.Message = "Break-glass session started; this use has been alerted and will be reviewed"
+	}
+	if session.IdentityError != "" {
+		resp.IdentityWarning = "backend audit will not attribute this session to you: " + session.IdentityError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateBreakGlass trims a break-glass request and returns what is wrong with it, if anything
+func validateBreakGlass(req *ConnectRequest) string {
+	req.Ticket = strings.TrimSpace(req.Ticket)
+	req.Justification = strings.TrimSpace(req.Justification)
+	maxIncident := audit.MaxTicketLength - len(audit.BreakGlassPrefix)
+	switch {
+	case req.Ticket == "":
+		return "ticket is required for break-glass access: give the incident reference"
+	case len(req.Ticket) > maxIncident:
+		return fmt.Sprintf("ticket must be at most %d characters for break-glass access", maxIncident)
+	case req.Justification == "":
+		return "justification is required for break-glass access"
+	case len(req.Justification) > maxJustificationLength:
+		return fmt.Sprintf("justification must be at most %d characters", maxJustificationLength)
+	}
+	return ""
+}
+
+// DisconnectRequest represents disconnect request payload
+type DisconnectRequest struct {
+	DatabaseName string `json:"database_name"`
+}
+
+// handleDisconnect handles POST /api/disconnect
+func (s *Server) handleDisconnect(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	token := r.Context().Value("token").(string)
+
+	var req DisconnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info("disconnect request", "username", claims.Username, "database", req.DatabaseName)
+
+	// Stop session (deletes temp user)
+	if err := s.proxyManager.StopSession(token); err != nil {
+		utils.Logger.Error("failed to stop session", "error", err)
+		http.Error(w, "failed to stop proxy", http.StatusInternalServerError)
+		return
+	}
+
+	utils.Logger.Info("proxy session stopped", "username", claims.Username, "database", req.DatabaseName)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Disconnected successfully",
+	})
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/proxy"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// maxJustificationLength bounds the reason a user gives for an elevation
+const maxJustificationLength = 500
+
+// ElevateRequest represents an in-session elevation request
+type ElevateRequest struct {
+	Level         string `json:"level"`
+	Minutes       int    `json:"minutes"`
+	Justification string `json:"justification"`
+}
+
+// ElevateResponse represents an active elevation
+type ElevateResponse struct {
+	ElevationID  int64     `json:"elevation_id"`
+	SessionID    string    `json:"session_id"`
+	DatabaseName string    `json:"database_name"`
+	Level        string    `json:"level"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Message      string    `json:"message"`
+}
+
+// handleElevate handles POST /api/elevate
+// It elevates the caller's live proxy session to a higher level for a bounded time
+func (s *Server) handleElevate(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	token := r.Context().Value("token").(string)
+
+	var req ElevateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	req.Justification = strings.TrimSpace(req.Justification)
+	maxMinutes := int(proxy.MaxElevationWindow / time.Minute)
+	switch {
+	case req.Level == "":
+		http.Error(w, "level is required", http.StatusBadRequest)
+		return
+	case req.Minutes < 1 || req.Minutes > maxMinutes:
+		http.Error(w, fmt.Sprintf("minutes must be between 1 and %d", maxMinutes), http.StatusBadRequest)
+		return
+	case req.Justification == "":
+		http.Error(w, "justification is required", http.StatusBadRequest)
+		return
+	case len(req.Justification) > maxJustificationLength:
+		http.Error(w, fmt.Sprintf("justification must be at most %d characters", maxJustificationLength), http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info("elevation request", "username", claims.Username, "level", req.Level, "minutes", req.Minutes)
+
+	elevation, err := s.proxyManager.Elevate(token, req.Level, req.Justification, time.Duration(req.Minutes)*time.Minute)
+	if err != nil {
+		switch {
+		case errors.Is(err, proxy.ErrSessionNotFound):
+			http.Error(w, "no active session", http.StatusNotFound)
+		case errors.Is(err, proxy.ErrElevationActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, proxy.ErrElevationDenied):
+			utils.Logger.Warn("elevation denied", "username", claims.Username, "level", req.Level, "reason", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			utils.Logger.Error("failed to elevate session", "username", claims.Username, "error", err)
+			http.Error(w, "failed to elevate session", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ElevateResponse{
+		ElevationID:  elevation.Record.ID,
+		SessionID:    elevation.Record.SessionID,
+		DatabaseName: elevation.Record.Database,
+		Level:        elevation.Record.Level,
+		ExpiresAt:    elevation.Record.ExpiresAt,
+		Message:      "Session elevated; reconnect if existing connections do not see the new privileges",
+	})
+}
+
+// handleEndElevation handles DELETE /api/elevate
+// It revokes the caller's active elevation before its window ends
+func (s *Server) handleEndElevation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	token := r.Context().Value("token").(string)
+
+	if err := s.proxyManager.EndElevation(token); err != nil {
+		switch {
+		case errors.Is(err, proxy.ErrSessionNotFound):
+			http.Error(w, "no active session", http.StatusNotFound)
+		case errors.Is(err, proxy.ErrNotElevated):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			utils.Logger.Error("failed to end elevation", "username", claims.Username, "error", err)
+			http.Error(w, "failed to end elevation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Elevation ended",
+	})
+}
+
+// handleListElevations handles GET /api/admin/elevations
+// An optional username query parameter narrows the list to one user
+func (s *Server) handleListElevations(w http.ResponseWriter, r *http.Request) {
+	elevations, err := s.store.ListElevations(r.URL.Query().Get("username"))
+	if err != nil {
+		writeAdminError(w, "list elevations", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, elevations)
+}
@@ -1,99 +1,198 @@
-package api
-
-import (
-	"encoding/json"
-	"net/http"
-	"time"
-
-	"github.com/zGate-Team/zGate-Platform/internal/auth"
-	"github.com/zGate-Team/zGate-Platform/internal/utils"
-)
-
-// LoginRequest represents login request payload
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-// LoginResponse represents login response payload (OAuth2-style)
-type LoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"` // seconds
-	Username     string `json:"username"`
-}
-
-// handleLogin handles POST /api/login
-func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.Logger.Error("invalid login request", "error", err)
-		http.Error(w, "invalid request", http.StatusBadRequest)
-		return
-	}
-
-	utils.Logger.Info("login attempt", "username", req.Username)
-
-	// Authenticate user
-	user, err := s.authenticator.Authenticate(req.Username, req.Password)
-	if err != nil {
-		utils.Logger.Warn("authentication failed", "username", req.Username, "error", err)
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
-		return
-	}
-
-	// Generate JWT access token
-	accessToken, expiresAt, err := auth.GenerateToken(user)
-	if err != nil {
-		utils.Logger.Error("failed to generate access token", "error", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	// Generate refresh token
-	userAgent := r.Header.Get("User-Agent")
-	ipAddress := getClientIP(r)
-	refreshToken, err := s.store.CreateRefreshToken(
-		user.Username,
-		userAgent,
-		ipAddress,
-		auth.RefreshTokenDuration,
-	)
-	if err != nil {
-		utils.Logger.Error("failed to generate refresh token", "error", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	utils.Logger.Info("login successful", "username", req.Username, "ip", ipAddress)
-
-	// Calculate expires_in (seconds until expiration)
-	expiresIn := int(time.Until(expiresAt).Seconds())
-
-	// Return tokens (OAuth2-style response)
-	resp := LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    expiresIn,
-		Username:     user.Username,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-}
-
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
-	}
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
-}
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// trustedProxiesEnvVar names the proxies allowed to report the client address in forwarding headers
+const trustedProxiesEnvVar = "ZGATE_TRUSTED_PROXIES"
+
+var (
+	trustedProxyNets   []*net.IPNet
+	trustedProxiesOnce sync.Once
+)
+
+// LoginRequest represents login request payload
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginResponse represents login response payload (OAuth2-style)
+type LoginResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // seconds
+	Username     string `json:"username"`
+}
+
+// handleLogin handles POST /api/login
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Logger.Error("invalid login request", "error", err)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info("login attempt", "username", req.Username)
+
+	// Authenticate user
+	user, err := s.authenticator.Authenticate(req.Username, req.Password)
+	if err != nil {
+		utils.Logger.Warn("authentication failed", "username", req.Username, "error", err)
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	// Generate JWT access token
+	accessToken, expiresAt, err := auth.GenerateToken(user)
+	if err != nil {
+		utils.Logger.Error("failed to generate access token", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Generate refresh token
+	userAgent := r.Header.Get("User-Agent")
+	ipAddress := getClientIP(r)
+	refreshToken, err := s.store.CreateRefreshToken(
+		user.Username,
+		userAgent,
+		ipAddress,
+		auth.RefreshTokenDuration,
+	)
+	if err != nil {
+		utils.Logger.Error("failed to generate refresh token", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.Logger.Info("login successful", "username", req.Username, "ip", ipAddress)
+
+	// Calculate expires_in (seconds until expiration)
+	expiresIn := int(time.Until(expiresAt).Seconds())
+
+	// Return tokens (OAuth2-style response)
+	resp := LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    expiresIn,
+		Username:     user.Username,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// getClientIP returns the address of the client that sent r, as clientIP finds it,
+// for logs and refresh token records
+func getClientIP(r *http.Request) string {
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// clientIP returns the address of the client that sent r. It returns nil when the
+// address cannot be parsed.
+func clientIP(r *http.Request) net.IP {
+	return forwardedClientIP(r, trustedProxies())
+}
+
+// forwardedClientIP returns the peer address of r, unless the peer is one of the
+// trusted proxies: then X-Forwarded-For is read from the right, past the trusted
+// proxies that appended to it, and the first other address is the client's.
+// X-Real-IP is used when a trusted proxy sent no X-Forwarded-For. Headers from
+// any other peer are ignored, as clients can set them to anything.
+func forwardedClientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	peer := parseIP(r.RemoteAddr)
+	if peer == nil || !containsIP(trusted, peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) == 0 {
+		if xri := r.Header.Get("X-Real-IP"); xri != "" {
+			return parseIP(xri)
+		}
+		return peer
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		// The first address that is not a trusted proxy is the client; entries left of it may be forged
+		if client = parseIP(hops[i]); client == nil || !containsIP(trusted, client) {
+			return client
+		}
+	}
+	return client
+}
+
+// parseIP parses an address with or without a port
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+// trustedProxies returns the networks listed in ZGATE_TRUSTED_PROXIES, comma-separated
+// CIDRs or single addresses of the proxies whose forwarding headers are believed.
+// Entries that do not parse are logged and left out.
+func trustedProxies() []*net.IPNet {
+	trustedProxiesOnce.Do(func() {
+		for _, entry := range strings.Split(os.Getenv(trustedProxiesEnvVar), ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			if !strings.Contains(entry, "/") {
+				if ip := net.ParseIP(entry); ip != nil {
+					bits := 8 * len(ip.To16())
+					if ip.To4() != nil {
+						ip, bits = ip.To4(), 32
+					}
+					trustedProxyNets = append(trustedProxyNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+					continue
+				}
+			}
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				utils.Logger.Warn("ignoring invalid trusted proxy", "entry", entry, "error", err)
+				continue
+			}
+			trustedProxyNets = append(trustedProxyNets, network)
+		}
+	})
+	return trustedProxyNets
+}
+
+// containsIP reports whether ip is in any of networks
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestContext returns what policy conditions are evaluated against for r
+func requestContext(r *http.Request) policy.RequestContext {
+	return policy.RequestContext{ClientIP: clientIP(r), Time: time.Now()}
+}
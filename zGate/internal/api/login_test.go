@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/24")
+	trusted := []*net.IPNet{proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "203.0.113.5:51000", nil, "", "203.0.113.5"},
+		{"headers from untrusted peer are ignored", "203.0.113.5:51000", []string{"10.9.9.9"}, "10.9.9.9", "203.0.113.5"},
+		{"trusted proxy without headers", "10.0.0.2:443", nil, "", "10.0.0.2"},
+		{"trusted proxy forwarding", "10.0.0.2:443", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"spoofed entries left of the client", "10.0.0.2:443", []string{"192.168.1.1, 198.51.100.7"}, "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.2:443", []string{"198.51.100.7, 10.0.0.3"}, "", "198.51.100.7"},
+		{"repeated headers", "10.0.0.2:443", []string{"192.168.1.1", "198.51.100.7, 10.0.0.3"}, "", "198.51.100.7"},
+		{"all entries trusted", "10.0.0.2:443", []string{"10.0.0.4, 10.0.0.3"}, "", "10.0.0.4"},
+		{"unparseable entry", "10.0.0.2:443", []string{"198.51.100.7, bogus"}, "", ""},
+		{"real IP from trusted proxy", "10.0.0.2:443", nil, "198.51.100.7", "198.51.100.7"},
+		{"forwarded-for wins over real IP", "10.0.0.2:443", []string{"198.51.100.7"}, "192.168.1.1", "198.51.100.7"},
+		{"IPv6 peer", "[2001:db8::1]:51000", []string{"10.9.9.9"}, "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/databases", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			got := forwardedClientIP(r, trusted)
+			if want := net.ParseIP(tt.want); !got.Equal(want) {
+				t.Fatalf("forwardedClientIP = %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/alert"
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// errNotOwner is returned when a user acts on a database they do not own
+var errNotOwner = errors.New("only the database's owners may do this")
+
+// OwnedDatabaseResponse represents a database as its owners see it
+// Owner webhooks are left out; they may embed credentials and only admins manage them
+type OwnedDatabaseResponse struct {
+	Name                 string               `json:"name"`
+	Type                 string               `json:"type"`
+	Description          string               `json:"description"`
+	AvailablePermissions []string             `json:"available_permissions"`
+	Owners               store.DatabaseOwners `json:"owners"`
+}
+
+// OwnedRoleResponse represents a role with only its permissions on the caller's databases
+type OwnedRoleResponse struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Permissions []store.Permission `json:"permissions"`
+}
+
+// handleListOwnedDatabases handles GET /api/owner/databases
+// It lists the databases the caller owns, directly or through a group
+func (s *Server) handleListOwnedDatabases(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+
+	databases, err := s.ownedDatabases(claims.Username)
+	if err != nil {
+		writeAdminError(w, "list owned databases", err)
+		return
+	}
+
+	resp := make([]OwnedDatabaseResponse, 0, len(databases))
+	for _, db := range databases {
+		resp = append(resp, OwnedDatabaseResponse{
+			Name:                 db.Name,
+			Type:                 db.Type,
+			Description:          db.Description,
+			AvailablePermissions: db.AvailablePermissions,
+			Owners:               nonNilOwners(store.DatabaseOwners{Users: db.Owners.Users, Groups: db.Owners.Groups}),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleListOwnedConnections handles GET /api/owner/databases/{name}/connections
+// It lists who connected to an owned database, at what level and as which temp user;
+// the optional username query parameter narrows the list to one user
+func (s *Server) handleListOwnedConnections(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	name := mux.Vars(r)["name"]
+
+	if _, err := s.ownedDatabase(claims.Username, name); err != nil {
+		writeAdminError(w, "list connections", err)
+		return
+	}
+
+	connections, err := s.store.ListConnections(store.ConnectionFilter{
+		Database: name,
+		Username: r.URL.Query().Get("username"),
+	})
+	if err != nil {
+		writeAdminError(w, "list connections", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, connections)
+}
+
+// handleListOwnedStatements handles GET /api/owner/databases/{name}/connections/{id}/statements
+// It lists what a connection to an owned database ran, as far as the proxy could read it;
+// the connection's statements_note says why any statements are missing
+func (s *Server) handleListOwnedStatements(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	name := mux.Vars(r)["name"]
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid connection ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.ownedDatabase(claims.Username, name); err != nil {
+		writeAdminError(w, "list statements", err)
+		return
+	}
+	conn, err := s.store.GetConnection(id)
+	if err != nil {
+		writeAdminError(w, "list statements", err)
+		return
+	}
+	if conn.Database != name {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	statements, err := s.store.ListStatements(id)
+	if err != nil {
+		writeAdminError(w, "list statements", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statements)
+}
+
+// handleListOwnedAccessRequests handles GET /api/owner/access-requests
+// Optional database and status query parameters filter the requests for the caller's databases
+func (s *Server) handleListOwnedAccessRequests(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	query := r.URL.Query()
+
+	databases, err := s.ownedDatabases(claims.Username)
+	if err != nil {
+		writeAdminError(w, "list access requests", err)
+		return
+	}
+
+	requests, err := s.store.ListAccessRequests(store.AccessRequestFilter{
+		Database: query.Get("database"),
+		Status:   query.Get("status"),
+	})
+	if err != nil {
+		writeAdminError(w, "list access requests", err)
+		return
+	}
+	requests = slices.DeleteFunc(requests, func(req store.AccessRequest) bool {
+		return !slices.ContainsFunc(databases, func(db store.Database) bool { return db.Name == req.Database })
+	})
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// handleOwnerApproveAccessRequest handles POST /api/owner/access-requests/{id}/approve
+func (s *Server) handleOwnerApproveAccessRequest(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	s.decideAccessRequest(w, r, true, claims.Username, s.ownerMayDecide(claims.Username))
+}
+
+// handleOwnerDenyAccessRequest handles POST /api/owner/access-requests/{id}/deny
+func (s *Server) handleOwnerDenyAccessRequest(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	s.decideAccessRequest(w, r, false, claims.Username, s.ownerMayDecide(claims.Username))
+}
+
+// ownerMayDecide lets username decide requests for databases they own, except their own requests
+func (s *Server) ownerMayDecide(username string) func(*store.AccessRequest) error {
+	return func(req *store.AccessRequest) error {
+		if _, err := s.ownedDatabase(username, req.Database); err != nil {
+			return err
+		}
+		if req.Username == username {
+			return fmt.Errorf("%w: owners cannot decide their own requests", errNotOwner)
+		}
+		return nil
+	}
+}
+
+// handleListOwnedRoles handles GET /api/owner/roles
+// Every role is listed so owners can grant it access, but only with its permissions on their databases
+func (s *Server) handleListOwnedRoles(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+
+	databases, err := s.ownedDatabases(claims.Username)
+	if err != nil {
+		writeAdminError(w, "list roles", err)
+		return
+	}
+	roles, err := s.store.ListRoles()
+	if err != nil {
+		writeAdminError(w, "list roles", err)
+		return
+	}
+
+	resp := make([]OwnedRoleResponse, 0, len(roles))
+	for _, role := range roles {
+		perms := slices.DeleteFunc(slices.Clone(role.Permissions), func(perm store.Permission) bool {
+			return !slices.ContainsFunc(databases, func(db store.Database) bool { return db.Name == perm.Database })
+		})
+		resp = append(resp, OwnedRoleResponse{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: nonNilPermissions(perms),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSetOwnedRolePermission handles PUT /api/owner/roles/{name}/permissions/{database}
+// It replaces the role's permission on one owned database, leaving its other permissions alone
+func (s *Server) handleSetOwnedRolePermission(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var perm store.Permission
+	if err := json.NewDecoder(r.Body).Decode(&perm); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if perm.Database != "" && perm.Database != vars["database"] {
+		http.Error(w, "database does not match the URL", http.StatusBadRequest)
+		return
+	}
+	perm.Database = vars["database"]
+
+	s.updateOwnedRolePermission(w, r, &perm)
+}
+
+// handleDeleteOwnedRolePermission handles DELETE /api/owner/roles/{name}/permissions/{database}
+func (s *Server) handleDeleteOwnedRolePermission(w http.ResponseWriter, r *http.Request) {
+	s.updateOwnedRolePermission(w, r, nil)
+}
+
+// updateOwnedRolePermission sets, or removes when perm is nil, a role's permission on
+// the database named in the URL, once the caller is confirmed as one of its owners
+func (s *Server) updateOwnedRolePermission(w http.ResponseWriter, r *http.Request, perm *store.Permission) {
+	claims := r.Context().Value("claims").(*auth.Claims)
+	vars := mux.Vars(r)
+
+	db, err := s.ownedDatabase(claims.Username, vars["database"])
+	if err != nil {
+		writeAdminError(w, "update role permission", err)
+		return
+	}
+	role, err := s.store.GetRole(vars["name"])
+	if err != nil {
+		writeAdminError(w, "update role permission", err)
+		return
+	}
+
+	before := slices.Clone(role.Permissions)
+	found := slices.ContainsFunc(role.Permissions, func(p store.Permission) bool { return p.Database == db.Name })
+	role.Permissions = slices.DeleteFunc(role.Permissions, func(p store.Permission) bool { return p.Database == db.Name })
+	if perm != nil {
+		role.Permissions = append(role.Permissions, *perm)
+	} else if !found {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.validatePermissions(role.Permissions); err != nil {
+		writeAdminError(w, "update role permission", err)
+		return
+	}
+	if err := s.store.SaveRole(role); err != nil {
+		writeAdminError(w, "update role permission", err)
+		return
+	}
+
+	utils.Logger.Info("role permission updated by owner", "owner", claims.Username, "role", role.Name,
+		"database", db.Name, "removed", perm == nil)
+	s.notifyNewGrants(before, role.Permissions, policy.RoleSource(role.Name), claims.Username)
+
+	saved, err := s.store.GetRole(role.Name)
+	if err != nil {
+		writeAdminError(w, "update role permission", err)
+		return
+	}
+	saved.Permissions = slices.DeleteFunc(saved.Permissions, func(p store.Permission) bool { return p.Database != db.Name })
+	writeJSON(w, http.StatusOK, OwnedRoleResponse{
+		Name:        saved.Name,
+		Description: saved.Description,
+		Permissions: nonNilPermissions(saved.Permissions),
+	})
+}
+
+// ownedDatabases returns the databases username owns, directly or through a group
+func (s *Server) ownedDatabases(username string) ([]store.Database, error) {
+	groups, err := s.store.GetGroupsForUser(username)
+	if err != nil {
+		return nil, err
+	}
+	databases, err := s.store.ListDatabases()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(databases, func(db store.Database) bool {
+		return !db.Owners.Includes(username, groups)
+	}), nil
+}
+
+// ownedDatabase loads a database, failing with errNotOwner unless username owns it
+func (s *Server) ownedDatabase(username, name string) (*store.Database, error) {
+	db, err := s.store.GetDatabase(name)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.store.GetGroupsForUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if !db.Owners.Includes(username, groups) {
+		return nil, errNotOwner
+	}
+	return db, nil
+}
+
+// notifyNewGrants tells owners about allow entries in after that grantee did not hold
+// before, or held at a different level. Each goes to the owner webhooks of its database.
+func (s *Server) notifyNewGrants(before, after []store.Permission, grantee, grantedBy string) {
+	for _, perm := range after {
+		if perm.Deny {
+			continue
+		}
+		i := slices.IndexFunc(before, func(p store.Permission) bool { return p.Database == perm.Database })
+		if i >= 0 && !before[i].Deny && before[i].Level == perm.Level && before[i].ElevateTo == perm.ElevateTo {
+			continue
+		}
+		s.notifyOwners(perm.Database, grantee, perm.Level, grantedBy, map[string]any{
+			"elevate_to":  perm.ElevateTo,
+			"valid_from":  perm.ValidFrom,
+			"valid_until": perm.ValidUntil,
+		})
+	}
+}
+
+// notifyOwners posts a new grant on database to its owner webhooks
+func (s *Server) notifyOwners(database, grantee, level, grantedBy string, extra map[string]any) {
+	db, err := s.store.GetDatabase(database)
+	if err != nil {
+		utils.Logger.Warn("failed to load database for owner notification", "database", database, "error", err)
+		return
+	}
+	if len(db.Owners.Webhooks) == 0 {
+		return
+	}
+
+	details := map[string]any{
+		"database":   db.Name,
+		"grantee":    grantee,
+		"level":      level,
+		"granted_by": grantedBy,
+	}
+	for key, value := range extra {
+		details[key] = value
+	}
+	alert.SendTo(db.Owners.Webhooks, alert.Event{
+		Kind:    "grant_added",
+		Text:    fmt.Sprintf("%s granted %s on %s to %s", grantedBy, level, db.Name, grantee),
+		Details: details,
+		Time:    time.Now().UTC(),
+	})
+}
@@ -1,109 +1,160 @@
-package api
-
-import (
-	"context"
-	"fmt"
-	"net/http"
-	"time"
-
-	"github.com/gorilla/mux"
-	"github.com/zGate-Team/zGate-Platform/internal/auth"
-	"github.com/zGate-Team/zGate-Platform/internal/gateway"
-	"github.com/zGate-Team/zGate-Platform/internal/policy"
-	"github.com/zGate-Team/zGate-Platform/internal/proxy"
-	"github.com/zGate-Team/zGate-Platform/internal/store"
-	"github.com/zGate-Team/zGate-Platform/internal/utils"
-)
-
-// Server is the API server for CLI communication
-type Server struct {
-	addr          string
-	server        *http.Server
-	authenticator *auth.Authenticator
-	policyEngine  *policy.Engine
-	proxyManager  *proxy.Manager
-	store         *store.Store
-}
-
-// NewServer creates a new API server
-func NewServer(addr string, store *store.Store) (*Server, error) {
-	// Initialize authenticator
-	authenticator := auth.NewAuthenticator(store)
-
-	// Initialize policy engine
-	policyEngine := policy.NewEngine(store)
-
-	// Initialize gateway server (for handlers)
-	gwServer, err := gateway.NewServer(store)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize gateway server: %w", err)
-	}
-
-	// Initialize proxy manager
-	proxyManager := proxy.NewManager(store, gwServer)
-
-	s := &Server{
-		addr:          addr,
-		authenticator: authenticator,
-		policyEngine:  policyEngine,
-		proxyManager:  proxyManager,
-		store:         store,
-	}
-
-	// Setup routes
-	router := mux.NewRouter()
-
-	// Public routes (no authentication required)
-	router.HandleFunc("/api/login", s.handleLogin).Methods("POST")
-	router.HandleFunc("/api/refresh", s.handleRefresh).Methods("POST")
-	router.HandleFunc("/api/logout", s.handleLogout).Methods("POST")
-
-	// Protected routes (authentication required)
-	router.HandleFunc("/api/active-logins", s.authMiddleware(s.handleListActiveLogins)).Methods("GET")
-	router.HandleFunc("/api/active-logins/{id}", s.authMiddleware(s.handleRevokeActiveLogin)).Methods("DELETE")
-	router.HandleFunc("/api/databases", s.authMiddleware(s.handleListDatabases)).Methods("GET")
-	router.HandleFunc("/api/connect", s.authMiddleware(s.handleConnect)).Methods("POST")
-	router.HandleFunc("/api/disconnect", s.authMiddleware(s.handleDisconnect)).Methods("POST")
-
-	// Admin routes
-	router.HandleFunc("/api/admin/login", s.handleAdminLogin).Methods("GET")
-	// TODO: Implement admin authentication and uncomment these routes
-	// router.HandleFunc("/api/admin/users", s.authMiddleware(s.handleListUsers)).Methods("GET")
-	// router.HandleFunc("/api/admin/users/{id}", s.authMiddleware(s.handleUpdateUser)).Methods("PUT")
-	// router.HandleFunc("/api/admin/users/{id}", s.authMiddleware(s.handleCreateUser)).Methods("POST")
-	// router.HandleFunc("/api/admin/users/{id}", s.authMiddleware(s.handleRevokeUser)).Methods("DELETE")
-	// router.HandleFunc("/api/admin/roles", s.authMiddleware(s.handleListRoles)).Methods("GET")
-	// router.HandleFunc("/api/admin/roles/{id}", s.authMiddleware(s.handleUpdateRole)).Methods("PUT")
-	// router.HandleFunc("/api/admin/roles/{id}", s.authMiddleware(s.handleCreateRole)).Methods("POST")
-	// router.HandleFunc("/api/admin/roles/{id}", s.authMiddleware(s.handleRevokeRole)).Methods("DELETE")
-	// router.HandleFunc("/api/admin/databases", s.authMiddleware(s.handleListDatabases)).Methods("GET")
-	// router.HandleFunc("/api/admin/databases/{id}", s.authMiddleware(s.handleUpdateDatabase)).Methods("PUT")
-	// router.HandleFunc("/api/admin/databases/{id}", s.authMiddleware(s.handleCreateDatabase)).Methods("POST")
-	// router.HandleFunc("/api/admin/databases/{id}", s.authMiddleware(s.handleRevokeDatabase)).Methods("DELETE")
-	// router.HandleFunc("/api/admin/active-logins", s.authMiddleware(s.handleListActiveLogins)).Methods("GET")
-	// router.HandleFunc("/api/admin/active-logins/{id}", s.authMiddleware(s.handleRevokeActiveLogin)).Methods("DELETE")
-
-	s.server = &http.Server{
-		Addr:         addr,
-		Handler:      router,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-	}
-
-	return s, nil
-}
-
-// Start starts the API server
-func (s *Server) Start() error {
-	utils.Logger.Info("API server starting", "addr", s.addr)
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("API server failed: %w", err)
-	}
-	return nil
-}
-
-// Shutdown gracefully stops the API server
-func (s *Server) Shutdown(ctx context.Context) error {
-	utils.Logger.Info("shutting down API server")
-	return s.server.Shutdown(ctx)
-}
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/gateway"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/proxy"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// Server is the API server for CLI communication
+type Server struct {
+	addr          string
+	server        *http.Server
+	authenticator *auth.Authenticator
+	policyEngine  *policy.Engine
+	proxyManager  *proxy.Manager
+	store         *store.Store
+}
+
+// NewServer creates a new API server
+func NewServer(addr string, store *store.Store) (*Server, error) {
+	// Initialize authenticator
+	authenticator := auth.NewAuthenticator(store)
+
+	// Initialize policy engine
+	policyEngine := policy.NewEngine(store)
+
+	// Initialize gateway server (for handlers)
+	gwServer, err := gateway.NewServer(store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize gateway server: %w", err)
+	}
+
+	// Initialize proxy manager
+	proxyManager := proxy.NewManager(store, gwServer)
+
+	s := &Server{
+		addr:          addr,
+		authenticator: authenticator,
+		policyEngine:  policyEngine,
+		proxyManager:  proxyManager,
+		store:         store,
+	}
+
+	// Setup routes
+	router := mux.NewRouter()
+
+	// Public routes (no authentication required)
+	router.HandleFunc("/api/login", s.handleLogin).Methods("POST")
+	router.HandleFunc("/api/refresh", s.handleRefresh).Methods("POST")
+	router.HandleFunc("/api/logout", s.handleLogout).Methods("POST")
+
+	// Protected routes (authentication required)
+	router.HandleFunc("/api/active-logins", s.authMiddleware(s.handleListActiveLogins)).Methods("GET")
+	router.HandleFunc("/api/active-logins/{id}", s.authMiddleware(s.handleRevokeActiveLogin)).Methods("DELETE")
+	router.HandleFunc("/api/databases", s.authMiddleware(s.handleListDatabases)).Methods("GET")
+	router.HandleFunc("/api/connect", s.authMiddleware(s.handleConnect)).Methods("POST")
+	router.HandleFunc("/api/disconnect", s.authMiddleware(s.handleDisconnect)).Methods("POST")
+	router.HandleFunc("/api/elevate", s.authMiddleware(s.handleElevate)).Methods("POST")
+	router.HandleFunc("/api/elevate", s.authMiddleware(s.handleEndElevation)).Methods("DELETE")
+	router.HandleFunc("/api/access-requests", s.authMiddleware(s.handleListMyAccessRequests)).Methods("GET")
+	router.HandleFunc("/api/access-requests", s.authMiddleware(s.handleCreateAccessRequest)).Methods("POST")
+	router.HandleFunc("/api/access-requests/{id}", s.authMiddleware(s.handleCancelAccessRequest)).Methods("DELETE")
+
+	// Owner routes: authenticated users acting on the databases they own
+	router.HandleFunc("/api/owner/databases", s.authMiddleware(s.handleListOwnedDatabases)).Methods("GET")
+	router.HandleFunc("/api/owner/databases/{name}/connections", s.authMiddleware(s.handleListOwnedConnections)).Methods("GET")
+	router.HandleFunc("/api/owner/databases/{name}/connections/{id}/statements", s.authMiddleware(s.handleListOwnedStatements)).Methods("GET")
+	router.HandleFunc("/api/owner/access-requests", s.authMiddleware(s.handleListOwnedAccessRequests)).Methods("GET")
+	router.HandleFunc("/api/owner/access-requests/{id}/approve", s.authMiddleware(s.handleOwnerApproveAccessRequest)).Methods("POST")
+	router.HandleFunc("/api/owner/access-requests/{id}/deny", s.authMiddleware(s.handleOwnerDenyAccessRequest)).Methods("POST")
+	router.HandleFunc("/api/owner/roles", s.authMiddleware(s.handleListOwnedRoles)).Methods("GET")
+	router.HandleFunc("/api/owner/roles/{name}/permissions/{database}", s.authMiddleware(s.handleSetOwnedRolePermission)).Methods("PUT")
+	router.HandleFunc("/api/owner/roles/{name}/permissions/{database}", s.authMiddleware(s.handleDeleteOwnedRolePermission)).Methods("DELETE")
+
+	// Admin routes
+	router.HandleFunc("/api/admin/login", s.handleAdminLogin).Methods("POST")
+	router.HandleFunc("/api/admin/users", s.adminMiddleware(s.handleListUsers)).Methods("GET")
+	router.HandleFunc("/api/admin/users", s.adminMiddleware(s.handleCreateUser)).Methods("POST")
+	router.HandleFunc("/api/admin/users/{username}", s.adminMiddleware(s.handleGetUser)).Methods("GET")
+	router.HandleFunc("/api/admin/users/{username}", s.adminMiddleware(s.handleUpdateUser)).Methods("PUT")
+	router.HandleFunc("/api/admin/users/{username}", s.adminMiddleware(s.handleRevokeUser)).Methods("DELETE")
+	router.HandleFunc("/api/admin/users/{username}/explain", s.adminMiddleware(s.handleExplainUser)).Methods("GET")
+	router.HandleFunc("/api/admin/users/{username}/simulate", s.adminMiddleware(s.handleSimulateUser)).Methods("POST")
+	router.HandleFunc("/api/admin/elevations", s.adminMiddleware(s.handleListElevations)).Methods("GET")
+	router.HandleFunc("/api/admin/break-glass", s.adminMiddleware(s.handleListBreakGlassSessions)).Methods("GET")
+	router.HandleFunc("/api/admin/break-glass/{id}/review", s.adminMiddleware(s.handleReviewBreakGlassSession)).Methods("POST")
+	router.HandleFunc("/api/admin/access-requests", s.adminMiddleware(s.handleListAccessRequests)).Methods("GET")
+	router.HandleFunc("/api/admin/access-requests/{id}/approve", s.adminMiddleware(s.handleApproveAccessRequest)).Methods("POST")
+	router.HandleFunc("/api/admin/access-requests/{id}/deny", s.adminMiddleware(s.handleDenyAccessRequest)).Methods("POST")
+	router.HandleFunc("/api/admin/access-requests/{id}/revoke", s.adminMiddleware(s.handleRevokeAccessRequest)).Methods("POST")
+	router.HandleFunc("/api/admin/policies", s.adminMiddleware(s.handleListPolicies)).Methods("GET")
+	router.HandleFunc("/api/admin/policies", s.adminMiddleware(s.handleCreatePolicy)).Methods("POST")
+	router.HandleFunc("/api/admin/policies/dry-run", s.adminMiddleware(s.handlePolicyDryRun)).Methods("POST")
+	router.HandleFunc("/api/admin/policies/{name}", s.adminMiddleware(s.handleGetPolicy)).Methods("GET")
+	router.HandleFunc("/api/admin/policies/{name}", s.adminMiddleware(s.handleUpdatePolicy)).Methods("PUT")
+	router.HandleFunc("/api/admin/policies/{name}", s.adminMiddleware(s.handleDeletePolicy)).Methods("DELETE")
+	router.HandleFunc("/api/admin/policies/{name}/versions", s.adminMiddleware(s.handleListPolicyVersions)).Methods("GET")
+	router.HandleFunc("/api/admin/policies/{name}/rollback", s.adminMiddleware(s.handleRollbackPolicy)).Methods("POST")
+	router.HandleFunc("/api/admin/sod-rules", s.adminMiddleware(s.handleListSoDRules)).Methods("GET")
+	router.HandleFunc("/api/admin/sod-rules", s.adminMiddleware(s.handleCreateSoDRule)).Methods("POST")
+	router.HandleFunc("/api/admin/sod-rules/{name}", s.adminMiddleware(s.handleGetSoDRule)).Methods("GET")
+	router.HandleFunc("/api/admin/sod-rules/{name}", s.adminMiddleware(s.handleUpdateSoDRule)).Methods("PUT")
+	router.HandleFunc("/api/admin/sod-rules/{name}", s.adminMiddleware(s.handleDeleteSoDRule)).Methods("DELETE")
+	router.HandleFunc("/api/admin/sod-violations", s.adminMiddleware(s.handleListSoDViolations)).Methods("GET")
+	router.HandleFunc("/api/admin/groups", s.adminMiddleware(s.handleListGroups)).Methods("GET")
+	router.HandleFunc("/api/admin/groups", s.adminMiddleware(s.handleCreateGroup)).Methods("POST")
+	router.HandleFunc("/api/admin/groups/{name}", s.adminMiddleware(s.handleGetGroup)).Methods("GET")
+	router.HandleFunc("/api/admin/groups/{name}", s.adminMiddleware(s.handleUpdateGroup)).Methods("PUT")
+	router.HandleFunc("/api/admin/groups/{name}", s.adminMiddleware(s.handleRevokeGroup)).Methods("DELETE")
+	router.HandleFunc("/api/admin/groups/{name}/members/{username}", s.adminMiddleware(s.handleAddGroupMember)).Methods("PUT")
+	router.HandleFunc("/api/admin/groups/{name}/members/{username}", s.adminMiddleware(s.handleRemoveGroupMember)).Methods("DELETE")
+	router.HandleFunc("/api/admin/roles", s.adminMiddleware(s.handleListRoles)).Methods("GET")
+	router.HandleFunc("/api/admin/roles", s.adminMiddleware(s.handleCreateRole)).Methods("POST")
+	router.HandleFunc("/api/admin/roles/{name}", s.adminMiddleware(s.handleGetRole)).Methods("GET")
+	router.HandleFunc("/api/admin/roles/{name}", s.adminMiddleware(s.handleUpdateRole)).Methods("PUT")
+	router.HandleFunc("/api/admin/roles/{name}", s.adminMiddleware(s.handleRevokeRole)).Methods("DELETE")
+	router.HandleFunc("/api/admin/databases", s.adminMiddleware(s.handleAdminListDatabases)).Methods("GET")
+	router.HandleFunc("/api/admin/databases", s.adminMiddleware(s.handleCreateDatabase)).Methods("POST")
+	router.HandleFunc("/api/admin/databases/test", s.adminMiddleware(s.handleTestDatabaseDefinition)).Methods("POST")
+	router.HandleFunc("/api/admin/databases/{name}/test", s.adminMiddleware(s.handleTestDatabase)).Methods("POST")
+	router.HandleFunc("/api/admin/databases/{name}/levels", s.adminMiddleware(s.handleListPermissionLevels)).Methods("GET")
+	router.HandleFunc("/api/admin/databases/{name}/levels/{level}", s.adminMiddleware(s.handleSavePermissionLevel)).Methods("PUT")
+	router.HandleFunc("/api/admin/databases/{name}/levels/{level}", s.adminMiddleware(s.handleDeletePermissionLevel)).Methods("DELETE")
+	router.HandleFunc("/api/admin/databases/{name}", s.adminMiddleware(s.handleAdminGetDatabase)).Methods("GET")
+	router.HandleFunc("/api/admin/databases/{name}", s.adminMiddleware(s.handleUpdateDatabase)).Methods("PUT")
+	router.HandleFunc("/api/admin/databases/{name}", s.adminMiddleware(s.handleRevokeDatabase)).Methods("DELETE")
+
+	s.server = &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	return s, nil
+}
+
+// Start starts the API server
+func (s *Server) Start() error {
+	utils.Logger.Info("API server starting", "addr", s.addr)
+	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("API server failed: %w", err)
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the API server
+func (s *Server) Shutdown(ctx context.Context) error {
+	utils.Logger.Info("shutting down API server")
+	return s.server.Shutdown(ctx)
+}
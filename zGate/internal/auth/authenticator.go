@@ -1,49 +1,70 @@
-package auth
-
-import (
-	"fmt"
-
-	"github.com/zGate-Team/zGate-Platform/internal/store"
-)
-
-// Authenticator handles user authentication
-type Authenticator struct {
-	store *store.Store
-}
-
-// NewAuthenticator creates a new authenticator
-func NewAuthenticator(store *store.Store) *Authenticator {
-	return &Authenticator{store: store}
-}
-
-// Authenticate validates username and password, returns user with resolved permissions
-func (a *Authenticator) Authenticate(username, password string) (*UserWithPermissions, error) {
-	if err := a.store.VerifyPassword(username, password); err != nil {
-		return nil, fmt.Errorf("authentication failed: invalid credentials")
-	}
-
-	user, err := a.store.GetUser(username)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load user: %w", err)
-	}
-
-	rolePerms, err := a.store.GetPermissionsForRoles(user.Roles)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve role permissions: %w", err)
-	}
-
-	permissions := append(rolePerms, user.CustomPermissions...)
-
-	return &UserWithPermissions{
-		Username:    user.Username,
-		Roles:       user.Roles,
-		Permissions: permissions,
-	}, nil
-}
-
-// UserWithPermissions represents a user with resolved permissions
-type UserWithPermissions struct {
-	Username    string
-	Roles       []string
-	Permissions []store.Permission
-}
+package auth
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// Authenticator handles user authentication
+type Authenticator struct {
+	store *store.Store
+}
+
+// NewAuthenticator creates a new authenticator
+func NewAuthenticator(store *store.Store) *Authenticator {
+	return &Authenticator{store: store}
+}
+
+// Authenticate validates username and password, returns user with resolved permissions
+func (a *Authenticator) Authenticate(username, password string) (*UserWithPermissions, error) {
+	if err := a.store.VerifyPassword(username, password); err != nil {
+		return nil, fmt.Errorf("authentication failed: invalid credentials")
+	}
+
+	user, err := a.store.GetUser(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	roles, err := UserRoles(a.store, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve group roles: %w", err)
+	}
+
+	rolePerms, err := a.store.GetPermissionsForRoles(roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve role permissions: %w", err)
+	}
+
+	permissions := append(rolePerms, user.CustomPermissions...)
+
+	return &UserWithPermissions{
+		Username:    user.Username,
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+// UserRoles returns the roles assigned to a user directly, then those held through groups
+func UserRoles(s *store.Store, user *store.User) ([]string, error) {
+	groupRoles, err := s.GetGroupRolesForUser(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	roles := slices.Clone(user.Roles)
+	for _, role := range groupRoles {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// UserWithPermissions represents a user with resolved permissions
+type UserWithPermissions struct {
+	Username    string
+	Roles       []string
+	Permissions []store.Permission
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // condition timezones must resolve on hosts without a zoneinfo database
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// RequestContext is what conditions and policies are evaluated against
+type RequestContext struct {
+	ClientIP net.IP    // nil when unknown; CIDR conditions then fail
+	Time     time.Time // zero means now
+	// BreakGlass is set for emergency sessions opened without a grant
+	BreakGlass bool
+}
+
+func (rc RequestContext) now() time.Time {
+	if rc.Time.IsZero() {
+		return time.Now()
+	}
+	return rc.Time
+}
+
+// weekdays lists the accepted weekday names, indexed by time.Weekday
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// clockLayout is the format of condition start and end times
+const clockLayout = "15:04"
+
+// ValidateConditions checks that conditions can be evaluated
+func ValidateConditions(c *store.Conditions) error {
+	if c == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", c.Timezone)
+	}
+	for _, day := range c.Weekdays {
+		if !slices.Contains(weekdays, day) {
+			return fmt.Errorf("unknown weekday %q (use %s)", day, strings.Join(weekdays, ", "))
+		}
+	}
+	if (c.StartTime == "") != (c.EndTime == "") {
+		return fmt.Errorf("start_time and end_time must be set together")
+	}
+	if c.StartTime != "" {
+		start, err := time.Parse(clockLayout, c.StartTime)
+		if err != nil {
+			return fmt.Errorf("start_time must be HH:MM")
+		}
+		end, err := time.Parse(clockLayout, c.EndTime)
+		if err != nil {
+			return fmt.Errorf("end_time must be HH:MM")
+		}
+		if start.Equal(end) {
+			return fmt.Errorf("start_time and end_time must differ")
+		}
+	}
+	for _, cidr := range c.SourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid source CIDR %q", cidr)
+		}
+	}
+	return nil
+}
+
+// checkConditions reports whether rc satisfies c, and if not, why.
+// Conditions are expected to have passed ValidateConditions.
+func checkConditions(c *store.Conditions, rc RequestContext) (bool, string) {
+	if c == nil {
+		return true, ""
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return false, fmt.Sprintf("unknown timezone %q", c.Timezone)
+	}
+	now := rc.now().In(loc)
+
+	// The weekday is the one a time window opened on, so a window that wraps past
+	// midnight still applies after midnight following an allowed day
+	day := now.Weekday()
+	inside := true
+	if c.StartTime != "" {
+		start, _ := time.Parse(clockLayout, c.StartTime)
+		end, _ := time.Parse(clockLayout, c.EndTime)
+		minute := now.Hour()*60 + now.Minute()
+		from := start.Hour()*60 + start.Minute()
+		until := end.Hour()*60 + end.Minute()
+
+		// A window ending before it starts wraps past midnight
+		inside = from <= minute && minute < until
+		if from > until {
+			inside = minute >= from || minute < until
+			if minute < until {
+				day = now.AddDate(0, 0, -1).Weekday()
+			}
+		}
+	}
+
+	if len(c.Weekdays) > 0 && !slices.Contains(c.Weekdays, weekdays[day]) {
+		return false, fmt.Sprintf("allowed only on %s (%s)", strings.Join(c.Weekdays, ", "), loc)
+	}
+	if !inside {
+		return false, fmt.Sprintf("allowed only between %s and %s (%s)", c.StartTime, c.EndTime, loc)
+	}
+
+	if len(c.SourceCIDRs) > 0 {
+		allowed := strings.Join(c.SourceCIDRs, ", ")
+		if rc.ClientIP == nil {
+			return false, "client address unknown; allowed only from " + allowed
+		}
+		inRange := slices.ContainsFunc(c.SourceCIDRs, func(cidr string) bool {
+			_, network, err := net.ParseCIDR(cidr)
+			return err == nil && network.Contains(rc.ClientIP)
+		})
+		if !inRange {
+			return false, fmt.Sprintf("allowed only from %s, not %s", allowed, rc.ClientIP)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// at returns 2026-10-16 (a Friday) plus days, at hh:mm UTC
+func at(days, hour, minute int) time.Time {
+	return time.Date(2026, 10, 16+days, hour, minute, 0, 0, time.UTC)
+}
+
+func TestCheckConditions(t *testing.T) {
+	officeHours := &store.Conditions{StartTime: "09:00", EndTime: "17:00"}
+	weekdaysOnly := &store.Conditions{Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}}
+	berlin := &store.Conditions{Timezone: "Europe/Berlin", StartTime: "09:00", EndTime: "17:00"}
+	// A Friday night shift, ending Saturday morning
+	fridayNight := &store.Conditions{Weekdays: []string{"fri"}, StartTime: "22:00", EndTime: "06:00"}
+	office := &store.Conditions{SourceCIDRs: []string{"10.0.0.0/8", "192.0.2.0/24"}}
+
+	tests := []struct {
+		name       string
+		conditions *store.Conditions
+		time       time.Time
+		ip         string
+		want       bool
+	}{
+		{"no conditions", nil, at(0, 3, 0), "", true},
+
+		{"window start is inclusive", officeHours, at(0, 9, 0), "", true},
+		{"inside window", officeHours, at(0, 12, 30), "", true},
+		{"before window", officeHours, at(0, 8, 59), "", false},
+		{"window end is exclusive", officeHours, at(0, 17, 0), "", false},
+		{"window in timezone", berlin, at(0, 7, 30), "", true},
+		{"before window in timezone", berlin, at(0, 6, 30), "", false},
+		{"after window in timezone", berlin, at(0, 15, 0), "", false},
+
+		{"allowed weekday", weekdaysOnly, at(0, 12, 0), "", true},
+		{"saturday", weekdaysOnly, at(1, 12, 0), "", false},
+		{"sunday", weekdaysOnly, at(2, 12, 0), "", false},
+		{"monday", weekdaysOnly, at(3, 0, 0), "", true},
+
+		{"wrapped window before midnight", fridayNight, at(0, 23, 0), "", true},
+		{"wrapped window after midnight counts for the day it opened", fridayNight, at(1, 2, 0), "", true},
+		{"wrapped window end is exclusive", fridayNight, at(1, 6, 0), "", false},
+		{"wrapped window opened on a day not allowed", fridayNight, at(0, 2, 0), "", false},
+		{"wrapped window on a day not allowed", fridayNight, at(1, 23, 0), "", false},
+		{"between wrapped windows", fridayNight, at(0, 12, 0), "", false},
+
+		{"address in first range", office, at(0, 12, 0), "10.20.30.40", true},
+		{"address in second range", office, at(0, 12, 0), "192.0.2.7", true},
+		{"address outside ranges", office, at(0, 12, 0), "198.51.100.1", false},
+		{"address unknown", office, at(0, 12, 0), "", false},
+		{"IPv6 address", office, at(0, 12, 0), "2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateConditions(tt.conditions); err != nil {
+				t.Fatalf("ValidateConditions: %v", err)
+			}
+			rc := RequestContext{ClientIP: net.ParseIP(tt.ip), Time: tt.time}
+			got, reason := checkConditions(tt.conditions, rc)
+			if got != tt.want {
+				t.Fatalf("checkConditions at %s from %q = %v (%s); want %v", tt.time, tt.ip, got, reason, tt.want)
+			}
+			if !got && reason == "" {
+				t.Fatalf("checkConditions refused without a reason")
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// DeniedError reports that grants or policies refuse a request, as opposed to
+// a failure to load them
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// subject is a user with everything needed to decide their access
+type subject struct {
+	user        *store.User
+	groups      []string // every group the user belongs to, including through nesting
+	assignments []roleAssignment
+	grants      []Grant
+	policies    []store.Policy
+}
+
+// source is what subjects are loaded from: the store, or the store with unsaved changes on top
+type source interface {
+	GetUser(username string) (*store.User, error)
+	GetGroupsForUser(username string) ([]string, error)
+	GetGroup(name string) (*store.Group, error)
+	GetRole(name string) (*store.Role, error)
+	RoleAncestors(name string) ([]string, error)
+	ActiveAccessGrants(username string) ([]store.AccessRequest, error)
+	ListPolicies() ([]store.Policy, error)
+}
+
+// loadSubject reads a user's groups, roles and grants and the enabled policies
+func loadSubject(s source, username string) (*subject, error) {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.GetGroupsForUser(username)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := loadRoleAssignments(s, user, groups)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := userGrants(s, user, assignments)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := enabledPolicies(s)
+	if err != nil {
+		return nil, fmt.Errorf("load policies: %w", err)
+	}
+	return &subject{user: user, groups: groups, assignments: assignments, grants: grants, policies: policies}, nil
+}
+
+// resolve merges the subject's grants for db, then applies the policies
+func (sub *subject) resolve(db *store.Database, rc RequestContext) *Resolution {
+	res := Resolve(db, sub.grants, rc)
+	sub.applyPolicies(db, res, rc)
+	return res
+}
+
+// Evaluate decides a user's access to db from fresh grants and policies.
+// It is what every connect, database listing and live-session sync relies on.
+func Evaluate(s *store.Store, username string, db *store.Database, rc RequestContext) (*Resolution, error) {
+	sub, err := loadSubject(s, username)
+	if err != nil {
+		return nil, err
+	}
+	return sub.resolve(db, rc), nil
+}
+
+// EvaluateElevation checks with fresh grants and policies whether a user's
+// session on db may elevate to level, and returns the permission to grant.
+// Policies see the elevated level as the requested one.
+func EvaluateElevation(s *store.Store, username string, db *store.Database, level string, rc RequestContext) (*store.Permission, error) {
+	sub, err := loadSubject(s, username)
+	if err != nil {
+		return nil, err
+	}
+	if standing := sub.resolve(db, rc); !standing.Allowed {
+		return nil, &DeniedError{Reason: fmt.Sprintf("no access to database %s: %s", db.Name, standing.Reason)}
+	}
+
+	permission, err := ResolveElevation(db, sub.grants, level, rc)
+	if err != nil {
+		return nil, &DeniedError{Reason: err.Error()}
+	}
+
+	input := sub.ruleInput(db, level, rc)
+	for _, p := range sub.policies {
+		if result, reason := checkPolicy(p, input); !result.Allowed {
+			return nil, &DeniedError{Reason: reason}
+		}
+	}
+	return permission, nil
+}
+
+// EvaluateBreakGlass checks with fresh data whether a user may open an emergency
+// session on db without a grant, and returns the permission to grant: the whole
+// of the database's highest-ranked level, which is admin whenever the database
+// offers it. Grants, denies and their conditions are bypassed; only members of
+// one of eligibleGroups, directly or through nesting, qualify. Policies still
+// apply and see request.break_glass set.
+func EvaluateBreakGlass(s *store.Store, username string, db *store.Database, eligibleGroups []string, rc RequestContext) (*store.Permission, error) {
+	sub, err := loadSubject(s, username)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(sub.groups, func(group string) bool { return slices.Contains(eligibleGroups, group) }) {
+		return nil, &DeniedError{Reason: "not a member of a break-glass group"}
+	}
+	highest := db.HighestLevel()
+	if highest == "" {
+		return nil, &DeniedError{Reason: fmt.Sprintf("database %s offers no levels", db.Name)}
+	}
+
+	permission := &store.Permission{Database: db.Name, Level: highest}
+	if level, ok := db.Level(permission.Level); ok {
+		permission.Limits = level.Limits
+	}
+
+	rc.BreakGlass = true
+	input := sub.ruleInput(db, permission.Level, rc)
+	for _, p := range sub.policies {
+		if result, reason := checkPolicy(p, input); !result.Allowed {
+			return nil, &DeniedError{Reason: reason}
+		}
+	}
+	return permission, nil
+}
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// newTestStore returns an empty store in a temporary directory
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.NewStore(filepath.Join(t.TempDir(), "zgate.db"), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestEvaluateBreakGlass(t *testing.T) {
+	s := newTestStore(t)
+	for _, username := range []string{"alice", "bob"} {
+		if err := s.CreateUserWithPassword(&store.User{Username: username}, "secret"); err != nil {
+			t.Fatalf("CreateUserWithPassword(%s): %v", username, err)
+		}
+	}
+	// alice is on call through a group nested in the break-glass group
+	groups := []store.Group{
+		{Name: "responders"},
+		{Name: "sre", Parents: []string{"responders"}, Members: []string{"alice"}},
+	}
+	for i := range groups {
+		if err := s.SaveGroup(&groups[i]); err != nil {
+			t.Fatalf("SaveGroup(%s): %v", groups[i].Name, err)
+		}
+	}
+
+	reporting := store.PermissionLevel{
+		Database:    "orders",
+		Name:        "reporting",
+		NativeRoles: []string{"reporting"},
+		Limits:      &store.ResourceLimits{MaxConnections: 1},
+	}
+	tests := []struct {
+		name   string
+		levels []string
+		want   string
+		limits bool
+	}{
+		{"admin outranks a custom level", []string{"read", "write", "reporting", "admin"}, "admin", false},
+		{"admin outranks a custom level ranked first", []string{"reporting", "read", "admin"}, "admin", false},
+		{"highest level without admin", []string{"read", "write"}, "write", false},
+		{"custom level as the highest carries its limits", []string{"read", "reporting"}, "reporting", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &store.Database{Name: "orders", Type: "mysql", AvailablePermissions: tt.levels, Levels: []store.PermissionLevel{reporting}}
+			permission, err := EvaluateBreakGlass(s, "alice", db, []string{"responders"}, RequestContext{})
+			if err != nil {
+				t.Fatalf("EvaluateBreakGlass: %v", err)
+			}
+			if permission.Level != tt.want || len(permission.Objects) != 0 {
+				t.Fatalf("EvaluateBreakGlass = %+v; want the whole of %q", permission, tt.want)
+			}
+			if (permission.Limits != nil) != tt.limits {
+				t.Fatalf("Limits = %+v; want limits %v", permission.Limits, tt.limits)
+			}
+		})
+	}
+
+	db := &store.Database{Name: "orders", Type: "mysql", AvailablePermissions: []string{"read", "admin"}}
+	var denied *DeniedError
+	if _, err := EvaluateBreakGlass(s, "bob", db, []string{"responders"}, RequestContext{}); !errors.As(err, &denied) {
+		t.Fatalf("EvaluateBreakGlass for a non-member = %v; want a denial", err)
+	}
+	empty := &store.Database{Name: "orders", Type: "mysql"}
+	if _, err := EvaluateBreakGlass(s, "alice", empty, []string{"responders"}, RequestContext{}); !errors.As(err, &denied) {
+		t.Fatalf("EvaluateBreakGlass on a database without levels = %v; want a denial", err)
+	}
+
+	// Policies still apply, and see the session is a break-glass one
+	if err := s.SavePolicy(&store.Policy{Name: "no-break-glass-admin", Enabled: true,
+		Expression: `!request.break_glass || request.level != "admin"`}); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	t.Cleanup(func() { forgetProgram("no-break-glass-admin") })
+	if _, err := EvaluateBreakGlass(s, "alice", db, []string{"responders"}, RequestContext{}); !errors.As(err, &denied) {
+		t.Fatalf("EvaluateBreakGlass refused by a policy = %v; want a denial", err)
+	}
+}
+
+func TestEvaluateAppliesValidity(t *testing.T) {
+	s := newTestStore(t)
+	from := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 10, 31, 17, 0, 0, 0, time.UTC)
+	db := &store.Database{
+		Name:                 "orders",
+		Type:                 "mysql",
+		BackendAddr:          "db.internal:3306",
+		AdminUsername:        "zgate",
+		AdminPassword:        "secret",
+		TargetDatabase:       "orders",
+		AvailablePermissions: []string{"read", "write", "admin"},
+	}
+	if err := s.SaveDatabase(db); err != nil {
+		t.Fatalf("SaveDatabase: %v", err)
+	}
+	roles := []store.Role{
+		// reader applies from its permission's valid_from, writer until its assignment's valid_until
+		{Name: "reader", Permissions: []store.Permission{{Database: "orders", Level: "read", Validity: store.Validity{ValidFrom: &from}}}},
+		{Name: "writer", Permissions: []store.Permission{{Database: "orders", Level: "write"}}},
+	}
+	for i := range roles {
+		if err := s.SaveRole(&roles[i]); err != nil {
+			t.Fatalf("SaveRole(%s): %v", roles[i].Name, err)
+		}
+	}
+	if err := s.CreateUserWithPassword(&store.User{
+		Username:     "alice",
+		Roles:        []string{"reader", "writer"},
+		RoleValidity: map[string]store.Validity{"writer": {ValidUntil: &until}},
+	}, "secret"); err != nil {
+		t.Fatalf("CreateUserWithPassword: %v", err)
+	}
+	tests := []struct {
+		name    string
+		at      time.Time
+		allowed bool
+		level   string
+	}{
+		{"before valid_from", from.Add(-time.Nanosecond), true, "write"},
+		{"just before valid_until", until.Add(-time.Nanosecond), true, "write"},
+		{"exactly valid_until", until, true, "read"},
+		{"after valid_until", until.Add(time.Hour), true, "read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Evaluate(s, "alice", db, RequestContext{Time: tt.at})
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if res.Allowed != tt.allowed || res.Level != tt.level {
+				t.Fatalf("Evaluate at %v = allowed %v, %q; want allowed %v, %q (reason %q)", tt.at, res.Allowed, res.Level, tt.allowed, tt.level, res.Reason)
+			}
+		})
+	}
+
+	// Before reader's valid_from and before bob's writer assignment starts nothing applies
+	if err := s.CreateUserWithPassword(&store.User{
+		Username:     "bob",
+		Roles:        []string{"reader", "writer"},
+		RoleValidity: map[string]store.Validity{"writer": {ValidFrom: &until}},
+	}, "secret"); err != nil {
+		t.Fatalf("CreateUserWithPassword: %v", err)
+	}
+	for _, at := range []time.Time{from.Add(-time.Nanosecond), from} {
+		res, err := Evaluate(s, "bob", db, RequestContext{Time: at})
+		if err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+		if want := at.Equal(from); res.Allowed != want || (want && res.Level != "read") {
+			t.Fatalf("Evaluate at %v = allowed %v, %q; want allowed %v", at, res.Allowed, res.Level, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// Explanation is the decision trace behind a Resolution: the groups and role
+// assignments considered, then how every grant, condition and policy was treated
+type Explanation struct {
+	*Resolution
+	Groups []string    `json:"groups"`
+	Roles  []RoleTrace `json:"roles"`
+	// EvaluatedAt and ClientIP are the request context conditions and policies were checked against
+	EvaluatedAt time.Time `json:"evaluated_at"`
+	ClientIP    string    `json:"client_ip,omitempty"`
+}
+
+// RoleTrace is one role assignment considered for a user
+type RoleTrace struct {
+	Role string `json:"role"`
+	// Group is the user's group assigning the role; empty for direct assignments
+	Group     string   `json:"group,omitempty"`
+	Inherited []string `json:"inherited,omitempty"`
+	store.Validity
+	// Active reports whether the assignment's validity window covers the evaluated time
+	Active bool `json:"active"`
+}
+
+// explain resolves the subject's access to db and records what was considered
+func (sub *subject) explain(db *store.Database, rc RequestContext) *Explanation {
+	exp := &Explanation{
+		Resolution:  sub.resolve(db, rc),
+		Groups:      nonNilStrings(sub.groups),
+		Roles:       []RoleTrace{},
+		EvaluatedAt: rc.now(),
+	}
+	if rc.ClientIP != nil {
+		exp.ClientIP = rc.ClientIP.String()
+	}
+	for _, assignment := range sub.assignments {
+		exp.Roles = append(exp.Roles, RoleTrace{
+			Role:      assignment.role,
+			Group:     assignment.group,
+			Inherited: assignment.inherited,
+			Validity:  assignment.validity,
+			Active:    assignment.validity.ActiveAt(rc.now()),
+		})
+	}
+	return exp
+}
+
+// Changes are unsaved edits a simulation evaluates as if they were stored
+type Changes struct {
+	// Roles, when not nil, replaces the user's direct role assignments, with
+	// RoleValidity as their validity windows
+	Roles        []string
+	RoleValidity map[string]store.Validity
+	// CustomPermissions, when not nil, replaces the user's custom permissions
+	CustomPermissions []store.Permission
+	// RoleDefinitions replace or add roles by name, including their parents
+	RoleDefinitions map[string]*store.Role
+}
+
+// simulation reads from the store with changes applied on top
+type simulation struct {
+	*store.Store
+	changes *Changes
+}
+
+// GetUser returns the stored user with the simulated role assignments and custom permissions
+func (sim simulation) GetUser(username string) (*store.User, error) {
+	user, err := sim.Store.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if sim.changes.Roles != nil {
+		user.Roles = sim.changes.Roles
+		user.RoleValidity = sim.changes.RoleValidity
+	}
+	if sim.changes.CustomPermissions != nil {
+		user.CustomPermissions = sim.changes.CustomPermissions
+	}
+	return user, nil
+}
+
+// GetRole returns the simulated definition of a role, or the stored one
+func (sim simulation) GetRole(name string) (*store.Role, error) {
+	if role, ok := sim.changes.RoleDefinitions[name]; ok {
+		return role, nil
+	}
+	return sim.Store.GetRole(name)
+}
+
+// RoleAncestors follows parents through the simulated role definitions
+func (sim simulation) RoleAncestors(name string) ([]string, error) {
+	if len(sim.changes.RoleDefinitions) == 0 {
+		return sim.Store.RoleAncestors(name)
+	}
+	ancestors, _, err := sim.ancestors(name)
+	return ancestors, err
+}
+
+// ancestors walks name's parents breadth first, reporting whether name turned
+// out to be its own ancestor
+func (sim simulation) ancestors(name string) ([]string, bool, error) {
+	var ancestors []string
+	cycle := false
+	seen := map[string]bool{}
+	queue := []string{name}
+	for len(queue) > 0 {
+		role, err := sim.GetRole(queue[0])
+		queue = queue[1:]
+		if err != nil {
+			if store.IsNotFound(err) {
+				continue
+			}
+			return nil, false, err
+		}
+		for _, parent := range role.Parents {
+			if parent == name {
+				cycle = true
+			}
+			if seen[parent] || parent == name {
+				continue
+			}
+			seen[parent] = true
+			ancestors = append(ancestors, parent)
+			queue = append(queue, parent)
+		}
+	}
+	return ancestors, cycle, nil
+}
+
+// CheckRoleCycles rejects simulated role definitions that would make a role its own ancestor
+func (c *Changes) CheckRoleCycles(s *store.Store) error {
+	sim := simulation{Store: s, changes: c}
+	for name := range c.RoleDefinitions {
+		_, cycle, err := sim.ancestors(name)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("role %q would inherit from itself: %w", name, store.ErrRoleCycle)
+		}
+	}
+	return nil
+}
+
+// Explain traces a user's access to one database, or to every database when
+// databaseName is empty. With changes, the trace shows access as it would be
+// once they are saved; nothing is written.
+func (e *Engine) Explain(username, databaseName string, rc RequestContext, changes *Changes) ([]*Explanation, error) {
+	var src source = e.store
+	if changes != nil {
+		src = simulation{Store: e.store, changes: changes}
+	}
+	sub, err := loadSubject(src, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var databases []store.Database
+	if databaseName != "" {
+		db, err := e.store.GetDatabase(databaseName)
+		if err != nil {
+			return nil, err
+		}
+		databases = []store.Database{*db}
+	} else if databases, err = e.store.ListDatabases(); err != nil {
+		return nil, err
+	}
+
+	explanations := make([]*Explanation, 0, len(databases))
+	for i := range databases {
+		explanations = append(explanations, sub.explain(&databases[i], rc))
+	}
+	return explanations, nil
+}
+
+// SameAccess reports whether two resolutions grant the same access
+func SameAccess(a, b *Resolution) bool {
+	return a.Allowed == b.Allowed && a.Level == b.Level && a.Source == b.Source &&
+		slices.EqualFunc(a.Objects, b.Objects, func(x, y store.ObjectGrant) bool {
+			return x.Schema == y.Schema && x.Table == y.Table &&
+				slices.Equal(x.Columns, y.Columns) && slices.Equal(x.Privileges, y.Privileges)
+		})
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// newExplainStore returns a store with an orders database offering a custom
+// "reporting" level and users who reach it through each resolution rule
+func newExplainStore(t *testing.T) *store.Store {
+	t.Helper()
+	s := newTestStore(t)
+	if err := s.SaveDatabase(&store.Database{
+		Name:                 "orders",
+		Type:                 "mysql",
+		BackendAddr:          "db.internal:3306",
+		AdminUsername:        "zgate",
+		AdminPassword:        "secret",
+		TargetDatabase:       "orders",
+		AvailablePermissions: []string{"read", "write", "admin"},
+	}); err != nil {
+		t.Fatalf("SaveDatabase: %v", err)
+	}
+	if err := s.SavePermissionLevel(&store.PermissionLevel{Database: "orders", Name: "reporting", NativeRoles: []string{"reporting"}},
+		[]string{"read", "write", "reporting", "admin"}); err != nil {
+		t.Fatalf("SavePermissionLevel: %v", err)
+	}
+
+	office := &store.Conditions{SourceCIDRs: []string{"10.0.0.0/8"}}
+	roles := []store.Role{
+		{Name: "reader", Permissions: []store.Permission{{Database: "orders", Level: "read"}}},
+		{Name: "writer", Parents: []string{"reader"}, Permissions: []store.Permission{{Database: "orders", Level: "write"}}},
+		{Name: "lead", Parents: []string{"writer"}},
+		{Name: "dba", Permissions: []store.Permission{{Database: "orders", Level: "admin"}}},
+		{Name: "contractor", Permissions: []store.Permission{{Database: "orders", Deny: true}}},
+		{Name: "office-dba", Permissions: []store.Permission{{Database: "orders", Level: "admin", Conditions: office}}},
+	}
+	for i := range roles {
+		if err := s.SaveRole(&roles[i]); err != nil {
+			t.Fatalf("SaveRole(%s): %v", roles[i].Name, err)
+		}
+	}
+
+	users := []store.User{
+		{Username: "denied", Roles: []string{"dba", "contractor"}},
+		{Username: "custom", Roles: []string{"dba"}, CustomPermissions: []store.Permission{{Database: "orders", Level: "reporting"}}},
+		{Username: "inherited"},
+		{Username: "conditional", Roles: []string{"reader", "office-dba"}},
+	}
+	for i := range users {
+		if err := s.CreateUserWithPassword(&users[i], "secret"); err != nil {
+			t.Fatalf("CreateUserWithPassword(%s): %v", users[i].Username, err)
+		}
+	}
+	// inherited holds lead through a group and reaches write through lead's parents
+	if err := s.SaveGroup(&store.Group{Name: "engineering", Members: []string{"inherited"}, Roles: []string{"lead"}}); err != nil {
+		t.Fatalf("SaveGroup: %v", err)
+	}
+	return s
+}
+
+func TestExplainMatchesResolve(t *testing.T) {
+	e := NewEngine(newExplainStore(t))
+	noon := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		username string
+		ip       string
+		allowed  bool
+		level    string
+		source   string
+		via      string
+	}{
+		{"deny", "denied", "10.0.0.1", false, "", "", ""},
+		{"custom", "custom", "10.0.0.1", true, "reporting", CustomSource, ""},
+		{"inherited role", "inherited", "10.0.0.1", true, "write", RoleSource("writer"), "group:engineering > lead"},
+		{"condition met", "conditional", "10.0.0.1", true, "admin", RoleSource("office-dba"), ""},
+		{"condition unmet", "conditional", "192.0.2.1", true, "read", RoleSource("reader"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := RequestContext{ClientIP: net.ParseIP(tt.ip), Time: noon}
+			res, err := e.Resolve(tt.username, "orders", rc)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			explanations, err := e.Explain(tt.username, "orders", rc, nil)
+			if err != nil {
+				t.Fatalf("Explain: %v", err)
+			}
+			if len(explanations) != 1 {
+				t.Fatalf("%d explanations; want 1", len(explanations))
+			}
+			exp := explanations[0]
+
+			if !SameAccess(exp.Resolution, res) || exp.Reason != res.Reason {
+				t.Fatalf("Explain = allowed %v, %q from %q (%q); Resolve = allowed %v, %q from %q (%q)",
+					exp.Allowed, exp.Level, exp.Source, exp.Reason, res.Allowed, res.Level, res.Source, res.Reason)
+			}
+			if res.Allowed != tt.allowed || res.Level != tt.level || res.Source != tt.source {
+				t.Fatalf("Resolve = allowed %v, %q from %q; want allowed %v, %q from %q (reason %q)",
+					res.Allowed, res.Level, res.Source, tt.allowed, tt.level, tt.source, res.Reason)
+			}
+			if !slices.EqualFunc(exp.Candidates, res.Candidates, func(a, b Candidate) bool {
+				return a.Source == b.Source && a.Outcome == b.Outcome && a.Reason == b.Reason
+			}) {
+				t.Fatalf("Explain candidates %+v; Resolve candidates %+v", exp.Candidates, res.Candidates)
+			}
+			if tt.via != "" {
+				i := slices.IndexFunc(exp.Candidates, func(c Candidate) bool { return c.Outcome == "selected" })
+				if i < 0 || exp.Candidates[i].Via != tt.via {
+					t.Fatalf("selected candidate %+v; want it reached via %q", exp.Candidates, tt.via)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainSimulationMatchesSavedChanges(t *testing.T) {
+	s := newExplainStore(t)
+	e := NewEngine(s)
+	rc := RequestContext{ClientIP: net.ParseIP("192.0.2.1"), Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+
+	// Simulate giving conditional the lead role and a custom deny, then save the same edits
+	changes := &Changes{
+		Roles:             []string{"lead", "office-dba"},
+		CustomPermissions: []store.Permission{{Database: "orders", Deny: true, Conditions: &store.Conditions{SourceCIDRs: []string{"10.0.0.0/8"}}}},
+	}
+	explanations, err := e.Explain("conditional", "orders", rc, changes)
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	before, err := e.Resolve("conditional", "orders", rc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if before.Level != "read" {
+		t.Fatalf("simulation changed the stored user: level %q; want read", before.Level)
+	}
+
+	user, err := s.GetUser("conditional")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	user.Roles, user.CustomPermissions = changes.Roles, changes.CustomPermissions
+	if err := s.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	after, err := e.Resolve("conditional", "orders", rc)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if exp := explanations[0]; !SameAccess(exp.Resolution, after) || after.Level != "write" {
+		t.Fatalf("simulated %q from %q; saved %q from %q; want write from both", exp.Level, exp.Source, after.Level, after.Source)
+	}
+}
@@ -0,0 +1,387 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// CustomSource identifies a user's custom permissions as the origin of a grant
+const CustomSource = "custom"
+
+// BreakGlassSource identifies an emergency session that was opened without a grant
+const BreakGlassSource = "break-glass"
+
+// Grant is a permission together with where it came from
+type Grant struct {
+	// Source is "role:<name>" for role permissions, "request:<id>" for approved
+	// access requests, or CustomSource
+	Source string `json:"source"`
+	// Via is how the user holds a role grant when it is not assigned directly:
+	// the group assigning it ("group:<name>") and the assigned role it was
+	// inherited through, joined by " > "
+	Via        string           `json:"via,omitempty"`
+	Permission store.Permission `json:"permission"`
+}
+
+// Candidate records how the resolver treated one grant for a database
+type Candidate struct {
+	Source  string                `json:"source"`
+	Via     string                `json:"via,omitempty"`
+	Level   string                `json:"level,omitempty"`
+	Objects []store.ObjectGrant   `json:"objects,omitempty"`
+	Deny    bool                  `json:"deny,omitempty"`
+	Limits  *store.ResourceLimits `json:"limits,omitempty"`
+	// ElevateTo is the level this grant lets a live session elevate to
+	ElevateTo  string            `json:"elevate_to,omitempty"`
+	Conditions *store.Conditions `json:"conditions,omitempty"`
+	store.Validity
+	// Outcome is one of "denied", "selected", "overridden", "outranked", "ignored",
+	// "inactive" or "unmet"
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Resolution is the effective access a user has to one database
+type Resolution struct {
+	Database string              `json:"database"`
+	Allowed  bool                `json:"allowed"`
+	Level    string              `json:"level,omitempty"`
+	Objects  []store.ObjectGrant `json:"objects,omitempty"`
+	Source   string              `json:"source,omitempty"`
+	// Limits is the stricter of the selected grant's limits and its level's limits
+	Limits   *store.ResourceLimits `json:"limits,omitempty"`
+	DeniedBy []string              `json:"denied_by,omitempty"`
+	// Reason explains a denial in terms the user can act on
+	Reason     string      `json:"reason,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+	// Policies lists the stored policies evaluated once grants allowed access
+	Policies []PolicyResult `json:"policies,omitempty"`
+}
+
+// Permission returns the resolved permission, or nil when access is not allowed
+func (r *Resolution) Permission() *store.Permission {
+	if !r.Allowed {
+		return nil
+	}
+	return &store.Permission{Database: r.Database, Level: r.Level, Objects: r.Objects, Limits: r.Limits}
+}
+
+// RoleSource returns the grant source for a role
+func RoleSource(role string) string {
+	return "role:" + role
+}
+
+// RequestSource returns the grant source for an approved access request
+func RequestSource(id int64) string {
+	return "request:" + strconv.FormatInt(id, 10)
+}
+
+// isRequestSource reports whether source is an approved access request
+func isRequestSource(source string) bool {
+	return strings.HasPrefix(source, "request:")
+}
+
+// roleAssignment is a role a user holds, directly or through a group, with the roles it inherits
+type roleAssignment struct {
+	role      string
+	group     string // the user's group assigning the role; empty for direct assignments
+	validity  store.Validity
+	inherited []string
+}
+
+// roles returns the assigned role followed by every role it inherits from
+func (a roleAssignment) roles() []string {
+	return append([]string{a.role}, a.inherited...)
+}
+
+// via describes how the user holds name through this assignment
+func (a roleAssignment) via(name string) string {
+	var path []string
+	if a.group != "" {
+		path = append(path, "group:"+a.group)
+	}
+	if name != a.role {
+		path = append(path, a.role)
+	}
+	return strings.Join(path, " > ")
+}
+
+// loadRoleAssignments reads a user's direct role assignments, then the roles
+// assigned to each of the user's groups, each with the roles it inherits
+func loadRoleAssignments(s source, user *store.User, groups []string) ([]roleAssignment, error) {
+	var assignments []roleAssignment
+	for _, role := range user.Roles {
+		assignments = append(assignments, roleAssignment{role: role, validity: user.RoleValidity[role]})
+	}
+
+	var err error
+	for _, name := range groups {
+		group, err := s.GetGroup(name)
+		if err != nil {
+			if store.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("load group %s: %w", name, err)
+		}
+		for _, role := range group.Roles {
+			assignments = append(assignments, roleAssignment{role: role, group: name})
+		}
+	}
+
+	for i := range assignments {
+		if assignments[i].inherited, err = s.RoleAncestors(assignments[i].role); err != nil {
+			return nil, fmt.Errorf("load role %s: %w", assignments[i].role, err)
+		}
+	}
+	return assignments, nil
+}
+
+// userGrants loads every grant for a user: the permissions of each assigned
+// role and of the roles it inherits from, custom permissions, then approved
+// access requests that have not expired
+func userGrants(s source, user *store.User, assignments []roleAssignment) ([]Grant, error) {
+	var grants []Grant
+	for _, assignment := range assignments {
+		for _, roleName := range assignment.roles() {
+			role, err := s.GetRole(roleName)
+			if err != nil {
+				if store.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("load role %s: %w", roleName, err)
+			}
+			// A role's permissions apply only while both the assignment and the permission are valid
+			for _, perm := range role.Permissions {
+				perm.Validity = perm.Validity.Intersect(assignment.validity)
+				grants = append(grants, Grant{Source: RoleSource(role.Name), Via: assignment.via(roleName), Permission: perm})
+			}
+		}
+	}
+	for _, perm := range user.CustomPermissions {
+		grants = append(grants, Grant{Source: CustomSource, Permission: perm})
+	}
+
+	requests, err := s.ActiveAccessGrants(user.Username)
+	if err != nil {
+		return nil, fmt.Errorf("load access requests: %w", err)
+	}
+	for _, req := range requests {
+		grants = append(grants, Grant{
+			Source:     RequestSource(req.ID),
+			Permission: store.Permission{Database: req.Database, Level: req.Level},
+		})
+	}
+	return grants, nil
+}
+
+// Resolve merges every grant for db into one effective permission.
+//
+// The rules, applied in order:
+//  1. Any deny entry, from a role or custom permissions, blocks the database.
+//  2. Custom permissions override role permissions; roles only apply without one.
+//     Approved access requests are never overridden.
+//  3. Among the remaining grants the highest level wins. Levels rank by their
+//     position in the database's available_permissions (least privileged first).
+//  4. Ties prefer a grant on the whole database over one narrowed to objects,
+//     then the lowest source name, so the result does not depend on row order.
+//
+// Grants outside their validity window (inactive) or whose conditions rc does
+// not meet (unmet) take no part, deny entries included. Grants whose level the
+// database no longer offers are ignored. The selected grant's resource limits
+// are tightened by any limits on its level.
+func Resolve(db *store.Database, grants []Grant, rc RequestContext) *Resolution {
+	res := &Resolution{Database: db.Name, Candidates: []Candidate{}}
+
+	var matching []Grant
+	var skipped []Candidate
+	for _, g := range grants {
+		if g.Permission.Database != db.Name {
+			continue
+		}
+		if !g.Permission.ActiveAt(rc.now()) {
+			skipped = append(skipped, skippedCandidate(g, "inactive", "outside its validity window"))
+			continue
+		}
+		if ok, reason := checkConditions(g.Permission.Conditions, rc); !ok {
+			skipped = append(skipped, skippedCandidate(g, "unmet", reason))
+			continue
+		}
+		matching = append(matching, g)
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].Source < matching[j].Source
+	})
+
+	hasCustom := false
+	for _, g := range matching {
+		if g.Permission.Deny {
+			res.DeniedBy = append(res.DeniedBy, g.Source)
+		} else if g.Source == CustomSource && slices.Contains(db.AvailablePermissions, g.Permission.Level) {
+			hasCustom = true
+		}
+	}
+
+	best := -1
+	for i, g := range matching {
+		if g.Permission.Deny || len(res.DeniedBy) > 0 {
+			continue
+		}
+		if !slices.Contains(db.AvailablePermissions, g.Permission.Level) {
+			continue
+		}
+		if overriddenByCustom(g, hasCustom) {
+			continue
+		}
+		if best < 0 || outranks(db, g, matching[best]) {
+			best = i
+		}
+	}
+
+	for i, g := range matching {
+		c := Candidate{
+			Source:  g.Source,
+			Via:     g.Via,
+			Level:   g.Permission.Level,
+			Objects: g.Permission.Objects,
+			Deny:    g.Permission.Deny,
+			Limits:  g.Permission.Limits,
+
+			ElevateTo:  g.Permission.ElevateTo,
+			Conditions: g.Permission.Conditions,
+			Validity:   g.Permission.Validity,
+		}
+		switch {
+		case g.Permission.Deny:
+			c.Outcome = "denied"
+			c.Level = ""
+		case len(res.DeniedBy) > 0:
+			c.Outcome = "overridden"
+			c.Reason = "database is denied"
+		case !slices.Contains(db.AvailablePermissions, g.Permission.Level):
+			c.Outcome = "ignored"
+			c.Reason = fmt.Sprintf("level %q is not available on database %s", g.Permission.Level, db.Name)
+		case overriddenByCustom(g, hasCustom):
+			c.Outcome = "overridden"
+			c.Reason = "custom permission takes precedence over roles"
+		case i == best:
+			c.Outcome = "selected"
+		default:
+			c.Outcome = "outranked"
+			c.Reason = fmt.Sprintf("%s grants %s", matching[best].Source, matching[best].Permission.Level)
+		}
+		res.Candidates = append(res.Candidates, c)
+	}
+	res.Candidates = append(res.Candidates, skipped...)
+
+	if best >= 0 {
+		res.Allowed = true
+		res.Level = matching[best].Permission.Level
+		res.Objects = matching[best].Permission.Objects
+		res.Source = matching[best].Source
+		res.Limits = matching[best].Permission.Limits
+		if level, ok := db.Level(res.Level); ok {
+			res.Limits = store.StricterLimits(res.Limits, level.Limits)
+		}
+	} else {
+		res.Reason = denialReason(res)
+	}
+	return res
+}
+
+// skippedCandidate records a grant that took no part in resolution
+func skippedCandidate(g Grant, outcome, reason string) Candidate {
+	return Candidate{
+		Source:     g.Source,
+		Via:        g.Via,
+		Level:      g.Permission.Level,
+		Objects:    g.Permission.Objects,
+		Deny:       g.Permission.Deny,
+		Conditions: g.Permission.Conditions,
+		Validity:   g.Permission.Validity,
+		Outcome:    outcome,
+		Reason:     reason,
+	}
+}
+
+// denialReason explains why res allows no access
+func denialReason(res *Resolution) string {
+	if len(res.DeniedBy) > 0 {
+		return "access is denied by " + strings.Join(res.DeniedBy, ", ")
+	}
+
+	var unmet []string
+	outcomes := map[string]bool{}
+	for _, c := range res.Candidates {
+		outcomes[c.Outcome] = true
+		if c.Outcome == "unmet" {
+			unmet = append(unmet, c.Source+": "+c.Reason)
+		}
+	}
+	switch {
+	case len(unmet) > 0:
+		return "conditions not met: " + strings.Join(unmet, "; ")
+	case outcomes["inactive"]:
+		return "grants for " + res.Database + " are outside their validity window"
+	case outcomes["ignored"]:
+		return "the granted level is no longer offered on " + res.Database
+	default:
+		return "no grant for " + res.Database
+	}
+}
+
+// ResolveElevation checks whether a session on db may elevate to level and
+// returns the permission to grant for the elevation window.
+//
+// Only grants that Resolve would consider (no deny on the database, custom
+// permissions taking precedence over roles) may offer an elevation, through
+// their elevate_to. The level must outrank the one Resolve selects, and the
+// elevation always covers the whole level.
+func ResolveElevation(db *store.Database, grants []Grant, level string, rc RequestContext) (*store.Permission, error) {
+	standing := Resolve(db, grants, rc)
+	if !standing.Allowed {
+		return nil, fmt.Errorf("no access to database %s", db.Name)
+	}
+	if db.Rank(level) < 0 {
+		return nil, fmt.Errorf("level %q is not available on database %s", level, db.Name)
+	}
+	if db.Rank(level) <= db.Rank(standing.Level) {
+		return nil, fmt.Errorf("level %q does not outrank the current level %q", level, standing.Level)
+	}
+
+	var eligible []Grant
+	for _, c := range standing.Candidates {
+		if c.ElevateTo != level || (c.Outcome != "selected" && c.Outcome != "outranked") {
+			continue
+		}
+		eligible = append(eligible, Grant{
+			Source:     c.Source,
+			Permission: store.Permission{Database: db.Name, Level: level, Limits: c.Limits},
+		})
+	}
+
+	elevated := Resolve(db, eligible, rc)
+	if !elevated.Allowed {
+		return nil, fmt.Errorf("no grant allows elevation to %q on database %s", level, db.Name)
+	}
+	return elevated.Permission(), nil
+}
+
+// overriddenByCustom reports whether custom permissions take precedence over g
+func overriddenByCustom(g Grant, hasCustom bool) bool {
+	return hasCustom && g.Source != CustomSource && !isRequestSource(g.Source)
+}
+
+// outranks reports whether grant a should be chosen over grant b.
+// Callers pass grants sorted by source, so equal grants keep the earlier one.
+func outranks(db *store.Database, a, b Grant) bool {
+	rankA, rankB := db.Rank(a.Permission.Level), db.Rank(b.Permission.Level)
+	if rankA != rankB {
+		return rankA > rankB
+	}
+	return len(a.Permission.Objects) == 0 && len(b.Permission.Objects) > 0
+}
@@ -0,0 +1,224 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// ordersDB offers the built-in levels and a custom "reporting" level ranked between write and admin
+func ordersDB() *store.Database {
+	return &store.Database{
+		Name:                 "orders",
+		Type:                 "mysql",
+		AvailablePermissions: []string{"read", "write", "reporting", "admin"},
+		Levels: []store.PermissionLevel{{
+			Database:    "orders",
+			Name:        "reporting",
+			NativeRoles: []string{"reporting"},
+			Limits:      &store.ResourceLimits{MaxConnections: 2},
+		}},
+	}
+}
+
+// grant returns a grant on orders from source at level
+func grant(source, level string) Grant {
+	return Grant{Source: source, Permission: store.Permission{Database: "orders", Level: level}}
+}
+
+// deny returns a deny entry on orders from source
+func deny(source string) Grant {
+	return Grant{Source: source, Permission: store.Permission{Database: "orders", Deny: true}}
+}
+
+// scoped narrows g to one table
+func scoped(g Grant) Grant {
+	g.Permission.Objects = []store.ObjectGrant{{Table: "invoices", Privileges: []string{"SELECT"}}}
+	return g
+}
+
+func TestResolve(t *testing.T) {
+	nightOnly := &store.Conditions{StartTime: "22:00", EndTime: "06:00"}
+	expired := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	noon := RequestContext{Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name     string
+		grants   []Grant
+		allowed  bool
+		level    string
+		source   string
+		scoped   bool
+		outcomes map[string]string // source to outcome
+	}{
+		{
+			name:     "no grants",
+			allowed:  false,
+			outcomes: map[string]string{},
+		},
+		{
+			name:     "deny wins over a higher grant",
+			grants:   []Grant{grant("role:dba", "admin"), deny("role:contractor")},
+			allowed:  false,
+			outcomes: map[string]string{"role:dba": "overridden", "role:contractor": "denied"},
+		},
+		{
+			name:     "custom deny blocks approved requests",
+			grants:   []Grant{grant("request:7", "write"), deny(CustomSource)},
+			allowed:  false,
+			outcomes: map[string]string{"request:7": "overridden", CustomSource: "denied"},
+		},
+		{
+			name: "deny with unmet conditions takes no part",
+			grants: []Grant{grant("role:dev", "read"), {Source: "role:nights", Permission: store.Permission{
+				Database: "orders", Deny: true, Conditions: nightOnly,
+			}}},
+			allowed: true, level: "read", source: "role:dev",
+			outcomes: map[string]string{"role:dev": "selected", "role:nights": "unmet"},
+		},
+		{
+			name:    "custom overrides a higher role grant",
+			grants:  []Grant{grant("role:dba", "admin"), grant(CustomSource, "read")},
+			allowed: true, level: "read", source: CustomSource,
+			outcomes: map[string]string{"role:dba": "overridden", CustomSource: "selected"},
+		},
+		{
+			name:    "custom grant of a level no longer offered overrides nothing",
+			grants:  []Grant{grant("role:dev", "write"), grant(CustomSource, "legacy")},
+			allowed: true, level: "write", source: "role:dev",
+			outcomes: map[string]string{"role:dev": "selected", CustomSource: "ignored"},
+		},
+		{
+			name: "inactive custom grant overrides nothing",
+			grants: []Grant{grant("role:dev", "write"), {Source: CustomSource, Permission: store.Permission{
+				Database: "orders", Level: "read", Validity: store.Validity{ValidUntil: &expired},
+			}}},
+			allowed: true, level: "write", source: "role:dev",
+			outcomes: map[string]string{"role:dev": "selected", CustomSource: "inactive"},
+		},
+		{
+			name:    "approved requests are not overridden by custom",
+			grants:  []Grant{grant(CustomSource, "read"), grant("request:7", "write")},
+			allowed: true, level: "write", source: "request:7",
+			outcomes: map[string]string{CustomSource: "outranked", "request:7": "selected"},
+		},
+		{
+			name:    "highest level wins",
+			grants:  []Grant{grant("role:writer", "write"), grant("role:reader", "read")},
+			allowed: true, level: "write", source: "role:writer",
+			outcomes: map[string]string{"role:writer": "selected", "role:reader": "outranked"},
+		},
+		{
+			name:    "custom level ranks by its position",
+			grants:  []Grant{grant("role:analyst", "reporting"), grant("role:writer", "write")},
+			allowed: true, level: "reporting", source: "role:analyst",
+			outcomes: map[string]string{"role:analyst": "selected", "role:writer": "outranked"},
+		},
+		{
+			name:    "custom level ranked below admin",
+			grants:  []Grant{grant("role:analyst", "reporting"), grant("role:dba", "admin")},
+			allowed: true, level: "admin", source: "role:dba",
+			outcomes: map[string]string{"role:analyst": "outranked", "role:dba": "selected"},
+		},
+		{
+			name:    "tie prefers the whole database over objects",
+			grants:  []Grant{scoped(grant("role:a", "write")), grant("role:b", "write")},
+			allowed: true, level: "write", source: "role:b",
+			outcomes: map[string]string{"role:a": "outranked", "role:b": "selected"},
+		},
+		{
+			name:    "tie keeps the whole database whatever the order",
+			grants:  []Grant{scoped(grant("role:b", "write")), grant("role:a", "write")},
+			allowed: true, level: "write", source: "role:a",
+			outcomes: map[string]string{"role:a": "selected", "role:b": "outranked"},
+		},
+		{
+			name:    "higher objects-only grant beats a lower whole-database grant",
+			grants:  []Grant{grant("role:a", "read"), scoped(grant("role:b", "write"))},
+			allowed: true, level: "write", source: "role:b", scoped: true,
+			outcomes: map[string]string{"role:a": "outranked", "role:b": "selected"},
+		},
+		{
+			name:    "full tie picks the first source",
+			grants:  []Grant{grant("role:b", "write"), grant("role:a", "write")},
+			allowed: true, level: "write", source: "role:a",
+			outcomes: map[string]string{"role:a": "selected", "role:b": "outranked"},
+		},
+		{
+			name:     "grants on other databases are not considered",
+			grants:   []Grant{{Source: "role:dba", Permission: store.Permission{Database: "billing", Level: "admin"}}},
+			allowed:  false,
+			outcomes: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Resolve(ordersDB(), tt.grants, noon)
+			if res.Allowed != tt.allowed || res.Level != tt.level || res.Source != tt.source {
+				t.Fatalf("Resolve = allowed %v, %q from %q; want allowed %v, %q from %q (reason %q)",
+					res.Allowed, res.Level, res.Source, tt.allowed, tt.level, tt.source, res.Reason)
+			}
+			if scoped := len(res.Objects) > 0; scoped != tt.scoped {
+				t.Fatalf("Objects = %v; want scoped %v", res.Objects, tt.scoped)
+			}
+			if !res.Allowed && res.Reason == "" {
+				t.Fatalf("denied without a reason")
+			}
+			if len(res.Candidates) != len(tt.outcomes) {
+				t.Fatalf("%d candidates; want %d", len(res.Candidates), len(tt.outcomes))
+			}
+			for _, c := range res.Candidates {
+				if want := tt.outcomes[c.Source]; c.Outcome != want {
+					t.Errorf("candidate %s: outcome %q (%s); want %q", c.Source, c.Outcome, c.Reason, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveTightensLimitsWithLevel(t *testing.T) {
+	g := grant("role:analyst", "reporting")
+	g.Permission.Limits = &store.ResourceLimits{MaxConnections: 5, MaxQueriesPerHour: 100}
+
+	res := Resolve(ordersDB(), []Grant{g}, RequestContext{})
+	want := store.ResourceLimits{MaxConnections: 2, MaxQueriesPerHour: 100}
+	if res.Limits == nil || *res.Limits != want {
+		t.Fatalf("Limits = %+v; want %+v", res.Limits, want)
+	}
+}
+
+func TestResolveElevation(t *testing.T) {
+	elevating := func(source, level, to string) Grant {
+		g := grant(source, level)
+		g.Permission.ElevateTo = to
+		return g
+	}
+
+	tests := []struct {
+		name   string
+		grants []Grant
+		level  string
+		ok     bool
+	}{
+		{"selected grant offers elevation", []Grant{elevating("role:dev", "read", "admin")}, "admin", true},
+		{"outranked grant offers elevation", []Grant{elevating("role:dev", "read", "admin"), grant("role:writer", "write")}, "admin", true},
+		{"to a custom level", []Grant{elevating("role:dev", "read", "reporting")}, "reporting", true},
+		{"no grant offers the level", []Grant{elevating("role:dev", "read", "write")}, "admin", false},
+		{"level does not outrank the standing one", []Grant{elevating("role:dev", "read", "write"), grant("role:analyst", "reporting")}, "write", false},
+		{"overridden role grant offers nothing", []Grant{elevating("role:dev", "read", "admin"), grant(CustomSource, "read")}, "admin", false},
+		{"denied database", []Grant{elevating("role:dev", "read", "admin"), deny("role:contractor")}, "admin", false},
+		{"level not offered", []Grant{elevating("role:dev", "read", "owner")}, "owner", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permission, err := ResolveElevation(ordersDB(), tt.grants, tt.level, RequestContext{})
+			if (err == nil) != tt.ok {
+				t.Fatalf("ResolveElevation error = %v; want ok %v", err, tt.ok)
+			}
+			if tt.ok && (permission.Level != tt.level || len(permission.Objects) != 0) {
+				t.Fatalf("ResolveElevation = %+v; want the whole of %q", permission, tt.level)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// PolicyResult records how one stored policy judged a request
+type PolicyResult struct {
+	Policy  string `json:"policy"`
+	Version int    `json:"version"`
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// celEnv declares the input every policy expression is evaluated over:
+//
+//	user.name, user.roles, user.groups, user.attributes
+//	database.name, database.type, database.tags
+//	request.level, request.ip, request.time, request.break_glass
+//
+// plus ip.inCIDR("10.0.0.0/8") for matching the client address
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	input := cel.MapType(cel.StringType, cel.DynType)
+	return cel.NewEnv(
+		cel.Variable("user", input),
+		cel.Variable("database", input),
+		cel.Variable("request", input),
+		cel.Function("inCIDR",
+			cel.MemberOverload("string_in_cidr_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(inCIDR))),
+	)
+})
+
+var (
+	// programsMu guards programs
+	programsMu sync.Mutex
+	// programs caches the compiled current version of each stored policy by name,
+	// so it holds at most one program per policy; programs are safe for concurrent use
+	programs = map[string]compiledPolicy{}
+)
+
+// compiledPolicy is a stored policy version and its compiled expression
+type compiledPolicy struct {
+	version int
+	program cel.Program
+}
+
+// inCIDR implements ip.inCIDR(cidr); an empty or unparsable address is in no network
+func inCIDR(ipVal, cidrVal ref.Val) ref.Val {
+	_, network, err := net.ParseCIDR(string(cidrVal.(types.String)))
+	if err != nil {
+		return types.NewErr("invalid CIDR %q", cidrVal)
+	}
+	ip := net.ParseIP(string(ipVal.(types.String)))
+	return types.Bool(ip != nil && network.Contains(ip))
+}
+
+// CompileExpression checks that expression is a valid policy returning a bool
+func CompileExpression(expression string) (cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, fmt.Errorf("policy environment: %w", err)
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if out := ast.OutputType(); !out.IsExactType(cel.BoolType) && !out.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must return a bool, not %s", out)
+	}
+	return env.Program(ast)
+}
+
+// storedProgram compiles each version of a stored policy once, replacing the
+// program cached for an earlier version
+func storedProgram(p store.Policy) (cel.Program, error) {
+	programsMu.Lock()
+	cached, ok := programs[p.Name]
+	programsMu.Unlock()
+	if ok && cached.version == p.Version {
+		return cached.program, nil
+	}
+
+	program, err := CompileExpression(p.Expression)
+	if err != nil {
+		return nil, err
+	}
+	programsMu.Lock()
+	programs[p.Name] = compiledPolicy{version: p.Version, program: program}
+	programsMu.Unlock()
+	return program, nil
+}
+
+// EvaluateProgram runs a compiled policy over input, which holds the user,
+// database and request maps. A result that is not a bool is an error.
+func EvaluateProgram(program cel.Program, input map[string]any) (bool, error) {
+	out, _, err := program.Eval(input)
+	if err != nil {
+		return false, err
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %s, not a bool", out.Type().TypeName())
+	}
+	return allowed, nil
+}
+
+// ruleInput builds the policy input for the subject acting on db at level.
+// user.roles holds the active role assignments, direct or through groups, and
+// every role they inherit from.
+func (sub *subject) ruleInput(db *store.Database, level string, rc RequestContext) map[string]any {
+	now := rc.now()
+	user := sub.user
+
+	roles := []string{}
+	for _, assignment := range sub.assignments {
+		if !assignment.validity.ActiveAt(now) {
+			continue
+		}
+		for _, name := range assignment.roles() {
+			if !slices.Contains(roles, name) {
+				roles = append(roles, name)
+			}
+		}
+	}
+
+	ip := ""
+	if rc.ClientIP != nil {
+		ip = rc.ClientIP.String()
+	}
+
+	return map[string]any{
+		"user": map[string]any{
+			"name":       user.Username,
+			"roles":      roles,
+			"groups":     nonNilStrings(sub.groups),
+			"attributes": nonNilMap(user.Attributes),
+		},
+		"database": map[string]any{
+			"name": db.Name,
+			"type": db.Type,
+			"tags": nonNilMap(db.Tags),
+		},
+		"request": map[string]any{
+			"level":       level,
+			"ip":          ip,
+			"time":        now,
+			"break_glass": rc.BreakGlass,
+		},
+	}
+}
+
+// applyPolicies evaluates every enabled policy once grants allow access to a
+// database, and withdraws access when any of them does not evaluate to true.
+// Policies that fail to evaluate deny access.
+func (sub *subject) applyPolicies(db *store.Database, res *Resolution, rc RequestContext) {
+	if !res.Allowed {
+		return
+	}
+	input := sub.ruleInput(db, res.Level, rc)
+	for _, p := range sub.policies {
+		result, reason := checkPolicy(p, input)
+		res.Policies = append(res.Policies, result)
+		if !result.Allowed && res.Allowed {
+			res.Allowed = false
+			res.Reason = reason
+		}
+	}
+}
+
+// checkPolicy evaluates one enabled policy, returning its result and, when it
+// does not allow access, the reason to show the user
+func checkPolicy(p store.Policy, input map[string]any) (PolicyResult, string) {
+	result := PolicyResult{Policy: p.Name, Version: p.Version}
+	program, err := storedProgram(p)
+	if err != nil {
+		result.Error = err.Error()
+		return result, fmt.Sprintf("policy %s could not be evaluated", p.Name)
+	}
+	allowed, err := EvaluateProgram(program, input)
+	if err != nil {
+		result.Error = err.Error()
+		return result, fmt.Sprintf("policy %s could not be evaluated", p.Name)
+	}
+	result.Allowed = allowed
+	if allowed {
+		return result, ""
+	}
+	if p.Description != "" {
+		return result, fmt.Sprintf("denied by policy %s: %s", p.Name, p.Description)
+	}
+	return result, "denied by policy " + p.Name
+}
+
+// enabledPolicies loads the stored policies that are in force
+func enabledPolicies(s source) ([]store.Policy, error) {
+	policies, err := s.ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+	enabled := policies[:0]
+	for _, p := range policies {
+		if p.Enabled {
+			enabled = append(enabled, p)
+		}
+	}
+	return enabled, nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+func TestCompileExpression(t *testing.T) {
+	valid := []string{
+		`"dba" in user.roles`,
+		`request.ip.inCIDR("10.0.0.0/8")`,
+		`!("env" in database.tags && database.tags.env == "prod") || request.level != "admin"`,
+		`user.attributes.team`, // dynamic; checked for a bool when evaluated
+	}
+	for _, expression := range valid {
+		if _, err := CompileExpression(expression); err != nil {
+			t.Errorf("CompileExpression(%q): %v", expression, err)
+		}
+	}
+
+	invalid := []string{
+		``,
+		`user.name ==`,
+		`account.name == "alice"`, // undeclared variable
+		`1 + 2`,                   // not a bool
+		`"alice"`,
+		`request.ip.inCIDR(8)`,
+	}
+	for _, expression := range invalid {
+		if _, err := CompileExpression(expression); err == nil {
+			t.Errorf("CompileExpression(%q) succeeded; want an error", expression)
+		}
+	}
+}
+
+// policyInput returns the input alice's request for level on a prod database
+// from ip at noon would be evaluated over
+func policyInput(level, ip string) map[string]any {
+	sub := &subject{
+		user:   &store.User{Username: "alice", Attributes: map[string]string{"team": "payments"}},
+		groups: []string{"engineering"},
+	}
+	db := &store.Database{Name: "orders", Type: "mysql", Tags: map[string]string{"env": "prod"}}
+	rc := RequestContext{ClientIP: net.ParseIP(ip), Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	return sub.ruleInput(db, level, rc)
+}
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		allowed    bool
+		failed     bool // the policy could not be evaluated
+	}{
+		{"allow", `user.name == "alice" && database.tags.env == "prod"`, true, false},
+		{"deny", `request.level != "write"`, false, false},
+		{"client address", `request.ip.inCIDR("10.0.0.0/8")`, true, false},
+		{"client address outside", `request.ip.inCIDR("192.168.0.0/16")`, false, false},
+		{"time", `request.time < timestamp("2026-10-17T00:00:00Z")`, true, false},
+		{"missing key fails closed", `user.attributes.clearance == "high"`, false, true},
+		{"non-bool result fails closed", `user.attributes.team`, false, true},
+		{"invalid CIDR fails closed", `request.ip.inCIDR("10.0.0.0")`, false, true},
+	}
+	input := policyInput("write", "10.1.2.3")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := store.Policy{Name: "test-" + strings.ReplaceAll(tt.name, " ", "-"), Version: 1, Expression: tt.expression}
+			t.Cleanup(func() { forgetProgram(p.Name) })
+
+			result, reason := checkPolicy(p, input)
+			if result.Allowed != tt.allowed {
+				t.Fatalf("Allowed = %v; want %v (error %q)", result.Allowed, tt.allowed, result.Error)
+			}
+			if failed := result.Error != ""; failed != tt.failed {
+				t.Fatalf("Error = %q; want an error: %v", result.Error, tt.failed)
+			}
+			if result.Allowed != (reason == "") {
+				t.Fatalf("reason = %q with Allowed = %v", reason, result.Allowed)
+			}
+			if result.Policy != p.Name || result.Version != p.Version {
+				t.Fatalf("result names %s v%d; want %s v%d", result.Policy, result.Version, p.Name, p.Version)
+			}
+		})
+	}
+}
+
+func TestCheckPolicyReasonUsesDescription(t *testing.T) {
+	p := store.Policy{Name: "no-prod-writes", Version: 1, Expression: `request.level == "read"`, Description: "prod is read-only"}
+	t.Cleanup(func() { forgetProgram(p.Name) })
+
+	_, reason := checkPolicy(p, policyInput("write", ""))
+	if want := "denied by policy no-prod-writes: prod is read-only"; reason != want {
+		t.Fatalf("reason = %q; want %q", reason, want)
+	}
+}
+
+func TestStoredProgramRecompilesNewVersion(t *testing.T) {
+	input := policyInput("read", "")
+	p := store.Policy{Name: "versioned", Version: 1, Expression: `true`}
+	t.Cleanup(func() { forgetProgram(p.Name) })
+
+	if result, _ := checkPolicy(p, input); !result.Allowed {
+		t.Fatalf("version 1 denied: %q", result.Error)
+	}
+
+	// The same version is served from the cache
+	p.Expression = `false`
+	if result, _ := checkPolicy(p, input); !result.Allowed {
+		t.Fatalf("version 1 recompiled")
+	}
+
+	// A new version misses the cache and replaces the old program
+	p.Version = 2
+	if result, _ := checkPolicy(p, input); result.Allowed {
+		t.Fatalf("version 2 evaluated the cached program of version 1")
+	}
+	programsMu.Lock()
+	cached := programs[p.Name]
+	programsMu.Unlock()
+	if cached.version != 2 {
+		t.Fatalf("cached version = %d; want 2", cached.version)
+	}
+
+	// A version that does not compile fails closed
+	p.Version, p.Expression = 3, `user.name ==`
+	if result, _ := checkPolicy(p, input); result.Allowed || result.Error == "" {
+		t.Fatalf("uncompilable version 3 = %+v; want a failed evaluation", result)
+	}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	sub := &subject{
+		user: &store.User{Username: "alice"},
+		policies: []store.Policy{
+			{Name: "office-only", Version: 1, Expression: `request.ip.inCIDR("10.0.0.0/8")`},
+			{Name: "no-admin", Version: 4, Expression: `request.level != "admin"`},
+		},
+	}
+	t.Cleanup(func() {
+		for _, p := range sub.policies {
+			forgetProgram(p.Name)
+		}
+	})
+	db := &store.Database{Name: "orders", Type: "mysql"}
+
+	tests := []struct {
+		name    string
+		level   string
+		ip      string
+		allowed bool
+		reason  string
+	}{
+		{"every policy allows", "write", "10.0.0.9", true, ""},
+		{"one policy denies", "admin", "10.0.0.9", false, "denied by policy no-admin"},
+		{"first denial is reported", "admin", "192.0.2.1", false, "denied by policy office-only"},
+		{"unknown address", "read", "", false, "denied by policy office-only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Resolution{Allowed: true, Level: tt.level}
+			sub.applyPolicies(db, res, RequestContext{ClientIP: net.ParseIP(tt.ip)})
+			if res.Allowed != tt.allowed || res.Reason != tt.reason {
+				t.Fatalf("Allowed, Reason = %v, %q; want %v, %q", res.Allowed, res.Reason, tt.allowed, tt.reason)
+			}
+			if len(res.Policies) != len(sub.policies) {
+				t.Fatalf("%d policy results; want %d", len(res.Policies), len(sub.policies))
+			}
+		})
+	}
+
+	// Policies only take access away; a denied resolution is not evaluated
+	res := &Resolution{Allowed: false, Reason: "no grant"}
+	sub.applyPolicies(db, res, RequestContext{})
+	if res.Allowed || res.Reason != "no grant" || len(res.Policies) != 0 {
+		t.Fatalf("denied resolution changed to %+v", res)
+	}
+}
+
+// forgetProgram drops the cached program of the named policy
+func forgetProgram(name string) {
+	programsMu.Lock()
+	delete(programs, name)
+	programsMu.Unlock()
+}
@@ -0,0 +1,34 @@
+package audit
+
+// Session context keys under which the zGate identity is exposed to the backend
+const (
+	UserKey      = "zgate_user"
+	SessionIDKey = "zgate_session_id"
+	TicketKey    = "zgate_ticket"
+)
+
+// MaxTicketLength bounds the ticket reference a user may attach to a session
+const MaxTicketLength = 128
+
+// BreakGlassPrefix starts the ticket of every break-glass session, so backend
+// audit tells emergency access apart from normal sessions
+const BreakGlassPrefix = "break-glass:"
+
+// Identity is the human behind a temp principal, for database-native audit
+type Identity struct {
+	User      string
+	SessionID string
+	Ticket    string
+}
+
+// Attributes returns the identity keyed by the session context keys, omitting an empty ticket
+func (i Identity) Attributes() map[string]string {
+	attrs := map[string]string{
+		UserKey:      i.User,
+		SessionIDKey: i.SessionID,
+	}
+	if i.Ticket != "" {
+		attrs[TicketKey] = i.Ticket
+	}
+	return attrs
+}
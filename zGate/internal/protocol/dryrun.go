@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// ProvisioningStep is the outcome of one stage of a provisioning dry run
+type ProvisioningStep struct {
+	Name             string `json:"name"`
+	OK               bool   `json:"ok"`
+	Skipped          bool   `json:"skipped,omitempty"`
+	Error            string `json:"error,omitempty"`
+	MissingPrivilege string `json:"missing_privilege,omitempty"`
+	DurationMS       int64  `json:"duration_ms"`
+}
+
+// ProvisioningReport summarizes a provisioning dry run against a backend
+type ProvisioningReport struct {
+	Database  string             `json:"database"`
+	Type      string             `json:"type"`
+	Principal string             `json:"principal"`
+	OK        bool               `json:"ok"`
+	Steps     []ProvisioningStep `json:"steps"`
+}
+
+// requiredPrivileges describes, per database type and step, what the admin
+// account needs when that step fails
+var requiredPrivileges = map[string]map[string]string{
+	"mysql": {
+		"name":        "a valid username_template",
+		"connect":     "network access to backend_addr and valid admin credentials",
+		"create":      "CREATE USER",
+		"grant:read":  "SELECT WITH GRANT OPTION, CREATE ROLE, and SELECT on the mysql schema to read grants back",
+		"grant:write": "SELECT, INSERT, UPDATE, DELETE WITH GRANT OPTION, CREATE ROLE, and SELECT on the mysql schema to read grants back",
+		"grant:admin": "ALL PRIVILEGES WITH GRANT OPTION, CREATE ROLE, and SELECT on the mysql schema to read grants back",
+		"drop":        "CREATE USER",
+	},
+	"mssql": {
+		"name":        "a valid username_template",
+		"connect":     "network access to backend_addr and valid admin credentials",
+		"create":      "ALTER ANY LOGIN and ALTER ANY USER",
+		"grant:read":  "CREATE ROLE, ALTER ANY ROLE, and SELECT WITH GRANT OPTION (or CONTROL) on the database",
+		"grant:write": "CREATE ROLE, ALTER ANY ROLE, and SELECT, INSERT, UPDATE, DELETE WITH GRANT OPTION (or CONTROL) on the database",
+		"grant:admin": "CREATE ROLE and ALTER ANY ROLE, or membership in db_owner",
+		"drop":        "ALTER ANY LOGIN and ALTER ANY USER",
+	},
+}
+
+// TestProvisioning runs a full create, grant, verify and drop cycle against the
+// backend with a throwaway principal and reports the result of every step.
+// The principal is always dropped, even when an earlier step fails.
+func TestProvisioning(ctx context.Context, database store.Database) *ProvisioningReport {
+	report := &ProvisioningReport{
+		Database: database.Name,
+		Type:     database.Type,
+	}
+	password := GenerateTempPassword()
+
+	run := func(name string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		step := ProvisioningStep{
+			Name:       name,
+			OK:         err == nil,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			step.Error = err.Error()
+			step.MissingPrivilege = requiredPrivileges[database.Type][name]
+			if step.MissingPrivilege == "" && strings.HasPrefix(name, "grant:") {
+				step.MissingPrivilege = "CREATE ROLE, plus the privileges, role memberships and statements the custom level applies, with grant rights"
+			}
+		}
+		report.Steps = append(report.Steps, step)
+		return err == nil
+	}
+	skip := func(name string) {
+		report.Steps = append(report.Steps, ProvisioningStep{Name: name, Skipped: true})
+	}
+
+	named := run("name", func() error {
+		var err error
+		report.Principal, err = GenerateTempUsername(database, "dryrun")
+		return err
+	})
+
+	var mgr Manager
+	connected := named && run("connect", func() error {
+		var err error
+		mgr, err = NewManager(database)
+		return err
+	})
+	if !connected {
+		if !named {
+			skip("connect")
+		}
+		skip("create")
+		for _, level := range database.AvailablePermissions {
+			skip("grant:" + level)
+		}
+		skip("verify")
+		skip("drop")
+		return report
+	}
+	defer mgr.Close()
+
+	created := run("create", func() error {
+		return mgr.CreateTempUser(ctx, report.Principal, password, nil, time.Now().Add(BackendExpiryGrace))
+	})
+
+	for _, level := range database.AvailablePermissions {
+		if !created {
+			skip("grant:" + level)
+			continue
+		}
+		run("grant:"+level, func() error {
+			return mgr.GrantPermissions(ctx, report.Principal, []store.Permission{
+				{Database: database.Name, Level: level},
+			})
+		})
+	}
+
+	if created {
+		run("verify", func() error {
+			return mgr.VerifyLogin(ctx, report.Principal, password)
+		})
+	} else {
+		skip("verify")
+	}
+
+	// Always attempt the drop so a partially created principal does not linger
+	run("drop", func() error {
+		return mgr.DeleteTempUser(ctx, report.Principal)
+	})
+
+	report.OK = true
+	for _, step := range report.Steps {
+		if !step.OK {
+			report.OK = false
+			break
+		}
+	}
+
+	utils.Logger.Info("provisioning dry run finished",
+		"database", database.Name,
+		"principal", report.Principal,
+		"ok", report.OK,
+	)
+
+	return report
+}
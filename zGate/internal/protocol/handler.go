@@ -1,34 +1,49 @@
-package protocol
-
-import (
-	"context"
-	"fmt"
-	"net"
-
-	"github.com/zGate-Team/zGate-Platform/internal/protocol/mssql"
-	"github.com/zGate-Team/zGate-Platform/internal/protocol/mysql"
-)
-
-// Handler defines the interface for database connection handlers
-type Handler interface {
-	// Connect establishes a connection to the database
-	Connect(ctx context.Context, addr string) (net.Conn, error)
-
-	// GetType returns the database type (mssql, mysql, postgres)
-	GetType() string
-
-	// Close closes any resources held by the handler
-	Close() error
-}
-
-// NewHandler creates a handler for the specified database type
-func NewHandler(dbType string) (Handler, error) {
-	switch dbType {
-	case "mssql":
-		return mssql.NewHandler(), nil
-	case "mysql":
-		return mysql.NewHandler(), nil
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", dbType)
-	}
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/mssql"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/mysql"
+)
+
+// Handler defines the interface for database connection handlers
+type Handler interface {
+	// Connect establishes a connection to the database
+	Connect(ctx context.Context, addr string) (net.Conn, error)
+
+	// GetType returns the database type (mssql, mysql, postgres)
+	GetType() string
+
+	// Close closes any resources held by the handler
+	Close() error
+
+	// StatementTap returns a writer that reads one connection's client bytes and
+	// calls record with each statement sent, or unrecorded once with the reason it
+	// can no longer read them, e.g. because the client encrypted the connection
+	StatementTap(record, unrecorded func(string)) io.Writer
+}
+
+// StatementLog records the statements clients send through one session's proxy
+type StatementLog interface {
+	// Statement records a statement a client sent
+	Statement(text string)
+
+	// Unrecorded notes why some of the session's statements were not recorded
+	Unrecorded(reason string)
+}
+
+// NewHandler creates a handler for the specified database type
+func NewHandler(dbType string) (Handler, error) {
+	switch dbType {
+	case "mssql":
+		return mssql.NewHandler(), nil
+	case "mysql":
+		return mysql.NewHandler(), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
 }
\ No newline at end of file
@@ -1,65 +1,141 @@
-package protocol
-
-import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
-	"fmt"
-
-	"github.com/zGate-Team/zGate-Platform/internal/protocol/mssql"
-	"github.com/zGate-Team/zGate-Platform/internal/protocol/mysql"
-	"github.com/zGate-Team/zGate-Platform/internal/store"
-)
-
-// Manager defines the interface for database user management
-type Manager interface {
-	// CreateTempUser creates a temporary database user
-	CreateTempUser(ctx context.Context, username, password string, permissions []string) error
-
-	// DeleteTempUser removes a temporary database user
-	DeleteTempUser(ctx context.Context, username string) error
-
-	// Close closes the database connection
-	Close() error
-
-	// GetType returns the database type
-	GetType() string
-}
-
-// TempCredentials represents temporary user credentials
-type TempCredentials struct {
-	Username string
-	Password string
-}
-
-// NewManager creates a manager for the specified database
-func NewManager(database store.Database) (Manager, error) {
-	switch database.Type {
-	case "mssql":
-		return mssql.NewManager(database)
-	case "mysql":
-		return mysql.NewManager(database)
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", database.Type)
-	}
-}
-
-// GenerateTempUsername creates a unique temporary username
-func GenerateTempUsername(baseUsername string) string {
-	randomBytes := make([]byte, 4)
-	rand.Read(randomBytes)
-	suffix := hex.EncodeToString(randomBytes)
-	return fmt.Sprintf("zgate_%s_%s", baseUsername, suffix)
-}
-
-// GenerateTempPassword creates a secure random password
-func GenerateTempPassword() string {
-	randomBytes := make([]byte, 16)
-	rand.Read(randomBytes)
-	password := hex.EncodeToString(randomBytes)
-
-	// FIX: Added 'Z' and 'G' prefixes to ensuring Uppercase characters are present
-	// Format: Z<8chars>#<8chars>$G<8chars>
-	// This satisfies MySQL MEDIUM policy (Length, Number, Mixed Case, Special Char)
-	return fmt.Sprintf("Z%s#%s$G%s", password[:8], password[8:16], password[16:24])
-}
+package protocol
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/audit"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/mssql"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/mysql"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// Manager defines the interface for database user management
+type Manager interface {
+	// CreateTempUser creates a temporary database user. The backend itself
+	// disables it at expiresAt, so it does not outlive a gateway that goes away.
+	CreateTempUser(ctx context.Context, username, password string, permissions []store.Permission, expiresAt time.Time) error
+
+	// GrantPermissions grants permissions to an existing temp user and
+	// returns the first failure
+	GrantPermissions(ctx context.Context, username string, permissions []store.Permission) error
+
+	// SetIdentity attaches the zGate identity behind a temp user to it on the backend,
+	// so database-native audit and triggers can attribute its actions
+	SetIdentity(ctx context.Context, username string, identity audit.Identity) error
+
+	// ReplacePermissions revokes everything a temp user holds and grants permissions
+	// instead. Open backend connections are closed so none keeps the old privileges.
+	ReplacePermissions(ctx context.Context, username string, permissions []store.Permission) error
+
+	// VerifyLogin checks that the backend accepts the given credentials
+	VerifyLogin(ctx context.Context, username, password string) error
+
+	// DeleteTempUser removes a temporary database user
+	DeleteTempUser(ctx context.Context, username string) error
+
+	// Close closes the database connection
+	Close() error
+
+	// GetType returns the database type
+	GetType() string
+}
+
+// TempCredentials represents temporary user credentials
+type TempCredentials struct {
+	Username string
+	Password string
+}
+
+// DefaultPermissionLevels lists the permission levels every vendor manager can grant
+var DefaultPermissionLevels = []string{"read", "write", "admin"}
+
+// ObjectPrivileges lists the privileges allowed in object grants
+var ObjectPrivileges = []string{"SELECT", "INSERT", "UPDATE", "DELETE"}
+
+// LevelPrivileges caps the object privileges each default level may grant.
+// Levels not listed here (e.g. admin) are not capped.
+var LevelPrivileges = map[string][]string{
+	"read":  {"SELECT"},
+	"write": {"SELECT", "INSERT", "UPDATE", "DELETE"},
+}
+
+// IsSupportedType reports whether a vendor implementation exists for the database type
+func IsSupportedType(dbType string) bool {
+	switch dbType {
+	case "mssql", "mysql":
+		return true
+	default:
+		return false
+	}
+}
+
+// NewManager creates a manager for the specified database
+func NewManager(database store.Database) (Manager, error) {
+	switch database.Type {
+	case "mssql":
+		return mssql.NewManager(database)
+	case "mysql":
+		return mysql.NewManager(database)
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", database.Type)
+	}
+}
+
+// PlanGrants returns the statements granting permissions to a temp user named
+// username would run on database, without connecting to it
+func PlanGrants(database store.Database, username string, permissions []store.Permission) ([]string, error) {
+	switch database.Type {
+	case "mssql":
+		return mssql.PlanGrants(database, username, permissions)
+	case "mysql":
+		return mysql.PlanGrants(database, username, permissions)
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", database.Type)
+	}
+}
+
+// DefaultSessionLifetime applies when a database sets no max_session_minutes
+const DefaultSessionLifetime = 8 * time.Hour
+
+// BackendExpiryGrace is how long after a session's intended end the backend
+// disables its temp principal on its own, as a failsafe for the gateway
+const BackendExpiryGrace = 5 * time.Minute
+
+// SessionLifetime returns how long a session on database may last
+func SessionLifetime(database store.Database) time.Duration {
+	if database.MaxSessionMinutes <= 0 {
+		return DefaultSessionLifetime
+	}
+	return time.Duration(database.MaxSessionMinutes) * time.Minute
+}
+
+// ValidateLimits checks that resource limits are non-negative and supported by the database type.
+// MSSQL has no per-login query rate, so max_queries_per_hour is MySQL only.
+func ValidateLimits(dbType string, limits *store.ResourceLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.MaxConnections < 0 || limits.MaxQueriesPerHour < 0 || limits.MaxExecutionSeconds < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if dbType == "mssql" && limits.MaxQueriesPerHour > 0 {
+		return fmt.Errorf("max_queries_per_hour is not supported on mssql")
+	}
+	return nil
+}
+
+// GenerateTempPassword creates a secure random password
+func GenerateTempPassword() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	password := hex.EncodeToString(randomBytes)
+
+	// FIX: Added 'Z' and 'G' prefixes to ensuring Uppercase characters are present
+	// Format: Z<8chars>#<8chars>$G<8chars>
+	// This satisfies MySQL MEDIUM policy (Length, Number, Mixed Case, Special Char)
+	return fmt.Sprintf("Z%s#%s$G%s", password[:8], password[8:16], password[16:24])
+}
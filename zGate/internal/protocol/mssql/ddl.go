@@ -0,0 +1,425 @@
+package mssql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxIdentifierLength is the sysname limit for logins, users, schemas and objects
+const MaxIdentifierLength = 128
+
+// permissionPattern matches permission names such as SELECT or VIEW DEFINITION
+var permissionPattern = regexp.MustCompile(`^[A-Z]+( [A-Z]+)*$`)
+
+// ddl builds login, user and permission statements. Every identifier and literal
+// is validated and escaped here; Manager never splices raw names into SQL.
+type ddl struct{}
+
+// quoteIdent quotes a login, user, role, schema, table or column name with brackets
+func (ddl) quoteIdent(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("identifier must not be empty")
+	}
+	if len([]rune(name)) > MaxIdentifierLength {
+		return "", fmt.Errorf("identifier %q exceeds %d characters", name, MaxIdentifierLength)
+	}
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("identifier %q contains a NUL byte", name)
+	}
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]", nil
+}
+
+// quoteString quotes a Unicode string literal
+func (ddl) quoteString(value string) (string, error) {
+	if strings.ContainsRune(value, 0) {
+		return "", fmt.Errorf("literal must not contain NUL bytes")
+	}
+	return "N'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+}
+
+// createLogin returns a CREATE LOGIN for login that is skipped when the login already exists
+func (d ddl) createLogin(login, password, defaultDatabase string) (string, error) {
+	name, err := d.quoteString(login)
+	if err != nil {
+		return "", err
+	}
+	quotedLogin, err := d.quoteIdent(login)
+	if err != nil {
+		return "", err
+	}
+	secret, err := d.quoteString(password)
+	if err != nil {
+		return "", fmt.Errorf("password: %w", err)
+	}
+	database, err := d.quoteIdent(defaultDatabase)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.server_principals WHERE name = %s)
+		BEGIN
+			CREATE LOGIN %s WITH PASSWORD = %s, DEFAULT_DATABASE = %s
+		END`, name, quotedLogin, secret, database), nil
+}
+
+// createUser returns a CREATE USER mapped to the login of the same name, skipped when the user exists
+func (d ddl) createUser(user string) (string, error) {
+	name, err := d.quoteString(user)
+	if err != nil {
+		return "", err
+	}
+	quoted, err := d.quoteIdent(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.database_principals WHERE name = %s)
+		BEGIN
+			CREATE USER %s FOR LOGIN %s
+		END`, name, quoted, quoted), nil
+}
+
+// dropUser returns a DROP USER that is skipped when the user does not exist
+func (d ddl) dropUser(user string) (string, error) {
+	return d.dropPrincipal("sys.database_principals", "USER", user)
+}
+
+// dropLogin returns a DROP LOGIN that is skipped when the login does not exist
+func (d ddl) dropLogin(login string) (string, error) {
+	return d.dropPrincipal("sys.server_principals", "LOGIN", login)
+}
+
+func (d ddl) dropPrincipal(catalog, kind, principal string) (string, error) {
+	name, err := d.quoteString(principal)
+	if err != nil {
+		return "", err
+	}
+	quoted, err := d.quoteIdent(principal)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`IF EXISTS (SELECT * FROM %s WHERE name = %s)
+		BEGIN
+			DROP %s %s
+		END`, catalog, name, kind, quoted), nil
+}
+
+// createExpiryJob returns a one-shot SQL Agent job that disables login after seconds.
+// The job deletes itself once it has run.
+func (d ddl) createExpiryJob(login string, seconds int) (string, error) {
+	job, err := d.quoteString("zgate_expire_" + login)
+	if err != nil {
+		return "", err
+	}
+	quotedLogin, err := d.quoteIdent(login)
+	if err != nil {
+		return "", err
+	}
+	command, err := d.quoteString("ALTER LOGIN " + quotedLogin + " DISABLE")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`DECLARE @at DATETIME = DATEADD(SECOND, %d, GETDATE());
+		DECLARE @date INT = CONVERT(INT, CONVERT(CHAR(8), @at, 112));
+		DECLARE @time INT = DATEPART(HOUR, @at) * 10000 + DATEPART(MINUTE, @at) * 100 + DATEPART(SECOND, @at);
+		EXEC msdb.dbo.sp_add_job @job_name = %s, @delete_level = 1;
+		EXEC msdb.dbo.sp_add_jobstep @job_name = %s, @step_name = N'disable login', @subsystem = N'TSQL', @database_name = N'master', @command = %s;
+		EXEC msdb.dbo.sp_add_jobschedule @job_name = %s, @name = %s, @freq_type = 1, @active_start_date = @date, @active_start_time = @time;
+		EXEC msdb.dbo.sp_add_jobserver @job_name = %s;`,
+		seconds, job, job, command, job, job, job), nil
+}
+
+// deleteExpiryJob returns a statement that deletes login's expiry job if it still exists
+func (d ddl) deleteExpiryJob(login string) (string, error) {
+	job, err := d.quoteString("zgate_expire_" + login)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`IF EXISTS (SELECT * FROM msdb.dbo.sysjobs WHERE name = %s)
+		BEGIN
+			EXEC msdb.dbo.sp_delete_job @job_name = %s
+		END`, job, job), nil
+}
+
+// onSchema returns the ON SCHEMA:: clause for schema
+func (d ddl) onSchema(schema string) (string, error) {
+	quoted, err := d.quoteIdent(schema)
+	if err != nil {
+		return "", err
+	}
+	return " ON SCHEMA::" + quoted, nil
+}
+
+// onObject returns the ON OBJECT:: clause for schema.table, with an optional column list
+func (d ddl) onObject(schema, table string, columns []string) (string, error) {
+	quotedSchema, err := d.quoteIdent(schema)
+	if err != nil {
+		return "", err
+	}
+	quotedTable, err := d.quoteIdent(table)
+	if err != nil {
+		return "", err
+	}
+	on := fmt.Sprintf(" ON OBJECT::%s.%s", quotedSchema, quotedTable)
+
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, col := range columns {
+			if quoted[i], err = d.quoteIdent(col); err != nil {
+				return "", err
+			}
+		}
+		on += " (" + strings.Join(quoted, ", ") + ")"
+	}
+	return on, nil
+}
+
+// grant returns GRANT names to user; on is empty for database-wide grants
+// or must come from onSchema or onObject
+func (d ddl) grant(names []string, on, user string) (string, error) {
+	return d.permissionStatement("GRANT", "TO", names, on, user)
+}
+
+// revoke returns REVOKE names from user; on follows the same rules as for grant
+func (d ddl) revoke(names []string, on, user string) (string, error) {
+	return d.permissionStatement("REVOKE", "FROM", names, on, user)
+}
+
+func (d ddl) permissionStatement(verb, preposition string, names []string, on, user string) (string, error) {
+	if len(names) == 0 {
+		return "", fmt.Errorf("no permissions to %s", strings.ToLower(verb))
+	}
+	permissions := make([]string, len(names))
+	for i, name := range names {
+		permissions[i] = strings.ToUpper(name)
+		if !permissionPattern.MatchString(permissions[i]) {
+			return "", fmt.Errorf("invalid permission %q", name)
+		}
+	}
+	quotedUser, err := d.quoteIdent(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s%s %s %s", verb, strings.Join(permissions, ", "), on, preposition, quotedUser), nil
+}
+
+// createRole returns a CREATE ROLE that is skipped when the role already exists
+func (d ddl) createRole(role string) (string, error) {
+	name, err := d.quoteString(role)
+	if err != nil {
+		return "", err
+	}
+	quoted, err := d.quoteIdent(role)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.database_principals WHERE name = %s)
+		BEGIN
+			CREATE ROLE %s
+		END`, name, quoted), nil
+}
+
+// dropRole returns a DROP ROLE that is skipped when the role does not exist
+func (d ddl) dropRole(role string) (string, error) {
+	return d.dropPrincipal("sys.database_principals", "ROLE", role)
+}
+
+// addRoleMember returns ALTER ROLE role ADD MEMBER user
+func (d ddl) addRoleMember(role, user string) (string, error) {
+	quotedRole, err := d.quoteIdent(role)
+	if err != nil {
+		return "", err
+	}
+	quotedUser, err := d.quoteIdent(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ALTER ROLE %s ADD MEMBER %s", quotedRole, quotedUser), nil
+}
+
+// dropRoleMember returns ALTER ROLE role DROP MEMBER user
+func (d ddl) dropRoleMember(role, user string) (string, error) {
+	quotedRole, err := d.quoteIdent(role)
+	if err != nil {
+		return "", err
+	}
+	quotedUser, err := d.quoteIdent(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ALTER ROLE %s DROP MEMBER %s", quotedRole, quotedUser), nil
+}
+
+// Resource Governor classifier, installed in master once: logins listed in
+// dbo.zgate_workload_groups run in their workload group, everyone else in default.
+// It runs on every login to the instance, so it is a single indexed lookup.
+const (
+	createClassifierTable = `IF OBJECT_ID(N'dbo.zgate_workload_groups') IS NULL
+		CREATE TABLE dbo.zgate_workload_groups (login_name sysname NOT NULL PRIMARY KEY, group_name sysname NOT NULL)`
+	createClassifierFunction = `CREATE FUNCTION dbo.zgate_classifier() RETURNS sysname WITH SCHEMABINDING AS
+		BEGIN
+			RETURN ISNULL((SELECT group_name FROM dbo.zgate_workload_groups WHERE login_name = SUSER_SNAME()), N'default')
+		END`
+	enableClassifier = `ALTER RESOURCE GOVERNOR WITH (CLASSIFIER_FUNCTION = dbo.zgate_classifier);
+		ALTER RESOURCE GOVERNOR RECONFIGURE`
+)
+
+// inMaster wraps a batch so it runs in the master database, where zGate's server-wide objects live
+func (d ddl) inMaster(batch string) (string, error) {
+	quoted, err := d.quoteString(batch)
+	if err != nil {
+		return "", err
+	}
+	return "EXEC master.sys.sp_executesql " + quoted, nil
+}
+
+// workloadGroup returns the name of the workload group shared by every login
+// limited to maxCPUSeconds per request
+func (ddl) workloadGroup(maxCPUSeconds int) string {
+	return fmt.Sprintf("zgate_wg_cpu_%d", maxCPUSeconds)
+}
+
+// createWorkloadGroup returns a batch that creates the workload group for maxCPUSeconds
+// CPU per request and applies it, skipped when the group already exists
+func (d ddl) createWorkloadGroup(maxCPUSeconds int) (string, error) {
+	group := d.workloadGroup(maxCPUSeconds)
+	groupName, err := d.quoteString(group)
+	if err != nil {
+		return "", err
+	}
+	quotedGroup, err := d.quoteIdent(group)
+	if err != nil {
+		return "", err
+	}
+	return d.inMaster(fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.resource_governor_workload_groups WHERE name = %s)
+		BEGIN
+			CREATE WORKLOAD GROUP %s WITH (REQUEST_MAX_CPU_TIME_SEC = %d) USING [default];
+			ALTER RESOURCE GOVERNOR RECONFIGURE;
+		END`, groupName, quotedGroup, maxCPUSeconds))
+}
+
+// assignWorkloadGroup returns a batch that routes login's new sessions to the
+// workload group for maxCPUSeconds. The classifier reads the table at login,
+// so no reconfigure is needed.
+func (d ddl) assignWorkloadGroup(login string, maxCPUSeconds int) (string, error) {
+	groupName, err := d.quoteString(d.workloadGroup(maxCPUSeconds))
+	if err != nil {
+		return "", err
+	}
+	loginName, err := d.quoteString(login)
+	if err != nil {
+		return "", err
+	}
+	return d.inMaster(fmt.Sprintf(`DELETE FROM dbo.zgate_workload_groups WHERE login_name = %s;
+		INSERT INTO dbo.zgate_workload_groups (login_name, group_name) VALUES (%s, %s);`,
+		loginName, loginName, groupName))
+}
+
+// unassignWorkloadGroup returns a batch that stops routing login to a workload group
+func (d ddl) unassignWorkloadGroup(login string) (string, error) {
+	loginName, err := d.quoteString(login)
+	if err != nil {
+		return "", err
+	}
+	return d.inMaster(fmt.Sprintf(`IF OBJECT_ID(N'dbo.zgate_workload_groups') IS NOT NULL
+		DELETE FROM dbo.zgate_workload_groups WHERE login_name = %s`, loginName))
+}
+
+// setUserProperties returns a batch that stores attrs as extended properties of
+// database user user, readable with sys.extended_properties WHERE class = 4 AND
+// major_id = USER_ID(). They live in the target database and go with the user.
+func (d ddl) setUserProperties(user string, attrs map[string]string) (string, error) {
+	userName, err := d.quoteString(user)
+	if err != nil {
+		return "", err
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		name, err := d.quoteString(key)
+		if err != nil {
+			return "", err
+		}
+		value, err := d.quoteString(attrs[key])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "EXEC sys.sp_addextendedproperty @name = %s, @value = %s, @level0type = N'USER', @level0name = %s;\n", name, value, userName)
+	}
+	return b.String(), nil
+}
+
+// Session identity, installed in master only for databases that opt in with
+// identity_trigger: dbo.zgate_identities maps temp logins to the zGate identity,
+// and a logon trigger copies the caller's row into read-only session context. Logins read their own row through a view owned by dbo, so they
+// need no rights on the table. The lookup runs as dynamic SQL so that any error,
+// including a missing object, is caught and never blocks a login.
+const (
+	createIdentityTable = `IF OBJECT_ID(N'dbo.zgate_identities') IS NULL
+		CREATE TABLE dbo.zgate_identities (
+			login_name sysname NOT NULL PRIMARY KEY,
+			zgate_user nvarchar(256) NOT NULL,
+			session_id nvarchar(64) NOT NULL,
+			ticket nvarchar(128) NULL
+		)`
+	createIdentityView = `CREATE VIEW dbo.zgate_my_identity AS
+		SELECT zgate_user, session_id, ticket FROM dbo.zgate_identities WHERE login_name = ORIGINAL_LOGIN()`
+	grantIdentityView     = `GRANT SELECT ON dbo.zgate_my_identity TO public`
+	createIdentityTrigger = `CREATE TRIGGER zgate_session_context ON ALL SERVER FOR LOGON AS
+		BEGIN
+			BEGIN TRY
+				DECLARE @user nvarchar(256), @session nvarchar(64), @ticket nvarchar(128);
+				EXEC master.sys.sp_executesql
+					N'SELECT @user = zgate_user, @session = session_id, @ticket = ticket FROM master.dbo.zgate_my_identity',
+					N'@user nvarchar(256) OUTPUT, @session nvarchar(64) OUTPUT, @ticket nvarchar(128) OUTPUT',
+					@user OUTPUT, @session OUTPUT, @ticket OUTPUT;
+				IF @user IS NOT NULL
+				BEGIN
+					EXEC sys.sp_set_session_context @key = N'zgate_user', @value = @user, @read_only = 1;
+					EXEC sys.sp_set_session_context @key = N'zgate_session_id', @value = @session, @read_only = 1;
+					IF @ticket IS NOT NULL
+						EXEC sys.sp_set_session_context @key = N'zgate_ticket', @value = @ticket, @read_only = 1;
+				END
+			END TRY
+			BEGIN CATCH
+			END CATCH
+		END`
+)
+
+// setIdentity returns a batch that records the zGate identity behind login
+func (d ddl) setIdentity(login, user, sessionID, ticket string) (string, error) {
+	values := make([]string, 4)
+	for i, value := range []string{login, user, sessionID} {
+		quoted, err := d.quoteString(value)
+		if err != nil {
+			return "", err
+		}
+		values[i] = quoted
+	}
+	values[3] = "NULL"
+	if ticket != "" {
+		quoted, err := d.quoteString(ticket)
+		if err != nil {
+			return "", err
+		}
+		values[3] = quoted
+	}
+	return d.inMaster(fmt.Sprintf(`DELETE FROM dbo.zgate_identities WHERE login_name = %s;
+		INSERT INTO dbo.zgate_identities (login_name, zgate_user, session_id, ticket) VALUES (%s, %s, %s, %s);`,
+		values[0], values[0], values[1], values[2], values[3]))
+}
+
+// clearIdentity returns a batch that forgets login's identity, skipped when the table does not exist
+func (d ddl) clearIdentity(login string) (string, error) {
+	name, err := d.quoteString(login)
+	if err != nil {
+		return "", err
+	}
+	return d.inMaster(fmt.Sprintf(`IF OBJECT_ID(N'dbo.zgate_identities') IS NOT NULL
+		DELETE FROM dbo.zgate_identities WHERE login_name = %s`, name))
+}
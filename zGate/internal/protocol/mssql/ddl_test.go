@@ -0,0 +1,143 @@
+package mssql
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// injectionSeeds are inputs that try to end a literal or identifier early
+var injectionSeeds = []string{
+	"alice",
+	"o'brien",
+	"x'; DROP LOGIN sa; --",
+	"a]b",
+	"x]; DROP TABLE t; --",
+	"[x]",
+	"back`tick",
+	`back\slash`,
+	"nul\x00byte",
+	"ünïcødé",
+	"雪'][",
+	"''",
+	"]]",
+}
+
+// skeleton replaces every bracketed identifier and string literal in stmt with ? and
+// returns the decoded values in order. It fails on an unterminated quote, so output
+// that ends a literal or identifier early shows up as a different skeleton.
+func skeleton(t *testing.T, stmt string) (string, []string) {
+	t.Helper()
+
+	var shape strings.Builder
+	var values []string
+	for i := 0; i < len(stmt); {
+		closer := byte(0)
+		switch stmt[i] {
+		case '[':
+			closer = ']'
+		case '\'':
+			closer = '\''
+		default:
+			shape.WriteByte(stmt[i])
+			i++
+			continue
+		}
+
+		var value strings.Builder
+		closed := false
+		for i++; i < len(stmt); i++ {
+			if stmt[i] == closer {
+				if i+1 < len(stmt) && stmt[i+1] == closer {
+					value.WriteByte(closer)
+					i++
+					continue
+				}
+				closed = true
+				i++
+				break
+			}
+			value.WriteByte(stmt[i])
+		}
+		if !closed {
+			t.Fatalf("unterminated quote in %q", stmt)
+		}
+		shape.WriteString("?")
+		values = append(values, value.String())
+	}
+	return shape.String(), values
+}
+
+func addSeeds(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+}
+
+func FuzzQuoteIdent(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted, err := ddl{}.quoteIdent(name)
+		if err != nil {
+			return
+		}
+		shape, values := skeleton(t, quoted)
+		if shape != "?" || !slices.Equal(values, []string{name}) {
+			t.Fatalf("quoteIdent(%q) = %s, reads back as %q %q", name, quoted, shape, values)
+		}
+	})
+}
+
+func FuzzQuoteString(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, value string) {
+		quoted, err := ddl{}.quoteString(value)
+		if err != nil {
+			if !strings.ContainsRune(value, 0) {
+				t.Fatalf("quoteString(%q) failed: %v", value, err)
+			}
+			return
+		}
+		shape, values := skeleton(t, quoted)
+		if shape != "N?" || !slices.Equal(values, []string{value}) {
+			t.Fatalf("quoteString(%q) = %s, reads back as %q %q", value, quoted, shape, values)
+		}
+	})
+}
+
+func FuzzCreateUser(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, user string) {
+		want, _ := ddl{}.createUser("u")
+		wantShape, _ := skeleton(t, want)
+
+		stmt, err := ddl{}.createUser(user)
+		if err != nil {
+			return
+		}
+		shape, values := skeleton(t, stmt)
+		if shape != wantShape || !slices.Equal(values, []string{user, user, user}) {
+			t.Fatalf("createUser(%q) = %s, reads back as %q %q", user, stmt, shape, values)
+		}
+	})
+}
+
+func FuzzCreateLogin(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed, seed)
+		f.Add("zgate_alice", seed)
+	}
+	f.Fuzz(func(t *testing.T, login, password string) {
+		want, _ := ddl{}.createLogin("u", "p", "d")
+		wantShape, _ := skeleton(t, want)
+
+		stmt, err := ddl{}.createLogin(login, password, "master")
+		if err != nil {
+			return
+		}
+		shape, values := skeleton(t, stmt)
+		if shape != wantShape || !slices.Equal(values, []string{login, login, password, "master"}) {
+			t.Fatalf("createLogin(%q, %q) = %s, reads back as %q %q", login, password, stmt, shape, values)
+		}
+	})
+}
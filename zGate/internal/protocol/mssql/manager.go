@@ -1,147 +1,1012 @@
-package mssql
-
-import (
-	"context"
-	"database/sql"
-	"fmt"
-
-	_ "github.com/microsoft/go-mssqldb"
-	"github.com/zGate-Team/zGate-Platform/internal/store"
-	"github.com/zGate-Team/zGate-Platform/internal/utils"
-)
-
-// Manager implements protocol.Manager for MSSQL
-type Manager struct {
-	database store.Database
-	db       *sql.DB
-}
-
-// NewManager creates a new MSSQL manager
-func NewManager(database store.Database) (*Manager, error) {
-	connString := fmt.Sprintf(
-		"server=%s;user id=%s;password=%s;database=master",
-		database.BackendAddr,
-		database.AdminUsername,
-		database.AdminPassword,
-	)
-
-	db, err := sql.Open("sqlserver", connString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MSSQL: %w", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping MSSQL: %w", err)
-	}
-
-	utils.Logger.Info("MSSQL manager connected", "database", database.Name)
-
-	return &Manager{
-		database: database,
-		db:       db,
-	}, nil
-}
-
-// CreateTempUser creates a temporary MSSQL login and user
-func (m *Manager) CreateTempUser(ctx context.Context, username, password string, permissions []string) error {
-	utils.Logger.Info("creating temp MSSQL user",
-		"database", m.database.Name,
-		"username", username,
-	)
-
-	// Create LOGIN
-	createLoginSQL := fmt.Sprintf(
-		`IF NOT EXISTS (SELECT * FROM sys.server_principals WHERE name = '%s')
-		BEGIN
-			CREATE LOGIN [%s] WITH PASSWORD = '%s'
-		END`,
-		username, username, password,
-	)
-
-	if _, err := m.db.ExecContext(ctx, createLoginSQL); err != nil {
-		return fmt.Errorf("failed to create login: %w", err)
-	}
-
-	// Create USER
-	createUserSQL := fmt.Sprintf(
-		`IF NOT EXISTS (SELECT * FROM sys.database_principals WHERE name = '%s')
-		BEGIN
-			CREATE USER [%s] FOR LOGIN [%s]
-		END`,
-		username, username, username,
-	)
-
-	if _, err := m.db.ExecContext(ctx, createUserSQL); err != nil {
-		m.DeleteTempUser(ctx, username)
-		return fmt.Errorf("failed to create user: %w", err)
-	}
-
-	// Grant permissions
-	for _, perm := range permissions {
-		var grantSQL string
-
-		switch perm {
-		case "read":
-			grantSQL = fmt.Sprintf(`GRANT SELECT TO [%s]`, username)
-		case "write":
-			grantSQL = fmt.Sprintf(`GRANT SELECT, INSERT, UPDATE, DELETE TO [%s]`, username)
-		case "admin":
-			grantSQL = fmt.Sprintf(`ALTER ROLE db_owner ADD MEMBER [%s]`, username)
-		default:
-			utils.Logger.Warn("unknown permission", "permission", perm)
-			continue
-		}
-
-		if _, err := m.db.ExecContext(ctx, grantSQL); err != nil {
-			utils.Logger.Error("failed to grant permission", "permission", perm, "error", err)
-		}
-	}
-
-	utils.Logger.Info("temp MSSQL user created", "database", m.database.Name, "username", username)
-	return nil
-}
-
-// DeleteTempUser removes a temporary MSSQL user
-func (m *Manager) DeleteTempUser(ctx context.Context, username string) error {
-	utils.Logger.Info("deleting temp MSSQL user", "database", m.database.Name, "username", username)
-
-	// Drop USER
-	dropUserSQL := fmt.Sprintf(
-		`IF EXISTS (SELECT * FROM sys.database_principals WHERE name = '%s')
-		BEGIN
-			DROP USER [%s]
-		END`,
-		username, username,
-	)
-	m.db.ExecContext(ctx, dropUserSQL)
-
-	// Drop LOGIN
-	dropLoginSQL := fmt.Sprintf(
-		`IF EXISTS (SELECT * FROM sys.server_principals WHERE name = '%s')
-		BEGIN
-			DROP LOGIN [%s]
-		END`,
-		username, username,
-	)
-
-	if _, err := m.db.ExecContext(ctx, dropLoginSQL); err != nil {
-		return fmt.Errorf("failed to drop login: %w", err)
-	}
-
-	utils.Logger.Info("temp MSSQL user deleted", "database", m.database.Name, "username", username)
-	return nil
-}
-
-// Close closes the database connection
-func (m *Manager) Close() error {
-	if m.db != nil {
-		return m.db.Close()
-	}
-	return nil
-}
-
-// GetType returns the database type
-func (m *Manager) GetType() string {
-	return "mssql"
-}
+package mssql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/microsoft/go-mssqldb"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/audit"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/nativerole"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/privileges"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// builtinLevels are the levels granted without a custom definition
+var builtinLevels = []string{"read", "write", "admin"}
+
+// levelPrivileges lists what the read and write levels grant on the target database or schema
+var levelPrivileges = map[string][]string{
+	"read":  {"SELECT"},
+	"write": {"SELECT", "INSERT", "UPDATE", "DELETE"},
+}
+
+// watchInterval is how often sessions are checked against their resource limits
+const watchInterval = 2 * time.Second
+
+// dialTimeoutSeconds bounds how long connecting to the backend may take
+const dialTimeoutSeconds = 5
+
+// SERVERPROPERTY('EngineEdition') values of the editions with Resource Governor
+const (
+	engineEditionEnterprise      = 3 // Enterprise, Developer and Evaluation
+	engineEditionManagedInstance = 8
+)
+
+var (
+	workloadGroupsMu sync.Mutex
+	// workloadGroups records the backend workload groups created since zGate started
+	workloadGroups = map[string]bool{}
+)
+
+// Manager implements protocol.Manager for MSSQL
+type Manager struct {
+	database store.Database
+	db       *sql.DB
+	ddl      ddl
+
+	// stopWatch stops the session watchdog, if one is running
+	stopWatch context.CancelFunc
+	// planning collects grant statements into planned instead of running them
+	planning bool
+	planned  []string
+}
+
+// NewManager creates a new MSSQL manager
+func NewManager(database store.Database) (*Manager, error) {
+	connString := fmt.Sprintf(
+		"server=%s;user id=%s;password=%s;database=%s;dial timeout=%d",
+		database.BackendAddr,
+		database.AdminUsername,
+		database.AdminPassword,
+		targetDatabase(database),
+		dialTimeoutSeconds,
+	)
+
+	db, err := sql.Open("sqlserver", connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MSSQL: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping MSSQL: %w", err)
+	}
+
+	utils.Logger.Info("MSSQL manager connected", "database", database.Name, "target_database", targetDatabase(database))
+
+	return &Manager{
+		database: database,
+		db:       db,
+	}, nil
+}
+
+// CreateTempUser creates a temporary MSSQL login and user.
+// Provisioning is all-or-nothing: if any step fails or a grant does not take effect both are dropped again.
+// An Agent job disables the login at expiresAt, so it is useless even if zGate is gone.
+// The permissions' resource limits are enforced by a watchdog; a CPU limit also routes the login to a Resource Governor workload group.
+func (m *Manager) CreateTempUser(ctx context.Context, username, password string, permissions []store.Permission, expiresAt time.Time) error {
+	utils.Logger.Info("creating temp MSSQL user",
+		"database", m.database.Name,
+		"username", username,
+	)
+
+	// Refuse unknown levels up front rather than creating a user with no rights
+	for _, perm := range permissions {
+		if err := m.checkLevel(perm.Level); err != nil {
+			return err
+		}
+	}
+
+	// Create LOGIN
+	createLoginSQL, err := m.ddl.createLogin(username, password, targetDatabase(m.database))
+	if err != nil {
+		return fmt.Errorf("failed to create login: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, createLoginSQL); err != nil {
+		return fmt.Errorf("failed to create login: %w", err)
+	}
+
+	// Create USER
+	createUserSQL, err := m.ddl.createUser(username)
+	if err != nil {
+		m.rollback(ctx, username)
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, createUserSQL); err != nil {
+		m.rollback(ctx, username)
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Grant and verify permissions
+	if err := m.GrantPermissions(ctx, username, permissions); err != nil {
+		m.rollback(ctx, username)
+		return fmt.Errorf("failed to provision %s: %w", username, err)
+	}
+
+	// The watchdog enforces the limits; the workload group only adds Resource Governor's CPU accounting
+	limits := resourceLimits(permissions)
+	if limits.MaxExecutionSeconds > 0 {
+		if err := m.assignWorkloadGroup(ctx, username, limits.MaxExecutionSeconds); err != nil {
+			utils.Logger.Warn("failed to assign workload group, relying on the watchdog",
+				"database", m.database.Name, "username", username, "error", err)
+		}
+	}
+	if limits.MaxConnections > 0 || limits.MaxExecutionSeconds > 0 {
+		m.startWatchdog(username, limits)
+	}
+
+	m.scheduleExpiry(ctx, username, expiresAt)
+
+	utils.Logger.Info("temp MSSQL user created", "database", m.database.Name, "username", username)
+	return nil
+}
+
+// GrantPermissions grants permissions to an existing temp user, stopping at the first failure,
+// then verifies the user's effective permissions cover what was granted
+func (m *Manager) GrantPermissions(ctx context.Context, username string, permissions []store.Permission) error {
+	for _, perm := range permissions {
+		if err := m.grantPermission(ctx, username, perm); err != nil {
+			return err
+		}
+	}
+
+	return m.verifyPrivileges(ctx, username, permissions)
+}
+
+// PlanGrants returns the statements GrantPermissions would run for username,
+// without connecting to the backend. Whole-level grants include building the
+// level role, which only happens when the role is first used or its level changed.
+func PlanGrants(database store.Database, username string, permissions []store.Permission) ([]string, error) {
+	m := &Manager{database: database, planning: true, planned: []string{}}
+	for _, perm := range permissions {
+		if err := m.grantPermission(context.Background(), username, perm); err != nil {
+			return m.planned, err
+		}
+	}
+	return m.planned, nil
+}
+
+// exec runs a grant statement, or only collects it when planning
+func (m *Manager) exec(ctx context.Context, query string) error {
+	if m.planning {
+		m.planned = append(m.planned, query)
+		return nil
+	}
+	_, err := m.db.ExecContext(ctx, query)
+	return err
+}
+
+// scheduleExpiry creates a SQL Agent job that disables the login at expiresAt.
+// SQL Agent is not available everywhere (Azure SQL Database has none), so this is best effort.
+func (m *Manager) scheduleExpiry(ctx context.Context, username string, expiresAt time.Time) {
+	seconds := max(1, int(time.Until(expiresAt).Seconds()))
+	jobSQL, err := m.ddl.createExpiryJob(username, seconds)
+	if err == nil {
+		_, err = m.db.ExecContext(ctx, jobSQL)
+	}
+	if err != nil {
+		utils.Logger.Warn("failed to schedule login expiry, the login is only removed by zGate",
+			"database", m.database.Name, "username", username, "error", err)
+	}
+}
+
+// resourceLimits combines the limits of every permission, keeping the strictest
+func resourceLimits(permissions []store.Permission) store.ResourceLimits {
+	var limits *store.ResourceLimits
+	for _, perm := range permissions {
+		limits = store.StricterLimits(limits, perm.Limits)
+	}
+	if limits == nil {
+		return store.ResourceLimits{}
+	}
+	return *limits
+}
+
+// assignWorkloadGroup routes username's sessions into the shared workload group for its
+// CPU limit per request, creating the group the first time zGate needs it. Resource Governor
+// needs Enterprise or Developer edition (or Managed Instance) and CONTROL SERVER; on other
+// editions, or when someone else's classifier is configured, it is skipped with a warning.
+func (m *Manager) assignWorkloadGroup(ctx context.Context, username string, maxCPUSeconds int) error {
+	var edition int
+	if err := m.db.QueryRowContext(ctx, `SELECT CONVERT(INT, SERVERPROPERTY('EngineEdition'))`).Scan(&edition); err != nil {
+		return fmt.Errorf("read engine edition: %w", err)
+	}
+	if edition != engineEditionEnterprise && edition != engineEditionManagedInstance {
+		utils.Logger.Warn("Resource Governor is not available on this edition, limits are enforced by the watchdog only",
+			"database", m.database.Name, "engine_edition", edition)
+		return nil
+	}
+
+	if err := m.installClassifier(ctx); err != nil {
+		return err
+	}
+
+	key := m.database.BackendAddr + "/" + m.ddl.workloadGroup(maxCPUSeconds)
+	workloadGroupsMu.Lock()
+	created := workloadGroups[key]
+	workloadGroupsMu.Unlock()
+	if !created {
+		groupSQL, err := m.ddl.createWorkloadGroup(maxCPUSeconds)
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, groupSQL); err != nil {
+			return fmt.Errorf("create workload group: %w", err)
+		}
+		workloadGroupsMu.Lock()
+		workloadGroups[key] = true
+		workloadGroupsMu.Unlock()
+	}
+
+	assignSQL, err := m.ddl.assignWorkloadGroup(username, maxCPUSeconds)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, assignSQL); err != nil {
+		return fmt.Errorf("assign workload group: %w", err)
+	}
+	return nil
+}
+
+// installClassifier makes zGate's classifier function the Resource Governor classifier.
+// It refuses to replace a classifier configured by someone else.
+func (m *Manager) installClassifier(ctx context.Context) error {
+	var configured int64
+	var ours sql.NullInt64
+	if err := m.db.QueryRowContext(ctx, `
+		SELECT classifier_function_id, OBJECT_ID(N'master.dbo.zgate_classifier')
+		FROM sys.resource_governor_configuration`).Scan(&configured, &ours); err != nil {
+		return fmt.Errorf("read resource governor configuration: %w", err)
+	}
+	if configured != 0 && ours.Valid && configured == ours.Int64 {
+		return nil
+	}
+	if configured != 0 {
+		return fmt.Errorf("another resource governor classifier function is configured")
+	}
+
+	batches := []string{enableClassifier}
+	if !ours.Valid {
+		batches = []string{createClassifierTable, createClassifierFunction, enableClassifier}
+	}
+	for _, batch := range batches {
+		stmt, err := m.ddl.inMaster(batch)
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("install classifier: %w", err)
+		}
+	}
+	return nil
+}
+
+// startWatchdog kills username's requests that run longer than max_execution_seconds
+// and its newest sessions beyond max_connections. Resource Governor only reports CPU
+// overruns and caps requests, not sessions, so zGate enforces both itself; the admin
+// login needs VIEW SERVER STATE and ALTER ANY CONNECTION.
+func (m *Manager) startWatchdog(username string, limits store.ResourceLimits) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopWatch = cancel
+
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := m.enforceLimits(ctx, username, limits); err != nil && ctx.Err() == nil {
+				utils.Logger.Warn("failed to enforce resource limits", "database", m.database.Name, "username", username, "error", err)
+			}
+		}
+	}()
+}
+
+// enforceLimits kills the sessions of username that break limits
+func (m *Manager) enforceLimits(ctx context.Context, username string, limits store.ResourceLimits) error {
+	var victims []int64
+
+	if limits.MaxExecutionSeconds > 0 {
+		ids, err := m.sessionIDs(ctx, `
+			SELECT r.session_id
+			FROM sys.dm_exec_requests r
+			JOIN sys.dm_exec_sessions s ON r.session_id = s.session_id
+			WHERE s.login_name = @p1 AND r.total_elapsed_time >= @p2`,
+			username, limits.MaxExecutionSeconds*1000)
+		if err != nil {
+			return err
+		}
+		victims = append(victims, ids...)
+	}
+
+	if limits.MaxConnections > 0 {
+		ids, err := m.sessionIDs(ctx, `
+			SELECT session_id FROM sys.dm_exec_sessions
+			WHERE login_name = @p1 ORDER BY login_time DESC`, username)
+		if err != nil {
+			return err
+		}
+		if extra := len(ids) - limits.MaxConnections; extra > 0 {
+			victims = append(victims, ids[:extra]...)
+		}
+	}
+
+	for _, id := range victims {
+		utils.Logger.Warn("killing session over resource limits", "database", m.database.Name, "username", username, "session", id)
+		if _, err := m.db.ExecContext(ctx, fmt.Sprintf("KILL %d", id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionIDs runs a query returning session IDs
+func (m *Manager) sessionIDs(ctx context.Context, query string, args ...any) ([]int64, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// stopWatchdog stops the session watchdog, if one is running
+func (m *Manager) stopWatchdog() {
+	if m.stopWatch != nil {
+		m.stopWatch()
+		m.stopWatch = nil
+	}
+}
+
+// rollback drops a partially provisioned login and user, even when ctx is already cancelled
+func (m *Manager) rollback(ctx context.Context, username string) {
+	if err := m.DeleteTempUser(context.WithoutCancel(ctx), username); err != nil {
+		utils.Logger.Error("failed to roll back temp user", "database", m.database.Name, "username", username, "error", err)
+	}
+}
+
+// grantPermission grants a single permission to a temp user.
+// Whole-level permissions add the user to the level's database role;
+// object grants replace the level's database-wide grants and go to the user directly.
+func (m *Manager) grantPermission(ctx context.Context, username string, perm store.Permission) error {
+	if err := m.checkLevel(perm.Level); err != nil {
+		return err
+	}
+
+	if len(perm.Objects) > 0 {
+		if level, ok := m.database.Level(perm.Level); ok {
+			if err := m.applyLevel(ctx, username, level, perm.Objects); err != nil {
+				return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+			}
+			return nil
+		}
+		for _, obj := range perm.Objects {
+			if err := m.grantObject(ctx, username, obj); err != nil {
+				return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+			}
+		}
+		return nil
+	}
+
+	role, err := m.ensureLevelRole(ctx, perm.Level)
+	if err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+
+	alterSQL, err := m.ddl.addRoleMember(role, username)
+	if err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+	if err := m.exec(ctx, alterSQL); err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+	return nil
+}
+
+// ensureLevelRole returns the level's database role, building it first if this
+// version of the level's definition was not built since zGate started
+func (m *Manager) ensureLevelRole(ctx context.Context, level string) (string, error) {
+	role := nativerole.Name(m.database, level)
+	if m.planning {
+		createSQL, err := m.ddl.createRole(role)
+		if err != nil {
+			return "", err
+		}
+		m.planned = append(m.planned, createSQL)
+		return role, m.grantLevel(ctx, role, level)
+	}
+
+	key := m.database.BackendAddr + "/" + targetDatabase(m.database) + "/" + role
+
+	err := nativerole.Ensure(key, nativerole.Fingerprint(m.database, level), func() error {
+		return m.reconcileLevelRole(ctx, role, level)
+	})
+	if err != nil {
+		return "", fmt.Errorf("level role %s: %w", role, err)
+	}
+	return role, nil
+}
+
+// reconcileLevelRole builds role with everything level grants and reads its permissions back.
+// The role is named after the level's definition, so it is only ever granted to, never
+// revoked from: a changed level gets a new role, and session sync moves live sessions
+// onto it. Older versions of the role are dropped once no one holds them.
+func (m *Manager) reconcileLevelRole(ctx context.Context, role, level string) error {
+	utils.Logger.Info("reconciling MSSQL level role", "database", m.database.Name, "level", level, "role", role)
+
+	createSQL, err := m.ddl.createRole(role)
+	if err != nil {
+		return err
+	}
+	if err := m.exec(ctx, createSQL); err != nil {
+		return fmt.Errorf("create role: %w", err)
+	}
+
+	if err := m.grantLevel(ctx, role, level); err != nil {
+		return err
+	}
+
+	want, wantRoles := m.levelGrants(level, nil)
+	have, haveRoles, err := m.effectivePrivileges(ctx, role)
+	if err != nil {
+		return fmt.Errorf("failed to read back permissions: %w", err)
+	}
+	if err := privileges.Check(role, want, have, wantRoles, haveRoles); err != nil {
+		return err
+	}
+
+	m.dropStaleLevelRoles(ctx, role, level)
+	return nil
+}
+
+// dropStaleLevelRoles drops older versions of level's role that have no members left.
+// Versions still held by a session are kept until a later reconcile finds them empty.
+func (m *Manager) dropStaleLevelRoles(ctx context.Context, current, level string) {
+	prefix := nativerole.Prefix(m.database.Name, level)
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT r.name FROM sys.database_principals r
+		WHERE r.type = 'R' AND LEFT(r.name, @p1) = @p2 AND r.name <> @p3
+		AND NOT EXISTS (SELECT 1 FROM sys.database_role_members rm WHERE rm.role_principal_id = r.principal_id)
+	`, len(prefix), prefix, current)
+	if err != nil {
+		utils.Logger.Warn("failed to list stale level roles", "database", m.database.Name, "level", level, "error", err)
+		return
+	}
+	var stale []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err == nil {
+			stale = append(stale, role)
+		}
+	}
+	rows.Close()
+
+	for _, role := range stale {
+		dropSQL, err := m.ddl.dropRole(role)
+		if err == nil {
+			_, err = m.db.ExecContext(ctx, dropSQL)
+		}
+		if err != nil {
+			utils.Logger.Warn("failed to drop stale level role", "database", m.database.Name, "role", role, "error", err)
+			continue
+		}
+		utils.Logger.Info("dropped stale level role", "database", m.database.Name, "role", role)
+	}
+}
+
+// resetPrincipal revokes every permission and role membership held by a temp user
+func (m *Manager) resetPrincipal(ctx context.Context, principal string) error {
+	current, memberOf, err := m.effectivePrivileges(ctx, principal)
+	if err != nil {
+		return fmt.Errorf("read permissions: %w", err)
+	}
+	for _, priv := range current {
+		// Temp users keep CONNECT, which CREATE USER granted
+		if priv.Name == "CONNECT" && priv.Schema == "" {
+			continue
+		}
+		revokeSQL, err := m.revokeStatement(priv, principal)
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, revokeSQL); err != nil {
+			return fmt.Errorf("revoke %s: %w", priv, err)
+		}
+	}
+	for _, parent := range memberOf {
+		dropSQL, err := m.ddl.dropRoleMember(parent, principal)
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, dropSQL); err != nil {
+			return fmt.Errorf("leave role %s: %w", parent, err)
+		}
+	}
+	return nil
+}
+
+// ReplacePermissions swaps a temp user's permissions for new ones: everything is revoked,
+// permissions are granted and verified, then the login's sessions are killed.
+// MSSQL fixes role membership when a session enters the database, so only new
+// sessions are guaranteed to see the change.
+func (m *Manager) ReplacePermissions(ctx context.Context, username string, permissions []store.Permission) error {
+	for _, perm := range permissions {
+		if err := m.checkLevel(perm.Level); err != nil {
+			return err
+		}
+	}
+
+	if err := m.resetPrincipal(ctx, username); err != nil {
+		return fmt.Errorf("failed to revoke permissions: %w", err)
+	}
+	if err := m.GrantPermissions(ctx, username, permissions); err != nil {
+		return err
+	}
+
+	ids, err := m.sessionIDs(ctx, `SELECT session_id FROM sys.dm_exec_sessions WHERE login_name = @p1`, username)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, id := range ids {
+		if _, err := m.db.ExecContext(ctx, fmt.Sprintf("KILL %d", id)); err != nil {
+			return fmt.Errorf("failed to kill session %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// revokeStatement returns the REVOKE undoing a permission read by effectivePrivileges
+func (m *Manager) revokeStatement(priv privileges.Privilege, principal string) (string, error) {
+	var on string
+	var err error
+	switch {
+	case priv.Table != "":
+		var columns []string
+		if priv.Column != "" {
+			columns = []string{priv.Column}
+		}
+		on, err = m.ddl.onObject(priv.Schema, priv.Table, columns)
+	case priv.Schema != "":
+		on, err = m.ddl.onSchema(priv.Schema)
+	}
+	if err != nil {
+		return "", err
+	}
+	return m.ddl.revoke([]string{priv.Name}, on, principal)
+}
+
+// grantLevel grants everything level gives on the target database or schema to grantee
+func (m *Manager) grantLevel(ctx context.Context, grantee, level string) error {
+	if custom, ok := m.database.Level(level); ok {
+		return m.applyLevel(ctx, grantee, custom, custom.Objects)
+	}
+
+	scope, err := m.schemaScope()
+	if err != nil {
+		return err
+	}
+
+	var grantSQL string
+
+	switch level {
+	case "read", "write":
+		grantSQL, err = m.ddl.grant(levelPrivileges[level], scope, grantee)
+	case "admin":
+		if m.database.TargetSchema != "" {
+			grantSQL, err = m.ddl.grant([]string{"CONTROL"}, scope, grantee)
+		} else {
+			grantSQL, err = m.ddl.addRoleMember("db_owner", grantee)
+		}
+	default:
+		return fmt.Errorf("permission level %q has no definition", level)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := m.exec(ctx, grantSQL); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkLevel rejects levels the database does not offer or that have no definition
+func (m *Manager) checkLevel(level string) error {
+	if !slices.Contains(m.database.AvailablePermissions, level) {
+		return fmt.Errorf("permission level %q is not available on database %s", level, m.database.Name)
+	}
+	if _, ok := m.database.Level(level); ok || slices.Contains(builtinLevels, level) {
+		return nil
+	}
+	return fmt.Errorf("permission level %q has no definition", level)
+}
+
+// applyLevel applies a custom level to grantee, a temp user or a level role:
+// object grants, then role memberships, then SQL statements
+func (m *Manager) applyLevel(ctx context.Context, grantee string, level *store.PermissionLevel, objects []store.ObjectGrant) error {
+	for _, obj := range objects {
+		if err := m.grantObject(ctx, grantee, obj); err != nil {
+			return err
+		}
+	}
+
+	for _, role := range level.NativeRoles {
+		alterSQL, err := m.ddl.addRoleMember(role, grantee)
+		if err != nil {
+			return fmt.Errorf("add to role %s: %w", role, err)
+		}
+		if err := m.exec(ctx, alterSQL); err != nil {
+			return fmt.Errorf("add to role %s: %w", role, err)
+		}
+	}
+
+	for i, stmt := range level.Statements {
+		expanded, err := m.expandTemplate(stmt, grantee)
+		if err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		if err := m.exec(ctx, expanded); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// expandTemplate replaces level statement placeholders with quoted identifiers
+func (m *Manager) expandTemplate(stmt, grantee string) (string, error) {
+	schema := m.database.TargetSchema
+	if schema == "" {
+		schema = "dbo"
+	}
+
+	var quoted [3]string
+	for i, name := range []string{grantee, targetDatabase(m.database), schema} {
+		var err error
+		if quoted[i], err = m.ddl.quoteIdent(name); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.NewReplacer(
+		"{{user}}", quoted[0],
+		"{{database}}", quoted[1],
+		"{{schema}}", quoted[2],
+	).Replace(stmt), nil
+}
+
+// grantObject grants privileges on a schema, table or column list.
+// Tables without a schema default to the target schema, then dbo.
+func (m *Manager) grantObject(ctx context.Context, username string, obj store.ObjectGrant) error {
+	schema := obj.Schema
+	if schema == "" {
+		schema = m.database.TargetSchema
+	}
+
+	var on string
+	var err error
+	switch {
+	case obj.Table != "":
+		if schema == "" {
+			schema = "dbo"
+		}
+		on, err = m.ddl.onObject(schema, obj.Table, obj.Columns)
+	case len(obj.Columns) > 0:
+		return fmt.Errorf("column grants need a table")
+	case schema != "":
+		on, err = m.ddl.onSchema(schema)
+	}
+	if err != nil {
+		return fmt.Errorf("grant on %s.%s: %w", schema, obj.Table, err)
+	}
+
+	grantSQL, err := m.ddl.grant(obj.Privileges, on, username)
+	if err != nil {
+		return fmt.Errorf("grant%s: %w", on, err)
+	}
+
+	if err := m.exec(ctx, grantSQL); err != nil {
+		return fmt.Errorf("grant%s: %w", on, err)
+	}
+	return nil
+}
+
+// targetDatabase returns the database temp users are created in; master when none is configured
+func targetDatabase(database store.Database) string {
+	if database.TargetDatabase == "" {
+		return "master"
+	}
+	return database.TargetDatabase
+}
+
+// schemaScope returns the ON clause restricting grants to TargetSchema.
+// Without a schema, grants apply to the whole target database.
+func (m *Manager) schemaScope() (string, error) {
+	if m.database.TargetSchema == "" {
+		return "", nil
+	}
+	return m.ddl.onSchema(m.database.TargetSchema)
+}
+
+// verifyPrivileges checks that the user's effective permissions and role memberships cover what permissions grant.
+// Custom level statements are free-form and cannot be checked.
+func (m *Manager) verifyPrivileges(ctx context.Context, username string, permissions []store.Permission) error {
+	want, wantRoles := m.expectedPrivileges(permissions)
+	if len(want) == 0 && len(wantRoles) == 0 {
+		return nil
+	}
+
+	have, haveRoles, err := m.effectivePrivileges(ctx, username)
+	if err != nil {
+		return fmt.Errorf("failed to read back permissions: %w", err)
+	}
+	return privileges.Check(username, want, have, wantRoles, haveRoles)
+}
+
+// expectedPrivileges returns the permissions and roles permissions should leave the user with.
+// Whole-level permissions only need membership of the level role, which was verified when it was built.
+func (m *Manager) expectedPrivileges(permissions []store.Permission) ([]privileges.Privilege, []string) {
+	var want []privileges.Privilege
+	var roles []string
+
+	for _, perm := range permissions {
+		if len(perm.Objects) == 0 {
+			roles = append(roles, nativerole.Name(m.database, perm.Level))
+			continue
+		}
+		levelWant, levelRoles := m.levelGrants(perm.Level, perm.Objects)
+		want = append(want, levelWant...)
+		roles = append(roles, levelRoles...)
+	}
+	return want, roles
+}
+
+// levelGrants returns the permissions and roles level grants, restricted to objects when given
+func (m *Manager) levelGrants(level string, objects []store.ObjectGrant) ([]privileges.Privilege, []string) {
+	var want []privileges.Privilege
+	var roles []string
+
+	if custom, ok := m.database.Level(level); ok {
+		if len(objects) == 0 {
+			objects = custom.Objects
+		}
+		roles = append(roles, custom.NativeRoles...)
+	} else if len(objects) == 0 {
+		switch {
+		case level != "admin":
+			for _, name := range levelPrivileges[level] {
+				want = append(want, privileges.Privilege{Name: name, Schema: m.database.TargetSchema})
+			}
+		case m.database.TargetSchema != "":
+			want = append(want, privileges.Privilege{Name: "CONTROL", Schema: m.database.TargetSchema})
+		default:
+			roles = append(roles, "db_owner")
+		}
+	}
+
+	for _, obj := range objects {
+		schema := obj.Schema
+		if schema == "" {
+			schema = m.database.TargetSchema
+		}
+		if obj.Table != "" && schema == "" {
+			schema = "dbo"
+		}
+		for _, priv := range obj.Privileges {
+			if len(obj.Columns) == 0 {
+				want = append(want, privileges.Privilege{Name: strings.ToUpper(priv), Schema: schema, Table: obj.Table})
+				continue
+			}
+			for _, col := range obj.Columns {
+				want = append(want, privileges.Privilege{Name: strings.ToUpper(priv), Schema: schema, Table: obj.Table, Column: col})
+			}
+		}
+	}
+	return want, roles
+}
+
+// effectivePrivileges reads the user's granted permissions and role memberships in the target database
+func (m *Manager) effectivePrivileges(ctx context.Context, username string) ([]privileges.Privilege, []string, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT p.permission_name,
+			ISNULL(CASE p.class WHEN 3 THEN SCHEMA_NAME(p.major_id) WHEN 1 THEN OBJECT_SCHEMA_NAME(p.major_id) END, ''),
+			ISNULL(CASE p.class WHEN 1 THEN OBJECT_NAME(p.major_id) END, ''),
+			ISNULL(CASE WHEN p.class = 1 AND p.minor_id > 0 THEN COL_NAME(p.major_id, p.minor_id) END, '')
+		FROM sys.database_permissions p
+		JOIN sys.database_principals u ON p.grantee_principal_id = u.principal_id
+		WHERE u.name = @p1 AND p.state IN ('G', 'W') AND p.class IN (0, 1, 3)`, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var have []privileges.Privilege
+	for rows.Next() {
+		var p privileges.Privilege
+		if err := rows.Scan(&p.Name, &p.Schema, &p.Table, &p.Column); err != nil {
+			return nil, nil, err
+		}
+		have = append(have, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	roleRows, err := m.db.QueryContext(ctx, `
+		SELECT r.name
+		FROM sys.database_role_members rm
+		JOIN sys.database_principals r ON rm.role_principal_id = r.principal_id
+		JOIN sys.database_principals u ON rm.member_principal_id = u.principal_id
+		WHERE u.name = @p1`, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer roleRows.Close()
+
+	var roles []string
+	for roleRows.Next() {
+		var role string
+		if err := roleRows.Scan(&role); err != nil {
+			return nil, nil, err
+		}
+		roles = append(roles, role)
+	}
+	return have, roles, roleRows.Err()
+}
+
+// SetIdentity records the zGate identity behind username as extended properties of its
+// database user, so audit and triggers can look it up with USER_ID(). When the database
+// opts in with identity_trigger, a server-wide logon trigger also copies it into read-only
+// session context on every connection, readable with SESSION_CONTEXT(N'zgate_user').
+// The trigger needs CONTROL SERVER, and logins must be able to enter master as guest.
+func (m *Manager) SetIdentity(ctx context.Context, username string, identity audit.Identity) error {
+	propertiesSQL, err := m.ddl.setUserProperties(username, identity.Attributes())
+	if err != nil {
+		return fmt.Errorf("failed to set identity: %w", err)
+	}
+	if _, err := m.db.ExecContext(ctx, propertiesSQL); err != nil {
+		return fmt.Errorf("failed to set identity: %w", err)
+	}
+	if !m.database.IdentityTrigger {
+		return nil
+	}
+
+	if err := m.installIdentityTrigger(ctx); err != nil {
+		return fmt.Errorf("failed to set session context identity: %w", err)
+	}
+	identitySQL, err := m.ddl.setIdentity(username, identity.User, identity.SessionID, identity.Ticket)
+	if err != nil {
+		return fmt.Errorf("failed to set session context identity: %w", err)
+	}
+	if _, err := m.db.ExecContext(ctx, identitySQL); err != nil {
+		return fmt.Errorf("failed to set session context identity: %w", err)
+	}
+	return nil
+}
+
+// installIdentityTrigger creates the identity table, view and logon trigger in master unless the trigger exists
+func (m *Manager) installIdentityTrigger(ctx context.Context) error {
+	var view sql.NullInt64
+	var triggers int
+	if err := m.db.QueryRowContext(ctx, `
+		SELECT OBJECT_ID(N'master.dbo.zgate_my_identity'),
+			(SELECT COUNT(*) FROM sys.server_triggers WHERE name = N'zgate_session_context')`).Scan(&view, &triggers); err != nil {
+		return fmt.Errorf("read identity trigger: %w", err)
+	}
+	if triggers > 0 {
+		return nil
+	}
+
+	batches := []string{createIdentityTable}
+	if !view.Valid {
+		batches = append(batches, createIdentityView)
+	}
+	batches = append(batches, grantIdentityView, createIdentityTrigger)
+
+	for _, batch := range batches {
+		stmt, err := m.ddl.inMaster(batch)
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("install identity trigger: %w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyLogin opens a connection to the backend as the given login
+func (m *Manager) VerifyLogin(ctx context.Context, username, password string) error {
+	connString := fmt.Sprintf(
+		"server=%s;user id=%s;password=%s;database=%s;dial timeout=%d",
+		m.database.BackendAddr,
+		username,
+		password,
+		targetDatabase(m.database),
+		dialTimeoutSeconds,
+	)
+
+	db, err := sql.Open("sqlserver", connString)
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to log in as %s: %w", username, err)
+	}
+	return nil
+}
+
+// DeleteTempUser removes a temporary MSSQL user
+func (m *Manager) DeleteTempUser(ctx context.Context, username string) error {
+	utils.Logger.Info("deleting temp MSSQL user", "database", m.database.Name, "username", username)
+	m.stopWatchdog()
+
+	// Drop USER
+	dropUserSQL, err := m.ddl.dropUser(username)
+	if err != nil {
+		return fmt.Errorf("failed to drop user: %w", err)
+	}
+	m.db.ExecContext(ctx, dropUserSQL)
+
+	// Drop LOGIN
+	dropLoginSQL, err := m.ddl.dropLogin(username)
+	if err != nil {
+		return fmt.Errorf("failed to drop login: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, dropLoginSQL); err != nil {
+		return fmt.Errorf("failed to drop login: %w", err)
+	}
+
+	// The expiry job, workload group assignment and identity are no longer needed once the login is gone.
+	// Workload groups are shared by limit and stay for the next login.
+	if deleteJobSQL, err := m.ddl.deleteExpiryJob(username); err == nil {
+		m.db.ExecContext(ctx, deleteJobSQL)
+	}
+	if unassignSQL, err := m.ddl.unassignWorkloadGroup(username); err == nil {
+		if _, err := m.db.ExecContext(ctx, unassignSQL); err != nil {
+			utils.Logger.Warn("failed to unassign workload group", "database", m.database.Name, "username", username, "error", err)
+		}
+	}
+	if clearSQL, err := m.ddl.clearIdentity(username); err == nil {
+		m.db.ExecContext(ctx, clearSQL)
+	}
+
+	utils.Logger.Info("temp MSSQL user deleted", "database", m.database.Name, "username", username)
+	return nil
+}
+
+// Close closes the database connection
+func (m *Manager) Close() error {
+	m.stopWatchdog()
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// GetType returns the database type
+func (m *Manager) GetType() string {
+	return "mssql"
+}
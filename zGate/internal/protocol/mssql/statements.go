@@ -0,0 +1,426 @@
+package mssql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// TDS packet types the statement tap reads or passes over
+const (
+	packetSQLBatch    = 0x01
+	packetRPC         = 0x03
+	packetAttention   = 0x06
+	packetBulkLoad    = 0x07
+	packetFedAuth     = 0x08
+	packetTransaction = 0x0e
+	packetLogin7      = 0x10
+	packetSSPI        = 0x11
+	packetPrelogin    = 0x12
+
+	statusEndOfMessage = 0x01
+	packetHeaderSize   = 8
+)
+
+// TLS record types; after the handshake, which travels inside PRELOGIN packets,
+// encrypted data is sent as bare TLS records
+const (
+	tlsHandshake       = 0x16
+	tlsApplicationData = 0x17
+	tlsRecordHeader    = 5
+)
+
+// Well-known procedures a client calls by ID in an RPC request
+const (
+	procExecuteSQL = 10
+	procPrepare    = 11
+	procExecute    = 12
+	procPrepExec   = 13
+)
+
+// TDS types of the RPC parameters the tap decodes
+const (
+	typeIntN     = 0x26
+	typeNVarChar = 0xe7
+	plpLength    = 0xffff
+)
+
+// maxStatementBytes bounds how much of one message the tap keeps; longer statements are cut
+const maxStatementBytes = 128 << 10
+
+// statementTap reads the client side of a TDS connection and reports the text of
+// every SQL batch and RPC call. It only sees what is on the wire: the login may be
+// encrypted, but once the client encrypts requests as well the tap gives up.
+type statementTap struct {
+	record     func(string)
+	unrecorded func(string)
+
+	buf []byte
+	// skip counts bytes of a packet or TLS record the tap does not keep
+	skip    int
+	started bool
+	// loginEncrypted is set once the encrypted login has gone by
+	loginEncrypted bool
+	stopped        bool
+
+	// message collects the packets of one request
+	message     []byte
+	messageType byte
+	messageSize int
+}
+
+// StatementTap returns a writer to copy the client's bytes into. It calls record
+// with each statement, literals redacted, and unrecorded once with the reason it
+// stops reading.
+// Writes never fail, so it can sit in the forwarding path.
+func (h *Handler) StatementTap(record, unrecorded func(string)) io.Writer {
+	return &statementTap{record: record, unrecorded: unrecorded}
+}
+
+func (t *statementTap) Write(p []byte) (int, error) {
+	if t.stopped {
+		return len(p), nil
+	}
+	t.buf = append(t.buf, p...)
+	pending := t.buf
+loop:
+	for !t.stopped {
+		if t.skip > 0 {
+			n := min(t.skip, len(pending))
+			t.skip -= n
+			pending = pending[n:]
+			if t.skip > 0 {
+				break
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		switch pending[0] {
+		case tlsHandshake, tlsApplicationData:
+			if len(pending) < tlsRecordHeader {
+				break loop
+			}
+			t.tlsRecord(pending[0])
+			t.skip = tlsRecordHeader + int(binary.BigEndian.Uint16(pending[3:5]))
+			continue
+		}
+
+		if len(pending) < packetHeaderSize {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(pending[2:4]))
+		if length < packetHeaderSize {
+			t.stop("client sent an unexpected TDS packet")
+			break
+		}
+		if len(pending) < length {
+			break
+		}
+		t.packet(pending[0], pending[1], pending[packetHeaderSize:length])
+		pending = pending[length:]
+	}
+	if t.stopped {
+		t.buf = nil
+		return len(p), nil
+	}
+	t.buf = append(t.buf[:0], pending...)
+	return len(p), nil
+}
+
+// tlsRecord handles a bare TLS record of the given type
+func (t *statementTap) tlsRecord(recordType byte) {
+	switch {
+	case !t.started:
+		// TDS 8 encrypts the connection from the first byte
+		t.stop("client encrypted the connection with TLS")
+	case recordType == tlsApplicationData && !t.loginEncrypted:
+		// With encryption off, only the login is encrypted and requests follow in the clear
+		t.loginEncrypted = true
+	case recordType == tlsApplicationData:
+		t.stop("client encrypted the connection with TLS")
+	}
+}
+
+// packet handles one TDS packet
+func (t *statementTap) packet(packetType, status byte, payload []byte) {
+	t.started = true
+	switch packetType {
+	case packetSQLBatch, packetRPC:
+	case packetPrelogin, packetLogin7, packetSSPI, packetFedAuth, packetAttention, packetTransaction, packetBulkLoad:
+		return
+	default:
+		t.stop(fmt.Sprintf("client sent unknown TDS packet type %#x", packetType))
+		return
+	}
+
+	if t.messageType != packetType {
+		t.message = t.message[:0]
+		t.messageSize = 0
+		t.messageType = packetType
+	}
+	t.messageSize += len(payload)
+	if room := maxStatementBytes - len(t.message); room > 0 {
+		t.message = append(t.message, payload[:min(room, len(payload))]...)
+	}
+	if status&statusEndOfMessage == 0 {
+		return
+	}
+
+	statement := t.statement()
+	t.messageType = 0
+	if statement == "" {
+		return
+	}
+	if t.messageSize > maxStatementBytes {
+		statement += fmt.Sprintf(" -- cut at %d of %d bytes", len(t.message), t.messageSize)
+	}
+	t.record(statement)
+}
+
+// statement returns the text of the collected request
+func (t *statementTap) statement() string {
+	body, ok := skipAllHeaders(t.message)
+	if !ok {
+		return "-- request not recorded: unexpected headers"
+	}
+	if t.messageType == packetSQLBatch {
+		return redact(decodeUCS2(body))
+	}
+	return rpcStatement(body)
+}
+
+// skipAllHeaders skips the ALL_HEADERS block that starts every TDS 7.2+ request
+func skipAllHeaders(message []byte) ([]byte, bool) {
+	if len(message) < 4 {
+		return nil, false
+	}
+	total := int(binary.LittleEndian.Uint32(message))
+	if total < 4 || total > len(message) {
+		return nil, false
+	}
+	return message[total:], true
+}
+
+// rpcStatement describes an RPC request: the statement text for the procedures
+// that execute or prepare one, and EXEC with the procedure name otherwise.
+// A batch of several RPC requests is described by its first one.
+func rpcStatement(body []byte) string {
+	r := &reader{data: body}
+	nameLength := r.uint16()
+	if nameLength != 0xffff {
+		name := decodeUCS2(r.bytes(2 * int(nameLength)))
+		if r.failed {
+			return "-- RPC not recorded: truncated request"
+		}
+		return "EXEC " + name
+	}
+
+	procID := r.uint16()
+	r.uint16() // option flags
+	var statementParam int
+	switch procID {
+	case procExecuteSQL:
+		statementParam = 0
+	case procPrepare, procPrepExec:
+		// @handle OUTPUT, @params, @stmt
+		statementParam = 2
+	case procExecute:
+		if handle, ok := r.param(); ok {
+			return "EXEC sp_execute " + handle
+		}
+		return "EXEC sp_execute"
+	default:
+		return fmt.Sprintf("EXEC procedure %d", procID)
+	}
+
+	for i := 0; i < statementParam; i++ {
+		if _, ok := r.param(); !ok {
+			return "-- RPC not recorded: unsupported parameter"
+		}
+	}
+	statement, ok := r.param()
+	if !ok {
+		return "-- RPC not recorded: unsupported parameter"
+	}
+	return redact(statement)
+}
+
+// redact replaces every string and binary literal in statement with ?, so
+// passwords in CREATE LOGIN and ALTER LOGIN and the values a batch carries are
+// never stored. Identifiers in brackets or double quotes are kept. A literal
+// left open runs to the end of the statement.
+func redact(statement string) string {
+	var b strings.Builder
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case c == '[' || c == '"':
+			end := literalEnd(statement, i)
+			b.WriteString(statement[i:end])
+			i = end
+			continue
+		case c == '\'':
+			i = literalEnd(statement, i)
+		case (c == 'N' || c == 'n') && i+1 < len(statement) && statement[i+1] == '\'' && !inWord(statement, i):
+			i = literalEnd(statement, i+1)
+		case c == '0' && i+1 < len(statement) && (statement[i+1] == 'x' || statement[i+1] == 'X') && !inWord(statement, i):
+			i += 2
+			for i < len(statement) && isWordByte(statement[i]) {
+				i++
+			}
+		default:
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		b.WriteByte('?')
+	}
+	return b.String()
+}
+
+// literalEnd returns the index just past the quote closing the literal or
+// delimited identifier that opens at start; a doubled closing quote stands for itself
+func literalEnd(statement string, start int) int {
+	closing := statement[start]
+	if closing == '[' {
+		closing = ']'
+	}
+	for i := start + 1; i < len(statement); i++ {
+		if statement[i] != closing {
+			continue
+		}
+		if i+1 < len(statement) && statement[i+1] == closing {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(statement)
+}
+
+// inWord reports whether the byte at i continues an identifier or number
+func inWord(statement string, i int) bool {
+	return i > 0 && isWordByte(statement[i-1])
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '@' || c == '#' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// reader decodes the little-endian fields of an RPC request; reads past the end
+// return zero values and set failed
+type reader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || n > len(r.data) {
+		r.failed = true
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// param reads one RPC parameter and returns its value as text. Only the integer
+// and Unicode string types clients use for sp_executesql and friends are decoded.
+func (r *reader) param() (string, bool) {
+	r.bytes(2 * int(r.uint8())) // parameter name
+	r.uint8()                   // status flags
+
+	var value string
+	switch r.uint8() {
+	case typeIntN:
+		r.uint8() // maximum length
+		switch n := r.uint8(); n {
+		case 0:
+			value = "NULL"
+		case 1, 2, 4, 8:
+			var v uint64
+			for i, b := range r.bytes(int(n)) {
+				v |= uint64(b) << (8 * i)
+			}
+			value = fmt.Sprint(signExtend(v, int(n)))
+		default:
+			return "", false
+		}
+	case typeNVarChar:
+		maxLength := r.uint16()
+		r.bytes(5) // collation
+		if maxLength == plpLength {
+			value = r.plp()
+		} else if n := r.uint16(); n == 0xffff {
+			value = "NULL"
+		} else {
+			value = decodeUCS2(r.bytes(int(n)))
+		}
+	default:
+		return "", false
+	}
+	return value, !r.failed
+}
+
+// plp reads a partially length-prefixed value, as nvarchar(max) is sent
+func (r *reader) plp() string {
+	if total := r.bytes(8); total == nil || binary.LittleEndian.Uint64(total) == 0xffffffffffffffff {
+		return "NULL"
+	}
+	var b strings.Builder
+	for !r.failed {
+		n := r.uint32()
+		if n == 0 {
+			break
+		}
+		b.WriteString(decodeUCS2(r.bytes(int(n))))
+	}
+	return b.String()
+}
+
+// signExtend interprets the low n bytes of v as a signed integer
+func signExtend(v uint64, n int) int64 {
+	shift := 64 - 8*n
+	return int64(v<<shift) >> shift
+}
+
+// decodeUCS2 decodes UTF-16LE text, dropping a trailing odd byte
+func decodeUCS2(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// stop reports why statements are no longer read and ignores the rest of the connection
+func (t *statementTap) stop(reason string) {
+	t.stopped = true
+	t.unrecorded(reason)
+}
@@ -0,0 +1,280 @@
+package mssql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// FuzzStatementTap feeds arbitrary client bytes, split at any point, to the
+// statement tap, which sits in the forwarding path and must never panic
+func FuzzStatementTap(f *testing.F) {
+	f.Add([]byte("\x01\x01\x00\x10\x00\x00\x01\x00\x04\x00\x00\x00S\x00;\x00"), 3)
+	f.Fuzz(func(t *testing.T, data []byte, split int) {
+		tap := (&Handler{}).StatementTap(func(string) {}, func(string) {})
+		if split > 0 && split < len(data) {
+			tap.Write(data[:split])
+			data = data[split:]
+		}
+		if n, err := tap.Write(data); n != len(data) || err != nil {
+			t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(data))
+		}
+	})
+}
+
+// tdsPacket frames payload as one TDS packet
+func tdsPacket(packetType, status byte, payload []byte) []byte {
+	header := []byte{packetType, status, 0, 0, 0, 0, 1, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(packetHeaderSize+len(payload)))
+	return append(header, payload...)
+}
+
+// message frames payload as a message split into packets of at most size payload bytes
+func message(packetType byte, payload []byte, size int) []byte {
+	var out []byte
+	for {
+		n := min(size, len(payload))
+		status := byte(0)
+		if n == len(payload) {
+			status = statusEndOfMessage
+		}
+		out = append(out, tdsPacket(packetType, status, payload[:n])...)
+		if payload = payload[n:]; len(payload) == 0 {
+			return out
+		}
+	}
+}
+
+// allHeaders is the ALL_HEADERS block with the transaction descriptor header every request carries
+func allHeaders() []byte {
+	b := binary.LittleEndian.AppendUint32(nil, 22)
+	b = binary.LittleEndian.AppendUint32(b, 18)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = append(b, make([]byte, 8)...)             // transaction descriptor
+	return binary.LittleEndian.AppendUint32(b, 1) // outstanding requests
+}
+
+func ucs2(s string) []byte {
+	var b []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, unit)
+	}
+	return b
+}
+
+func sqlBatch(text string) []byte {
+	return append(allHeaders(), ucs2(text)...)
+}
+
+// rpcByID calls one of the procedures clients call by ID
+func rpcByID(procID uint16, params ...[]byte) []byte {
+	b := binary.LittleEndian.AppendUint16(allHeaders(), 0xffff)
+	b = binary.LittleEndian.AppendUint16(b, procID)
+	b = binary.LittleEndian.AppendUint16(b, 0) // option flags
+	return slices.Concat(append([][]byte{b}, params...)...)
+}
+
+// rpcByName calls a procedure by name
+func rpcByName(name string, params ...[]byte) []byte {
+	b := binary.LittleEndian.AppendUint16(allHeaders(), uint16(len(utf16.Encode([]rune(name)))))
+	b = append(b, ucs2(name)...)
+	b = binary.LittleEndian.AppendUint16(b, 0) // option flags
+	return slices.Concat(append([][]byte{b}, params...)...)
+}
+
+// paramHeader starts an RPC parameter: its name, status flags and type
+func paramHeader(name string, status, typ byte) []byte {
+	b := []byte{byte(len(name))}
+	return append(append(b, ucs2(name)...), status, typ)
+}
+
+var collation = []byte{0x09, 0x04, 0xd0, 0x00, 0x34}
+
+func nvarcharParam(name, value string) []byte {
+	b := binary.LittleEndian.AppendUint16(paramHeader(name, 0, typeNVarChar), 8000)
+	b = append(b, collation...)
+	b = binary.LittleEndian.AppendUint16(b, uint16(2*len(utf16.Encode([]rune(value)))))
+	return append(b, ucs2(value)...)
+}
+
+// nvarcharMaxParam sends value as nvarchar(max), in chunks of two characters
+func nvarcharMaxParam(name, value string) []byte {
+	b := binary.LittleEndian.AppendUint16(paramHeader(name, 0, typeNVarChar), plpLength)
+	b = append(b, collation...)
+	data := ucs2(value)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(data)))
+	for len(data) > 0 {
+		n := min(4, len(data))
+		b = binary.LittleEndian.AppendUint32(b, uint32(n))
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	return binary.LittleEndian.AppendUint32(b, 0)
+}
+
+func intParam(name string, value int32) []byte {
+	b := append(paramHeader(name, 0, typeIntN), 4, 4)
+	return binary.LittleEndian.AppendUint32(b, uint32(value))
+}
+
+// nullHandleParam is the @handle OUTPUT parameter of sp_prepare and sp_prepexec
+func nullHandleParam() []byte {
+	return append(paramHeader("@handle", 0x01, typeIntN), 4, 0)
+}
+
+// login opens a connection the way a client with encryption off does: PRELOGIN,
+// then the login inside one TLS record
+func login() []byte {
+	tlsLogin := []byte{tlsApplicationData, 0x03, 0x03, 0, 6, 1, 2, 3, 4, 5, 6}
+	return slices.Concat(message(packetPrelogin, []byte("prelogin options"), 4096), tlsLogin)
+}
+
+// tapStream writes stream to a new tap in chunks of chunk bytes and returns
+// what it recorded and why it stopped, if it did
+func tapStream(stream []byte, chunk int) (recorded, unrecorded []string) {
+	tap := (&Handler{}).StatementTap(
+		func(s string) { recorded = append(recorded, s) },
+		func(s string) { unrecorded = append(unrecorded, s) },
+	)
+	for len(stream) > 0 {
+		n := min(chunk, len(stream))
+		tap.Write(stream[:n])
+		stream = stream[n:]
+	}
+	return recorded, unrecorded
+}
+
+func TestStatementTapRecordsRequests(t *testing.T) {
+	stream := slices.Concat(
+		login(),
+		message(packetSQLBatch, sqlBatch("SELECT * FROM orders WHERE email = N'ann@example.com'"), 40),
+		message(packetRPC, rpcByID(procExecuteSQL,
+			nvarcharMaxParam("", "UPDATE invoices SET paid = @p0 WHERE number = 'INV-1'"),
+			nvarcharParam("", "@p0 int"),
+			intParam("@p0", 1),
+		), 4096),
+		message(packetRPC, rpcByID(procPrepExec,
+			nullHandleParam(),
+			nvarcharParam("@params", "@p1 nvarchar(128)"),
+			nvarcharParam("@stmt", "SELECT name FROM sys.tables WHERE name = @p1"),
+			nvarcharParam("@p1", "orders"),
+		), 4096),
+		message(packetRPC, rpcByID(procExecute, intParam("@handle", 7), nvarcharParam("@p1", "orders")), 4096),
+		message(packetRPC, rpcByName("dbo.usp_refund", nvarcharParam("@card", "4111111111111111")), 4096),
+		tdsPacket(packetAttention, statusEndOfMessage, nil),
+		message(packetSQLBatch, sqlBatch("CREATE LOGIN [bob] WITH PASSWORD = 'hunter2', CHECK_POLICY = OFF"), 4096),
+		message(packetSQLBatch, sqlBatch("ALTER LOGIN bob WITH PASSWORD = 0x0200ABCDEF HASHED"), 4096),
+	)
+	want := []string{
+		"SELECT * FROM orders WHERE email = ?",
+		"UPDATE invoices SET paid = @p0 WHERE number = ?",
+		"SELECT name FROM sys.tables WHERE name = @p1",
+		"EXEC sp_execute 7",
+		"EXEC dbo.usp_refund",
+		"CREATE LOGIN [bob] WITH PASSWORD = ?, CHECK_POLICY = OFF",
+		"ALTER LOGIN bob WITH PASSWORD = ? HASHED",
+	}
+
+	// However the stream is split, the same statements come out
+	for _, chunk := range []int{len(stream), 1, 3, 7, 64} {
+		recorded, unrecorded := tapStream(stream, chunk)
+		if !slices.Equal(recorded, want) {
+			t.Fatalf("in chunks of %d: recorded %q; want %q", chunk, recorded, want)
+		}
+		if len(unrecorded) != 0 {
+			t.Fatalf("in chunks of %d: stopped: %q", chunk, unrecorded)
+		}
+	}
+}
+
+func TestStatementTapLongBatch(t *testing.T) {
+	text := "SELECT 1" + strings.Repeat(" ", maxStatementBytes)
+	payload := sqlBatch(text)
+	stream := slices.Concat(
+		login(),
+		message(packetSQLBatch, payload, 4088),
+		message(packetSQLBatch, sqlBatch("SELECT 2"), 4088),
+	)
+
+	recorded, _ := tapStream(stream, 1000)
+	if len(recorded) != 2 {
+		t.Fatalf("recorded %d statements; want 2", len(recorded))
+	}
+	suffix := fmt.Sprintf(" -- cut at %d of %d bytes", maxStatementBytes, len(payload))
+	if !strings.HasPrefix(recorded[0], "SELECT 1 ") || !strings.HasSuffix(recorded[0], suffix) {
+		t.Fatalf("long batch recorded as %.20q...; want SELECT 1 ...%q", recorded[0], suffix)
+	}
+	if recorded[1] != "SELECT 2" {
+		t.Fatalf("batch after the long one recorded as %q", recorded[1])
+	}
+}
+
+func TestStatementTapStops(t *testing.T) {
+	encrypted := []byte{tlsApplicationData, 0x03, 0x03, 0, 2, 0xaa, 0xbb}
+	tests := []struct {
+		name   string
+		stream []byte
+		want   []string
+		reason string
+	}{
+		{
+			name:   "requests encrypted after the login",
+			stream: slices.Concat(login(), message(packetSQLBatch, sqlBatch("SELECT 1"), 4096), encrypted),
+			want:   []string{"SELECT 1"},
+			reason: "client encrypted the connection with TLS",
+		},
+		{
+			name:   "TDS 8",
+			stream: []byte{tlsHandshake, 0x03, 0x01, 0, 2, 0x01, 0x00},
+			reason: "client encrypted the connection with TLS",
+		},
+		{
+			name:   "unknown packet type",
+			stream: slices.Concat(login(), tdsPacket(0x42, statusEndOfMessage, []byte("??"))),
+			reason: "client sent unknown TDS packet type 0x42",
+		},
+		{
+			name:   "short packet length",
+			stream: slices.Concat(login(), []byte{packetSQLBatch, statusEndOfMessage, 0, 4, 0, 0, 1, 0}),
+			reason: "client sent an unexpected TDS packet",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := slices.Concat(tt.stream, message(packetSQLBatch, sqlBatch("SELECT 2"), 4096))
+			recorded, unrecorded := tapStream(stream, 5)
+			if !slices.Equal(recorded, tt.want) {
+				t.Fatalf("recorded %q; want %q", recorded, tt.want)
+			}
+			if !slices.Equal(unrecorded, []string{tt.reason}) {
+				t.Fatalf("unrecorded %q; want %q once", unrecorded, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = 'x' AND b = N'y'", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"SELECT 'it''s', n'ü'", "SELECT ?, ?"},
+		{`SELECT [we'ird], "quoted'id", [a]]b] FROM t WHERE c = 'x'`, `SELECT [we'ird], "quoted'id", [a]]b] FROM t WHERE c = ?`},
+		{"CREATE CREDENTIAL c WITH IDENTITY = 'svc', SECRET = 'k3y'", "CREATE CREDENTIAL c WITH IDENTITY = ?, SECRET = ?"},
+		{"SELECT 0x0200ABCD, 0X1f, @p0x1, #t0x2, col0x3, N", "SELECT ?, ?, @p0x1, #t0x2, col0x3, N"},
+		{"SELECT 42, 3.14, -7e3, $1.50", "SELECT 42, 3.14, -7e3, $1.50"},
+		{"SELECT colN'x'", "SELECT colN?"},
+		{"INSERT INTO t VALUES ('unterminated", "INSERT INTO t VALUES (?"},
+		{"SELECT 'x' -- it's a comment", "SELECT ? -- it?"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.statement); got != tt.want {
+			t.Errorf("redact(%q) = %q; want %q", tt.statement, got, tt.want)
+		}
+	}
+}
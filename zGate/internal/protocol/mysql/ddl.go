@@ -0,0 +1,269 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MySQL limits account names to 32 characters and identifiers to 64
+const (
+	MaxUserLength       = 32
+	maxIdentifierLength = 64
+)
+
+// privilegePattern matches privilege names such as SELECT or CREATE TEMPORARY TABLES
+var privilegePattern = regexp.MustCompile(`^[A-Z]+( [A-Z]+)*$`)
+
+// ddl builds account and privilege statements. Every identifier and literal
+// is validated and escaped here; Manager never splices raw names into SQL.
+type ddl struct {
+	// noBackslashEscapes is set when the server runs with the NO_BACKSLASH_ESCAPES
+	// sql_mode, where a backslash in a literal is an ordinary character
+	noBackslashEscapes bool
+}
+
+// quoteIdent quotes a schema, table, column or role name with backticks
+func (ddl) quoteIdent(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("identifier must not be empty")
+	}
+	if len(name) > maxIdentifierLength {
+		return "", fmt.Errorf("identifier %q exceeds %d characters", name, maxIdentifierLength)
+	}
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("identifier %q contains a NUL byte", name)
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`", nil
+}
+
+// quoteString quotes a string literal. Quotes are doubled, and so are backslashes
+// unless the server takes them literally under NO_BACKSLASH_ESCAPES.
+func (d ddl) quoteString(value string) (string, error) {
+	if strings.ContainsRune(value, 0) {
+		return "", fmt.Errorf("literal must not contain NUL bytes")
+	}
+	if !d.noBackslashEscapes {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+}
+
+// account returns the quoted 'user'@'%' account name
+func (d ddl) account(user string) (string, error) {
+	if user == "" || len(user) > MaxUserLength {
+		return "", fmt.Errorf("user name %q must be 1 to %d characters", user, MaxUserLength)
+	}
+	quoted, err := d.quoteString(user)
+	if err != nil {
+		return "", fmt.Errorf("user name %q: %w", user, err)
+	}
+	return quoted + "@'%'", nil
+}
+
+// createUser returns CREATE USER IF NOT EXISTS for user identified by password,
+// limited to maxConnections concurrent connections, maxQueriesPerHour statements
+// per hour (0 is unlimited) and a password that expires after expireDays
+func (d ddl) createUser(user, password string, maxConnections, maxQueriesPerHour, expireDays int) (string, error) {
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	secret, err := d.quoteString(password)
+	if err != nil {
+		return "", fmt.Errorf("password: %w", err)
+	}
+	return fmt.Sprintf("CREATE USER IF NOT EXISTS %s IDENTIFIED BY %s WITH MAX_USER_CONNECTIONS %d MAX_QUERIES_PER_HOUR %d PASSWORD EXPIRE INTERVAL %d DAY",
+		account, secret, maxConnections, maxQueriesPerHour, expireDays), nil
+}
+
+// dropUser returns DROP USER IF EXISTS for user
+func (d ddl) dropUser(user string) (string, error) {
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return "DROP USER IF EXISTS " + account, nil
+}
+
+// createRole returns CREATE ROLE IF NOT EXISTS for role. Roles are accounts, so
+// every role statement names them as 'role'@'%' through account.
+func (d ddl) createRole(role string) (string, error) {
+	account, err := d.account(role)
+	if err != nil {
+		return "", err
+	}
+	return "CREATE ROLE IF NOT EXISTS " + account, nil
+}
+
+// dropRole returns DROP ROLE IF EXISTS for role
+func (d ddl) dropRole(role string) (string, error) {
+	account, err := d.account(role)
+	if err != nil {
+		return "", err
+	}
+	return "DROP ROLE IF EXISTS " + account, nil
+}
+
+// setAttributes returns ALTER USER ... ATTRIBUTE, merging attrs into user's JSON attributes
+func (d ddl) setAttributes(user string, attrs map[string]string) (string, error) {
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(attrs); err != nil {
+		return "", err
+	}
+	literal, err := d.quoteString(strings.TrimSpace(buf.String()))
+	if err != nil {
+		return "", fmt.Errorf("attributes: %w", err)
+	}
+	return fmt.Sprintf("ALTER USER %s ATTRIBUTE %s", account, literal), nil
+}
+
+// expiryEvent returns the quoted schema.event name of user's account lock event
+func (d ddl) expiryEvent(schema, user string) (string, error) {
+	quotedSchema, err := d.quoteIdent(schema)
+	if err != nil {
+		return "", err
+	}
+	quotedEvent, err := d.quoteIdent("zgate_expire_" + user)
+	if err != nil {
+		return "", err
+	}
+	return quotedSchema + "." + quotedEvent, nil
+}
+
+// createExpiryEvent returns a one-shot event in schema that locks user's account after seconds
+func (d ddl) createExpiryEvent(schema, user string, seconds int) (string, error) {
+	event, err := d.expiryEvent(schema, user)
+	if err != nil {
+		return "", err
+	}
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CREATE EVENT IF NOT EXISTS %s ON SCHEDULE AT CURRENT_TIMESTAMP + INTERVAL %d SECOND ON COMPLETION NOT PRESERVE DO ALTER USER IF EXISTS %s ACCOUNT LOCK",
+		event, seconds, account), nil
+}
+
+// dropExpiryEvent returns DROP EVENT IF EXISTS for user's account lock event
+func (d ddl) dropExpiryEvent(schema, user string) (string, error) {
+	event, err := d.expiryEvent(schema, user)
+	if err != nil {
+		return "", err
+	}
+	return "DROP EVENT IF EXISTS " + event, nil
+}
+
+// on returns the privilege level for a grant: *.* without a schema,
+// schema.* without a table, otherwise schema.table
+func (d ddl) on(schema, table string) (string, error) {
+	if schema == "" {
+		if table != "" {
+			return "", fmt.Errorf("table %q needs a schema", table)
+		}
+		return "*.*", nil
+	}
+	quotedSchema, err := d.quoteIdent(schema)
+	if err != nil {
+		return "", err
+	}
+	if table == "" {
+		return quotedSchema + ".*", nil
+	}
+	quotedTable, err := d.quoteIdent(table)
+	if err != nil {
+		return "", err
+	}
+	return quotedSchema + "." + quotedTable, nil
+}
+
+// privileges returns a privilege list, each followed by the column list when columns are given
+func (d ddl) privileges(names, columns []string) (string, error) {
+	if len(names) == 0 {
+		return "", fmt.Errorf("no privileges to grant")
+	}
+
+	var columnList string
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, col := range columns {
+			q, err := d.quoteIdent(col)
+			if err != nil {
+				return "", err
+			}
+			quoted[i] = q
+		}
+		columnList = " (" + strings.Join(quoted, ", ") + ")"
+	}
+
+	privs := make([]string, len(names))
+	for i, name := range names {
+		name = strings.ToUpper(name)
+		if !privilegePattern.MatchString(name) {
+			return "", fmt.Errorf("invalid privilege %q", name)
+		}
+		privs[i] = name + columnList
+	}
+	return strings.Join(privs, ", "), nil
+}
+
+// grant returns a GRANT statement for user; privilegeList and level must come from privileges and on
+func (d ddl) grant(privilegeList, level, user string) (string, error) {
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("GRANT %s ON %s TO %s", privilegeList, level, account), nil
+}
+
+// grantRole returns GRANT role TO user
+func (d ddl) grantRole(role, user string) (string, error) {
+	quotedRole, err := d.account(role)
+	if err != nil {
+		return "", err
+	}
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("GRANT %s TO %s", quotedRole, account), nil
+}
+
+// setDefaultRoles returns SET DEFAULT ROLE ALL for user
+func (d ddl) setDefaultRoles(user string) (string, error) {
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return "SET DEFAULT ROLE ALL TO " + account, nil
+}
+
+// revokeAll returns a REVOKE of every privilege user holds directly
+func (d ddl) revokeAll(user string) (string, error) {
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return "REVOKE ALL PRIVILEGES, GRANT OPTION FROM " + account, nil
+}
+
+// revokeRole returns REVOKE role FROM user
+func (d ddl) revokeRole(role, user string) (string, error) {
+	quotedRole, err := d.account(role)
+	if err != nil {
+		return "", err
+	}
+	account, err := d.account(user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("REVOKE %s FROM %s", quotedRole, account), nil
+}
@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// injectionSeeds are inputs that try to end a literal or identifier early
+var injectionSeeds = []string{
+	"alice",
+	"o'brien",
+	"x'; DROP USER root; --",
+	"a`b",
+	"x`; DROP TABLE t; --",
+	`back\slash`,
+	`\'; DROP USER root; --`,
+	`trailing\`,
+	"nul\x00byte",
+	"close]bracket",
+	"ünïcødé",
+	"雪'`\\",
+	"''",
+	"\\\\'",
+}
+
+// skeleton replaces every quoted identifier and string literal in stmt with ? and returns
+// the decoded values in order, reading stmt the way the server would. It fails on an
+// unterminated quote, so output that ends a literal early shows up as a different skeleton.
+func skeleton(t *testing.T, stmt string, noBackslashEscapes bool) (string, []string) {
+	t.Helper()
+
+	var shape strings.Builder
+	var values []string
+	for i := 0; i < len(stmt); {
+		quote := stmt[i]
+		if quote != '`' && quote != '\'' {
+			shape.WriteByte(quote)
+			i++
+			continue
+		}
+
+		var value strings.Builder
+		closed := false
+		for i++; i < len(stmt); i++ {
+			c := stmt[i]
+			if c == '\\' && quote == '\'' && !noBackslashEscapes {
+				if i+1 >= len(stmt) {
+					break
+				}
+				i++
+				switch stmt[i] {
+				case '0':
+					value.WriteByte(0)
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(stmt[i])
+				}
+				continue
+			}
+			if c == quote {
+				if i+1 < len(stmt) && stmt[i+1] == quote {
+					value.WriteByte(quote)
+					i++
+					continue
+				}
+				closed = true
+				i++
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			t.Fatalf("unterminated quote in %q", stmt)
+		}
+		shape.WriteString("?")
+		values = append(values, value.String())
+	}
+	return shape.String(), values
+}
+
+func addSeeds(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+}
+
+func FuzzQuoteIdent(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted, err := ddl{}.quoteIdent(name)
+		if err != nil {
+			return
+		}
+		shape, values := skeleton(t, quoted, false)
+		if shape != "?" || !slices.Equal(values, []string{name}) {
+			t.Fatalf("quoteIdent(%q) = %s, reads back as %q %q", name, quoted, shape, values)
+		}
+	})
+}
+
+func FuzzQuoteString(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, value string) {
+		for _, d := range []ddl{{}, {noBackslashEscapes: true}} {
+			quoted, err := d.quoteString(value)
+			if err != nil {
+				if !strings.ContainsRune(value, 0) {
+					t.Fatalf("quoteString(%q) failed: %v", value, err)
+				}
+				return
+			}
+			shape, values := skeleton(t, quoted, d.noBackslashEscapes)
+			if shape != "?" || !slices.Equal(values, []string{value}) {
+				t.Fatalf("quoteString(%q) with noBackslashEscapes=%v = %s, reads back as %q %q",
+					value, d.noBackslashEscapes, quoted, shape, values)
+			}
+		}
+	})
+}
+
+func FuzzCreateUser(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed, seed)
+		f.Add("zgate_alice", seed)
+	}
+	f.Fuzz(func(t *testing.T, user, password string) {
+		for _, d := range []ddl{{}, {noBackslashEscapes: true}} {
+			want, _ := d.createUser("u", "p", 10, 0, 1)
+			wantShape, _ := skeleton(t, want, d.noBackslashEscapes)
+
+			stmt, err := d.createUser(user, password, 10, 0, 1)
+			if err != nil {
+				return
+			}
+			shape, values := skeleton(t, stmt, d.noBackslashEscapes)
+			if shape != wantShape || !slices.Equal(values, []string{user, "%", password}) {
+				t.Fatalf("createUser(%q, %q) = %s, reads back as %q %q", user, password, stmt, shape, values)
+			}
+		}
+	})
+}
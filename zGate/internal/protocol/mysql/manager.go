@@ -1,113 +1,852 @@
-package mysql
-
-import (
-	"context"
-	"database/sql"
-	"fmt"
-
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/zGate-Team/zGate-Platform/internal/store"
-	"github.com/zGate-Team/zGate-Platform/internal/utils"
-)
-
-// Manager implements protocol.Manager for MySQL
-type Manager struct {
-	database store.Database
-	db       *sql.DB
-}
-
-// NewManager creates a new MySQL manager
-func NewManager(database store.Database) (*Manager, error) {
-	connString := fmt.Sprintf(
-		"%s:%s@tcp(%s)/mysql",
-		database.AdminUsername,
-		database.AdminPassword,
-		database.BackendAddr,
-	)
-
-	db, err := sql.Open("mysql", connString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping MySQL: %w", err)
-	}
-
-	utils.Logger.Info("MySQL manager connected", "database", database.Name)
-
-	return &Manager{
-		database: database,
-		db:       db,
-	}, nil
-}
-
-// CreateTempUser creates a temporary MySQL user
-func (m *Manager) CreateTempUser(ctx context.Context, username, password string, permissions []string) error {
-	utils.Logger.Info("creating temp MySQL user", "database", m.database.Name, "username", username)
-
-	// Create USER
-	createUserSQL := fmt.Sprintf(
-		"CREATE USER IF NOT EXISTS '%s'@'%%' IDENTIFIED BY '%s'",
-		username, password,
-	)
-
-	if _, err := m.db.ExecContext(ctx, createUserSQL); err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
-	}
-
-	// Grant permissions
-	for _, perm := range permissions {
-		var grantSQL string
-
-		switch perm {
-		case "read":
-			grantSQL = fmt.Sprintf("GRANT SELECT ON *.* TO '%s'@'%%'", username)
-		case "write":
-			grantSQL = fmt.Sprintf("GRANT SELECT, INSERT, UPDATE, DELETE ON *.* TO '%s'@'%%'", username)
-		case "admin":
-			grantSQL = fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO '%s'@'%%'", username)
-		default:
-			utils.Logger.Warn("unknown permission", "permission", perm)
-			continue
-		}
-
-		if _, err := m.db.ExecContext(ctx, grantSQL); err != nil {
-			utils.Logger.Error("failed to grant permission", "permission", perm, "error", err)
-		}
-	}
-
-	m.db.ExecContext(ctx, "FLUSH PRIVILEGES")
-
-	utils.Logger.Info("temp MySQL user created", "database", m.database.Name, "username", username)
-	return nil
-}
-
-// DeleteTempUser removes a temporary MySQL user
-func (m *Manager) DeleteTempUser(ctx context.Context, username string) error {
-	utils.Logger.Info("deleting temp MySQL user", "database", m.database.Name, "username", username)
-	dropUserSQL := fmt.Sprintf("DROP USER IF EXISTS '%s'@'%%'", username)
-
-	if _, err := m.db.ExecContext(ctx, dropUserSQL); err != nil {
-		return fmt.Errorf("failed to drop user: %w", err)
-	}
-
-	m.db.ExecContext(ctx, "FLUSH PRIVILEGES")
-
-	utils.Logger.Info("temp MySQL user deleted", "database", m.database.Name, "username", username)
-	return nil
-}
-
-// Close closes the database connection
-func (m *Manager) Close() error {
-	if m.db != nil {
-		return m.db.Close()
-	}
-	return nil
-}
-
-// GetType returns the database type
-func (m *Manager) GetType() string {
-	return "mysql"
-}
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/audit"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/nativerole"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/privileges"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// builtinLevels are the levels granted without a custom definition
+var builtinLevels = []string{"read", "write", "admin"}
+
+// levelPrivileges lists what the read and write levels grant on the target database
+var levelPrivileges = map[string][]string{
+	"read":  {"SELECT"},
+	"write": {"SELECT", "INSERT", "UPDATE", "DELETE"},
+}
+
+// adminPrivileges are checked to confirm an ALL PRIVILEGES grant took effect
+var adminPrivileges = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "ALTER", "INDEX"}
+
+// defaultMaxUserConnections caps concurrent connections per temp user when no limit is configured
+const defaultMaxUserConnections = 10
+
+// watchInterval is how often statements are checked against max_execution_seconds
+const watchInterval = 2 * time.Second
+
+// dialTimeout bounds how long connecting to the backend may take
+const dialTimeout = "5s"
+
+// Manager implements protocol.Manager for MySQL
+type Manager struct {
+	database store.Database
+	db       *sql.DB
+	ddl      ddl
+
+	// stopWatch stops the statement watchdog, if one is running
+	stopWatch context.CancelFunc
+	// planning collects grant statements into planned instead of running them
+	planning bool
+	planned  []string
+}
+
+// NewManager creates a new MySQL manager
+func NewManager(database store.Database) (*Manager, error) {
+	connString := fmt.Sprintf(
+		"%s:%s@tcp(%s)/mysql?timeout=%s",
+		database.AdminUsername,
+		database.AdminPassword,
+		database.BackendAddr,
+		dialTimeout,
+	)
+
+	db, err := sql.Open("mysql", connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping MySQL: %w", err)
+	}
+
+	if database.TargetDatabase == "" {
+		utils.Logger.Warn("no target database configured, temp users cannot be created without one to hold their lock event", "database", database.Name)
+	}
+
+	var scheduler string
+	if err := db.QueryRow("SELECT @@event_scheduler").Scan(&scheduler); err == nil && scheduler != "ON" {
+		utils.Logger.Warn("event_scheduler is not ON, temp users cannot be created until it is", "database", database.Name)
+	}
+
+	// Pooled connections start with the global sql_mode, which decides how literals escape backslashes
+	var sqlMode string
+	if err := db.QueryRow("SELECT @@GLOBAL.sql_mode").Scan(&sqlMode); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read sql_mode: %w", err)
+	}
+
+	utils.Logger.Info("MySQL manager connected", "database", database.Name)
+
+	return &Manager{
+		database: database,
+		db:       db,
+		ddl:      ddl{noBackslashEscapes: strings.Contains(sqlMode, "NO_BACKSLASH_ESCAPES")},
+	}, nil
+}
+
+// CreateTempUser creates a temporary MySQL user.
+// Provisioning is all-or-nothing: if any grant fails or does not take effect the user is dropped again.
+// An event locks the account at expiresAt, so the user is useless even if zGate is gone; the user is refused when
+// the lock cannot be scheduled. The password also expires, but only in whole days.
+// The permissions' resource limits become account limits; statements over max_execution_seconds are killed.
+func (m *Manager) CreateTempUser(ctx context.Context, username, password string, permissions []store.Permission, expiresAt time.Time) error {
+	utils.Logger.Info("creating temp MySQL user", "database", m.database.Name, "username", username)
+
+	// Refuse unknown levels up front rather than creating a user with no rights
+	for _, perm := range permissions {
+		if err := m.checkLevel(perm.Level); err != nil {
+			return err
+		}
+	}
+
+	// Create USER
+	// PASSWORD EXPIRE INTERVAL counts whole days
+	expireDays := max(1, int(math.Ceil(time.Until(expiresAt).Hours()/24)))
+
+	limits := resourceLimits(permissions)
+	maxConnections := limits.MaxConnections
+	if maxConnections == 0 {
+		maxConnections = defaultMaxUserConnections
+	}
+
+	createUserSQL, err := m.ddl.createUser(username, password, maxConnections, limits.MaxQueriesPerHour, expireDays)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, createUserSQL); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if err := m.scheduleExpiry(ctx, username, expiresAt); err != nil {
+		m.rollback(ctx, username)
+		return fmt.Errorf("failed to schedule the account lock for %s: %w", username, err)
+	}
+
+	// Grant and verify permissions
+	if err := m.GrantPermissions(ctx, username, permissions); err != nil {
+		m.rollback(ctx, username)
+		return fmt.Errorf("failed to provision %s: %w", username, err)
+	}
+
+	if limits.MaxExecutionSeconds > 0 {
+		m.startWatchdog(username, time.Duration(limits.MaxExecutionSeconds)*time.Second)
+	}
+
+	utils.Logger.Info("temp MySQL user created", "database", m.database.Name, "username", username)
+	return nil
+}
+
+// GrantPermissions grants permissions to an existing temp user, stopping at the first failure,
+// then verifies the user's effective privileges cover what was granted
+func (m *Manager) GrantPermissions(ctx context.Context, username string, permissions []store.Permission) error {
+	for _, perm := range permissions {
+		if err := m.grantPermission(ctx, username, perm); err != nil {
+			return err
+		}
+	}
+
+	if _, err := m.db.ExecContext(ctx, "FLUSH PRIVILEGES"); err != nil {
+		return fmt.Errorf("failed to flush privileges: %w", err)
+	}
+
+	return m.verifyPrivileges(ctx, username, permissions)
+}
+
+// PlanGrants returns the statements GrantPermissions would run for username,
+// without connecting to the backend. Whole-level grants include building the
+// level role, which only happens when the role is first used or its level changed.
+func PlanGrants(database store.Database, username string, permissions []store.Permission) ([]string, error) {
+	m := &Manager{database: database, planning: true, planned: []string{}}
+	for _, perm := range permissions {
+		if err := m.grantPermission(context.Background(), username, perm); err != nil {
+			return m.planned, err
+		}
+	}
+	m.planned = append(m.planned, "FLUSH PRIVILEGES")
+	return m.planned, nil
+}
+
+// exec runs a grant statement, or only collects it when planning
+func (m *Manager) exec(ctx context.Context, query string) error {
+	if m.planning {
+		m.planned = append(m.planned, query)
+		return nil
+	}
+	_, err := m.db.ExecContext(ctx, query)
+	return err
+}
+
+// scheduleExpiry creates an event that locks the account at expiresAt.
+// The password expiry set at creation counts whole days, and an expired MySQL
+// password still allows a sandbox login that can set a new one, so the lock
+// event is the real failsafe: without a schema to hold it or a running event
+// scheduler it is an error, and the user is not provisioned.
+func (m *Manager) scheduleExpiry(ctx context.Context, username string, expiresAt time.Time) error {
+	if m.database.TargetDatabase == "" {
+		return fmt.Errorf("the account lock event needs a target database to live in")
+	}
+
+	var scheduler string
+	if err := m.db.QueryRowContext(ctx, "SELECT @@GLOBAL.event_scheduler").Scan(&scheduler); err != nil {
+		return fmt.Errorf("failed to read event_scheduler: %w", err)
+	}
+	if scheduler != "ON" {
+		return fmt.Errorf("event_scheduler is %s, so the account lock would never run", scheduler)
+	}
+
+	seconds := max(1, int(time.Until(expiresAt).Seconds()))
+	eventSQL, err := m.ddl.createExpiryEvent(m.database.TargetDatabase, username, seconds)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, eventSQL); err != nil {
+		return fmt.Errorf("failed to create the account lock event: %w", err)
+	}
+	return nil
+}
+
+// resourceLimits combines the limits of every permission, keeping the strictest
+func resourceLimits(permissions []store.Permission) store.ResourceLimits {
+	var limits *store.ResourceLimits
+	for _, perm := range permissions {
+		limits = store.StricterLimits(limits, perm.Limits)
+	}
+	if limits == nil {
+		return store.ResourceLimits{}
+	}
+	return *limits
+}
+
+// startWatchdog kills username's statements once they run longer than limit.
+// MySQL's max_execution_time is a session variable only covering SELECT, so
+// zGate enforces the limit itself; the admin account needs PROCESS and CONNECTION_ADMIN.
+func (m *Manager) startWatchdog(username string, limit time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopWatch = cancel
+
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := m.killLongQueries(ctx, username, limit); err != nil && ctx.Err() == nil {
+				utils.Logger.Warn("failed to enforce max execution time", "database", m.database.Name, "username", username, "error", err)
+			}
+		}
+	}()
+}
+
+// killLongQueries kills every statement of username that has run for at least limit
+func (m *Manager) killLongQueries(ctx context.Context, username string, limit time.Duration) error {
+	ids, err := m.threadIDs(ctx,
+		`SELECT ID FROM information_schema.PROCESSLIST WHERE USER = ? AND COMMAND = 'Query' AND TIME >= ?`,
+		username, int(limit.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		utils.Logger.Warn("killing statement over max execution time", "database", m.database.Name, "username", username, "thread", id)
+		if _, err := m.db.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// threadIDs runs a query returning connection thread IDs
+func (m *Manager) threadIDs(ctx context.Context, query string, args ...any) ([]int64, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// stopWatchdog stops the statement watchdog, if one is running
+func (m *Manager) stopWatchdog() {
+	if m.stopWatch != nil {
+		m.stopWatch()
+		m.stopWatch = nil
+	}
+}
+
+// rollback drops a partially provisioned user, even when ctx is already cancelled
+func (m *Manager) rollback(ctx context.Context, username string) {
+	if err := m.DeleteTempUser(context.WithoutCancel(ctx), username); err != nil {
+		utils.Logger.Error("failed to roll back temp user", "database", m.database.Name, "username", username, "error", err)
+	}
+}
+
+// grantPermission grants a single permission to a temp user.
+// Whole-level permissions make the user a member of the level's native role;
+// object grants replace the level's database-wide grants and go to the user directly.
+func (m *Manager) grantPermission(ctx context.Context, username string, perm store.Permission) error {
+	if err := m.checkLevel(perm.Level); err != nil {
+		return err
+	}
+
+	if len(perm.Objects) > 0 {
+		if level, ok := m.database.Level(perm.Level); ok {
+			if err := m.applyLevel(ctx, username, level, perm.Objects); err != nil {
+				return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+			}
+			return nil
+		}
+		for _, obj := range perm.Objects {
+			if err := m.grantObject(ctx, username, obj); err != nil {
+				return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+			}
+		}
+		return nil
+	}
+
+	role, err := m.ensureLevelRole(ctx, perm.Level)
+	if err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+
+	grantSQL, err := m.ddl.grantRole(role, username)
+	if err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+	if err := m.exec(ctx, grantSQL); err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+
+	// Roles are inactive on login unless they are default roles
+	defaultSQL, err := m.ddl.setDefaultRoles(username)
+	if err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+	if err := m.exec(ctx, defaultSQL); err != nil {
+		return fmt.Errorf("failed to grant %s: %w", perm.Level, err)
+	}
+	return nil
+}
+
+// ensureLevelRole returns the level's native role, building it first if this
+// version of the level's definition was not built since zGate started
+func (m *Manager) ensureLevelRole(ctx context.Context, level string) (string, error) {
+	role := nativerole.Name(m.database, level)
+	if m.planning {
+		createSQL, err := m.ddl.createRole(role)
+		if err != nil {
+			return "", err
+		}
+		m.planned = append(m.planned, createSQL)
+		return role, m.grantLevel(ctx, role, level)
+	}
+
+	key := m.database.BackendAddr + "/" + role
+
+	err := nativerole.Ensure(key, nativerole.Fingerprint(m.database, level), func() error {
+		return m.reconcileLevelRole(ctx, role, level)
+	})
+	if err != nil {
+		return "", fmt.Errorf("level role %s: %w", role, err)
+	}
+	return role, nil
+}
+
+// reconcileLevelRole builds role with everything level grants and reads its privileges back.
+// The role is named after the level's definition, so it is only ever granted to, never
+// revoked from: a changed level gets a new role, and session sync moves live sessions
+// onto it. Older versions of the role are dropped once no one holds them.
+func (m *Manager) reconcileLevelRole(ctx context.Context, role, level string) error {
+	utils.Logger.Info("reconciling MySQL level role", "database", m.database.Name, "level", level, "role", role)
+
+	createSQL, err := m.ddl.createRole(role)
+	if err != nil {
+		return err
+	}
+	if err := m.exec(ctx, createSQL); err != nil {
+		return fmt.Errorf("create role: %w", err)
+	}
+
+	if err := m.grantLevel(ctx, role, level); err != nil {
+		return err
+	}
+
+	want, wantRoles := m.levelGrants(level, nil)
+	have, haveRoles, err := m.effectivePrivileges(ctx, role, len(wantRoles) > 0)
+	if err != nil {
+		return fmt.Errorf("failed to read back privileges: %w", err)
+	}
+	if err := privileges.Check(role, want, have, wantRoles, haveRoles); err != nil {
+		return err
+	}
+
+	m.dropStaleLevelRoles(ctx, role, level)
+	return nil
+}
+
+// dropStaleLevelRoles drops older versions of level's role that have no members left.
+// Versions still held by a session are kept until a later reconcile finds them empty.
+func (m *Manager) dropStaleLevelRoles(ctx context.Context, current, level string) {
+	prefix := nativerole.Prefix(m.database.Name, level)
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT u.User FROM mysql.user u
+		WHERE u.Host = '%' AND LEFT(u.User, ?) = ? AND u.User <> ?
+		AND NOT EXISTS (SELECT 1 FROM mysql.role_edges e WHERE e.FROM_USER = u.User AND e.FROM_HOST = '%')
+	`, len(prefix), prefix, current)
+	if err != nil {
+		utils.Logger.Warn("failed to list stale level roles", "database", m.database.Name, "level", level, "error", err)
+		return
+	}
+	var stale []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err == nil {
+			stale = append(stale, role)
+		}
+	}
+	rows.Close()
+
+	for _, role := range stale {
+		dropSQL, err := m.ddl.dropRole(role)
+		if err == nil {
+			_, err = m.db.ExecContext(ctx, dropSQL)
+		}
+		if err != nil {
+			utils.Logger.Warn("failed to drop stale level role", "database", m.database.Name, "role", role, "error", err)
+			continue
+		}
+		utils.Logger.Info("dropped stale level role", "database", m.database.Name, "role", role)
+	}
+}
+
+// resetPrincipal revokes every privilege and role held by a temp user
+func (m *Manager) resetPrincipal(ctx context.Context, principal string) error {
+	revokeSQL, err := m.ddl.revokeAll(principal)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, revokeSQL); err != nil {
+		return fmt.Errorf("revoke privileges: %w", err)
+	}
+
+	_, roles, err := m.effectivePrivileges(ctx, principal, true)
+	if err != nil {
+		return fmt.Errorf("read role memberships: %w", err)
+	}
+	for _, role := range roles {
+		revokeSQL, err := m.ddl.revokeRole(role, principal)
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, revokeSQL); err != nil {
+			return fmt.Errorf("revoke role %s: %w", role, err)
+		}
+	}
+	return nil
+}
+
+// ReplacePermissions swaps a temp user's permissions for new ones: everything is revoked,
+// permissions are granted and verified, then the user's open connections are killed.
+// MySQL caches schema privileges and active roles per connection, so only new connections
+// are guaranteed to see the change.
+func (m *Manager) ReplacePermissions(ctx context.Context, username string, permissions []store.Permission) error {
+	for _, perm := range permissions {
+		if err := m.checkLevel(perm.Level); err != nil {
+			return err
+		}
+	}
+
+	if err := m.resetPrincipal(ctx, username); err != nil {
+		return fmt.Errorf("failed to revoke permissions: %w", err)
+	}
+	if err := m.GrantPermissions(ctx, username, permissions); err != nil {
+		return err
+	}
+	return m.killConnections(ctx, username)
+}
+
+// killConnections closes every open connection of username
+func (m *Manager) killConnections(ctx context.Context, username string) error {
+	ids, err := m.threadIDs(ctx, `SELECT ID FROM information_schema.PROCESSLIST WHERE USER = ?`, username)
+	if err != nil {
+		return fmt.Errorf("failed to list connections: %w", err)
+	}
+	for _, id := range ids {
+		if _, err := m.db.ExecContext(ctx, fmt.Sprintf("KILL CONNECTION %d", id)); err != nil {
+			return fmt.Errorf("failed to kill connection %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// grantLevel grants everything level gives on the whole target database to grantee
+func (m *Manager) grantLevel(ctx context.Context, grantee, level string) error {
+	if custom, ok := m.database.Level(level); ok {
+		return m.applyLevel(ctx, grantee, custom, custom.Objects)
+	}
+
+	var names []string
+
+	switch level {
+	case "read", "write":
+		names = levelPrivileges[level]
+	case "admin":
+		names = []string{"ALL PRIVILEGES"}
+	default:
+		return fmt.Errorf("permission level %q has no definition", level)
+	}
+
+	// In MySQL a schema is a database, so grants are scoped to TargetDatabase;
+	// without one they fall back to every schema on the server.
+	grantSQL, err := m.buildGrant(names, nil, m.database.TargetDatabase, "", grantee)
+	if err != nil {
+		return err
+	}
+
+	if err := m.exec(ctx, grantSQL); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkLevel rejects levels the database does not offer or that have no definition
+func (m *Manager) checkLevel(level string) error {
+	if !slices.Contains(m.database.AvailablePermissions, level) {
+		return fmt.Errorf("permission level %q is not available on database %s", level, m.database.Name)
+	}
+	if _, ok := m.database.Level(level); ok || slices.Contains(builtinLevels, level) {
+		return nil
+	}
+	return fmt.Errorf("permission level %q has no definition", level)
+}
+
+// applyLevel applies a custom level to grantee, a temp user or a level role:
+// object grants, then role memberships, then SQL statements
+func (m *Manager) applyLevel(ctx context.Context, grantee string, level *store.PermissionLevel, objects []store.ObjectGrant) error {
+	for _, obj := range objects {
+		if err := m.grantObject(ctx, grantee, obj); err != nil {
+			return err
+		}
+	}
+
+	for _, role := range level.NativeRoles {
+		grantSQL, err := m.ddl.grantRole(role, grantee)
+		if err != nil {
+			return fmt.Errorf("grant role %s: %w", role, err)
+		}
+		if err := m.exec(ctx, grantSQL); err != nil {
+			return fmt.Errorf("grant role %s: %w", role, err)
+		}
+	}
+	if len(level.NativeRoles) > 0 {
+		// Roles are inactive on login unless they are default roles
+		defaultSQL, err := m.ddl.setDefaultRoles(grantee)
+		if err != nil {
+			return fmt.Errorf("set default roles: %w", err)
+		}
+		if err := m.exec(ctx, defaultSQL); err != nil {
+			return fmt.Errorf("set default roles: %w", err)
+		}
+	}
+
+	for i, stmt := range level.Statements {
+		expanded, err := m.expandTemplate(stmt, grantee)
+		if err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		if err := m.exec(ctx, expanded); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// expandTemplate replaces level statement placeholders with quoted identifiers
+func (m *Manager) expandTemplate(stmt, grantee string) (string, error) {
+	account, err := m.ddl.account(grantee)
+	if err != nil {
+		return "", err
+	}
+
+	var database string
+	if strings.Contains(stmt, "{{database}}") || strings.Contains(stmt, "{{schema}}") {
+		if database, err = m.ddl.quoteIdent(m.database.TargetDatabase); err != nil {
+			return "", fmt.Errorf("target database: %w", err)
+		}
+	}
+
+	return strings.NewReplacer(
+		"{{user}}", account,
+		"{{database}}", database,
+		"{{schema}}", database,
+	).Replace(stmt), nil
+}
+
+// grantObject grants privileges on a schema, table or column list.
+// MySQL column privileges are written as PRIV (col, ...) ON schema.table.
+func (m *Manager) grantObject(ctx context.Context, username string, obj store.ObjectGrant) error {
+	schema := obj.Schema
+	if schema == "" {
+		schema = m.database.TargetDatabase
+	}
+	if schema == "" {
+		return fmt.Errorf("object grant needs a schema or a target database")
+	}
+	if obj.Table == "" && len(obj.Columns) > 0 {
+		return fmt.Errorf("column grants need a table")
+	}
+
+	grantSQL, err := m.buildGrant(obj.Privileges, obj.Columns, schema, obj.Table, username)
+	if err != nil {
+		return fmt.Errorf("grant on %s.%s: %w", schema, obj.Table, err)
+	}
+
+	if err := m.exec(ctx, grantSQL); err != nil {
+		return fmt.Errorf("grant on %s.%s: %w", schema, obj.Table, err)
+	}
+	return nil
+}
+
+// buildGrant builds a GRANT of names (optionally on columns) on schema.table, schema.* or *.*
+func (m *Manager) buildGrant(names, columns []string, schema, table, username string) (string, error) {
+	privs, err := m.ddl.privileges(names, columns)
+	if err != nil {
+		return "", err
+	}
+	level, err := m.ddl.on(schema, table)
+	if err != nil {
+		return "", err
+	}
+	return m.ddl.grant(privs, level, username)
+}
+
+// verifyPrivileges checks that the user's effective privileges and roles cover what permissions grant.
+// Custom level statements are free-form and cannot be checked.
+func (m *Manager) verifyPrivileges(ctx context.Context, username string, permissions []store.Permission) error {
+	want, wantRoles := m.expectedPrivileges(permissions)
+	if len(want) == 0 && len(wantRoles) == 0 {
+		return nil
+	}
+
+	have, haveRoles, err := m.effectivePrivileges(ctx, username, len(wantRoles) > 0)
+	if err != nil {
+		return fmt.Errorf("failed to read back privileges: %w", err)
+	}
+	return privileges.Check(username, want, have, wantRoles, haveRoles)
+}
+
+// expectedPrivileges returns the privileges and roles permissions should leave the user with.
+// Whole-level permissions only need membership of the level role, which was verified when it was built.
+func (m *Manager) expectedPrivileges(permissions []store.Permission) ([]privileges.Privilege, []string) {
+	var want []privileges.Privilege
+	var roles []string
+
+	for _, perm := range permissions {
+		if len(perm.Objects) == 0 {
+			roles = append(roles, nativerole.Name(m.database, perm.Level))
+			continue
+		}
+		levelWant, levelRoles := m.levelGrants(perm.Level, perm.Objects)
+		want = append(want, levelWant...)
+		roles = append(roles, levelRoles...)
+	}
+	return want, roles
+}
+
+// levelGrants returns the privileges and roles level grants, restricted to objects when given
+func (m *Manager) levelGrants(level string, objects []store.ObjectGrant) ([]privileges.Privilege, []string) {
+	var want []privileges.Privilege
+	var roles []string
+
+	if custom, ok := m.database.Level(level); ok {
+		if len(objects) == 0 {
+			objects = custom.Objects
+		}
+		roles = append(roles, custom.NativeRoles...)
+	} else if len(objects) == 0 {
+		names := levelPrivileges[level]
+		if level == "admin" {
+			names = adminPrivileges
+		}
+		for _, name := range names {
+			want = append(want, privileges.Privilege{Name: name, Schema: m.database.TargetDatabase})
+		}
+	}
+
+	for _, obj := range objects {
+		schema := obj.Schema
+		if schema == "" {
+			schema = m.database.TargetDatabase
+		}
+		for _, priv := range obj.Privileges {
+			if len(obj.Columns) == 0 {
+				want = append(want, privileges.Privilege{Name: strings.ToUpper(priv), Schema: schema, Table: obj.Table})
+				continue
+			}
+			for _, col := range obj.Columns {
+				want = append(want, privileges.Privilege{Name: strings.ToUpper(priv), Schema: schema, Table: obj.Table, Column: col})
+			}
+		}
+	}
+	return want, roles
+}
+
+// effectivePrivileges reads the user's grants back from information_schema and, when asked, its granted roles.
+// The admin account needs SELECT on the mysql schema to see other users' grants.
+func (m *Manager) effectivePrivileges(ctx context.Context, username string, withRoles bool) ([]privileges.Privilege, []string, error) {
+	grantee, err := m.ddl.account(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	queries := []string{
+		`SELECT PRIVILEGE_TYPE, '', '', '' FROM information_schema.USER_PRIVILEGES WHERE GRANTEE = ?`,
+		`SELECT PRIVILEGE_TYPE, TABLE_SCHEMA, '', '' FROM information_schema.SCHEMA_PRIVILEGES WHERE GRANTEE = ?`,
+		`SELECT PRIVILEGE_TYPE, TABLE_SCHEMA, TABLE_NAME, '' FROM information_schema.TABLE_PRIVILEGES WHERE GRANTEE = ?`,
+		`SELECT PRIVILEGE_TYPE, TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMN_PRIVILEGES WHERE GRANTEE = ?`,
+	}
+
+	var have []privileges.Privilege
+	for _, query := range queries {
+		rows, err := m.db.QueryContext(ctx, query, grantee)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var p privileges.Privilege
+			if err := rows.Scan(&p.Name, &p.Schema, &p.Table, &p.Column); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			have = append(have, p)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !withRoles {
+		return have, nil, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT FROM_USER FROM mysql.role_edges WHERE TO_USER = ? AND TO_HOST = '%'`, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, nil, err
+		}
+		roles = append(roles, role)
+	}
+	return have, roles, rows.Err()
+}
+
+// SetIdentity stores the zGate identity in the account's JSON attributes. The proxy relays
+// the client's own protocol stream and MySQL has no logon hook, so nothing can set session
+// variables on the client's connections. The attribute is persistent, but each temp account
+// serves a single session and is dropped with it, so triggers and audit can look it up with
+// CURRENT_USER() in information_schema.USER_ATTRIBUTES. Needs MySQL 8.0.21 or later.
+func (m *Manager) SetIdentity(ctx context.Context, username string, identity audit.Identity) error {
+	alterSQL, err := m.ddl.setAttributes(username, identity.Attributes())
+	if err != nil {
+		return fmt.Errorf("failed to set identity: %w", err)
+	}
+	if _, err := m.db.ExecContext(ctx, alterSQL); err != nil {
+		return fmt.Errorf("failed to set identity: %w", err)
+	}
+	return nil
+}
+
+// VerifyLogin opens a connection to the backend as the given user
+func (m *Manager) VerifyLogin(ctx context.Context, username, password string) error {
+	connString := fmt.Sprintf(
+		"%s:%s@tcp(%s)/%s?timeout=%s",
+		username,
+		password,
+		m.database.BackendAddr,
+		m.database.TargetDatabase,
+		dialTimeout,
+	)
+
+	db, err := sql.Open("mysql", connString)
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to log in as %s: %w", username, err)
+	}
+	return nil
+}
+
+// DeleteTempUser removes a temporary MySQL user
+func (m *Manager) DeleteTempUser(ctx context.Context, username string) error {
+	utils.Logger.Info("deleting temp MySQL user", "database", m.database.Name, "username", username)
+	m.stopWatchdog()
+
+	dropUserSQL, err := m.ddl.dropUser(username)
+	if err != nil {
+		return fmt.Errorf("failed to drop user: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, dropUserSQL); err != nil {
+		return fmt.Errorf("failed to drop user: %w", err)
+	}
+
+	// The lock event is no longer needed once the user is gone
+	if m.database.TargetDatabase != "" {
+		if dropEventSQL, err := m.ddl.dropExpiryEvent(m.database.TargetDatabase, username); err == nil {
+			m.db.ExecContext(ctx, dropEventSQL)
+		}
+	}
+
+	m.db.ExecContext(ctx, "FLUSH PRIVILEGES")
+
+	utils.Logger.Info("temp MySQL user deleted", "database", m.database.Name, "username", username)
+	return nil
+}
+
+// Close closes the database connection
+func (m *Manager) Close() error {
+	m.stopWatchdog()
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// GetType returns the database type
+func (m *Manager) GetType() string {
+	return "mysql"
+}
@@ -0,0 +1,264 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Client capability flags the statement tap needs to know about
+const (
+	clientCompress            = 0x00000020
+	clientSSL                 = 0x00000800
+	clientZstdCompression     = 0x04000000
+	clientQueryAttributes     = 0x08000000
+	maxPacketPayload          = 0xffffff
+	sslRequestPayloadLength   = 32
+	handshakeCapabilitiesSize = 4
+)
+
+// Commands whose text the statement tap records
+const (
+	comInitDB      = 0x02
+	comQuery       = 0x03
+	comStmtPrepare = 0x16
+)
+
+// maxStatementBytes bounds how much of one command the tap keeps; longer statements are cut
+const maxStatementBytes = 64 << 10
+
+// statementTap reads the client side of a MySQL connection and reports the text
+// of every query and prepared statement. It only sees what is on the wire, so it
+// gives up on a connection the client encrypts or compresses.
+type statementTap struct {
+	record     func(string)
+	unrecorded func(string)
+
+	buf []byte
+	// skip counts bytes of a long packet the tap does not keep
+	skip            int
+	handshakeDone   bool
+	queryAttributes bool
+	stopped         bool
+
+	// command collects a payload split over several packets
+	command     []byte
+	continuing  bool
+	commandSize int
+}
+
+// StatementTap returns a writer to copy the client's bytes into. It calls record
+// with each statement, literals redacted, and unrecorded once with the reason it
+// stops reading.
+// Writes never fail, so it can sit in the forwarding path.
+func (h *Handler) StatementTap(record, unrecorded func(string)) io.Writer {
+	return &statementTap{record: record, unrecorded: unrecorded}
+}
+
+func (t *statementTap) Write(p []byte) (int, error) {
+	if t.stopped {
+		return len(p), nil
+	}
+	t.buf = append(t.buf, p...)
+	pending := t.buf
+	for !t.stopped {
+		if t.skip > 0 {
+			n := min(t.skip, len(pending))
+			t.skip -= n
+			pending = pending[n:]
+			if t.skip > 0 {
+				break
+			}
+		}
+		if len(pending) < 4 {
+			break
+		}
+		// Only the start of a long packet is kept; the rest is skipped as it arrives
+		length := int(pending[0]) | int(pending[1])<<8 | int(pending[2])<<16
+		keep := min(length, maxStatementBytes)
+		if len(pending) < 4+keep {
+			break
+		}
+		t.packet(pending[3], pending[4:4+keep], length)
+		pending = pending[4+keep:]
+		t.skip = length - keep
+	}
+	if t.stopped {
+		t.buf = nil
+		return len(p), nil
+	}
+	t.buf = append(t.buf[:0], pending...)
+	return len(p), nil
+}
+
+// packet handles one packet with sequence number seq, of which payload is the
+// part kept of length bytes
+func (t *statementTap) packet(seq byte, payload []byte, length int) {
+	if !t.handshakeDone {
+		t.handshake(payload)
+		return
+	}
+
+	if t.continuing {
+		t.appendCommand(payload, length)
+		if length < maxPacketPayload {
+			t.finishCommand()
+		}
+		return
+	}
+
+	// Authentication exchanges and LOAD DATA LOCAL contents continue a sequence;
+	// every command starts a new one
+	if seq != 0 || len(payload) == 0 {
+		return
+	}
+	switch payload[0] {
+	case comInitDB, comQuery, comStmtPrepare:
+	default:
+		return
+	}
+	t.command = t.command[:0]
+	t.commandSize = 0
+	t.appendCommand(payload, length)
+	if length == maxPacketPayload {
+		t.continuing = true
+		return
+	}
+	t.finishCommand()
+}
+
+// handshake reads the client's first packet: either the handshake response or
+// a request to switch to TLS
+func (t *statementTap) handshake(payload []byte) {
+	if len(payload) < handshakeCapabilitiesSize {
+		t.stop("client sent an unexpected handshake")
+		return
+	}
+	capabilities := binary.LittleEndian.Uint32(payload)
+	switch {
+	case capabilities&clientSSL != 0 && len(payload) == sslRequestPayloadLength:
+		t.stop("client encrypted the connection with TLS")
+		return
+	case capabilities&(clientCompress|clientZstdCompression) != 0:
+		t.stop("client compressed the connection")
+		return
+	}
+	t.queryAttributes = capabilities&clientQueryAttributes != 0
+	t.handshakeDone = true
+}
+
+// appendCommand adds the kept part of a packet of length bytes to the command,
+// up to maxStatementBytes
+func (t *statementTap) appendCommand(payload []byte, length int) {
+	t.commandSize += length
+	if room := maxStatementBytes - len(t.command); room > 0 {
+		t.command = append(t.command, payload[:min(room, len(payload))]...)
+	}
+}
+
+// finishCommand reports the statement in the collected command
+func (t *statementTap) finishCommand() {
+	t.continuing = false
+	command, body := t.command[0], t.command[1:]
+	switch command {
+	case comInitDB:
+		t.record(fmt.Sprintf("USE `%s`", body))
+		return
+	case comQuery:
+		if t.queryAttributes {
+			var ok bool
+			if body, ok = skipQueryAttributes(body); !ok {
+				t.record("-- query with attributes, text not recorded")
+				return
+			}
+		}
+	}
+	statement := redact(string(body))
+	if t.commandSize > maxStatementBytes {
+		statement += fmt.Sprintf(" -- cut at %d of %d bytes", len(t.command), t.commandSize)
+	}
+	t.record(statement)
+}
+
+// redact replaces every string, hex and bit literal in statement with ?, so
+// passwords in IDENTIFIED BY and the values a query carries are never stored.
+// Backquoted identifiers are kept. Strings are read with backslash escapes, as
+// the server reads them unless NO_BACKSLASH_ESCAPES is set, and double-quoted
+// text is taken for a string, as it is unless ANSI_QUOTES is set. A literal
+// left open runs to the end of the statement.
+func redact(statement string) string {
+	var b strings.Builder
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case c == '`':
+			end := literalEnd(statement, i, false)
+			b.WriteString(statement[i:end])
+			i = end
+			continue
+		case c == '\'' || c == '"':
+			i = literalEnd(statement, i, true)
+		case strings.IndexByte("xXbB", c) >= 0 && i+1 < len(statement) && statement[i+1] == '\'' && !inWord(statement, i):
+			i = literalEnd(statement, i+1, false)
+		case c == '0' && i+1 < len(statement) && (statement[i+1] == 'x' || statement[i+1] == 'b') && !inWord(statement, i):
+			i += 2
+			for i < len(statement) && isWordByte(statement[i]) {
+				i++
+			}
+		default:
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		b.WriteByte('?')
+	}
+	return b.String()
+}
+
+// literalEnd returns the index just past the quote closing the literal that
+// opens at start. A doubled quote stands for itself, as does a quote after a
+// backslash when backslash is set.
+func literalEnd(statement string, start int, backslash bool) int {
+	quote := statement[start]
+	for i := start + 1; i < len(statement); i++ {
+		if backslash && statement[i] == '\\' {
+			i++
+			continue
+		}
+		if statement[i] != quote {
+			continue
+		}
+		if i+1 < len(statement) && statement[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(statement)
+}
+
+// inWord reports whether the byte at i continues an identifier or number
+func inWord(statement string, i int) bool {
+	return i > 0 && isWordByte(statement[i-1])
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// skipQueryAttributes skips the attribute header a client that negotiated query
+// attributes puts before COM_QUERY text. Only queries without attributes are read.
+func skipQueryAttributes(body []byte) ([]byte, bool) {
+	// parameter_count and parameter_set_count are length-encoded; 0 and 1 fit one byte
+	if len(body) < 2 || body[0] != 0 || body[1] != 1 {
+		return nil, false
+	}
+	return body[2:], true
+}
+
+// stop reports why statements are no longer read and ignores the rest of the connection
+func (t *statementTap) stop(reason string) {
+	t.stopped = true
+	t.unrecorded(reason)
+}
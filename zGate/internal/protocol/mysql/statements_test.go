@@ -0,0 +1,203 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// FuzzStatementTap feeds arbitrary client bytes, split at any point, to the
+// statement tap, which sits in the forwarding path and must never panic
+func FuzzStatementTap(f *testing.F) {
+	f.Add([]byte("\x05\x00\x00\x01\x00\x00\x00\x00\x00\x09\x00\x00\x00\x03SELECT 1"), 5)
+	f.Fuzz(func(t *testing.T, data []byte, split int) {
+		tap := (&Handler{}).StatementTap(func(string) {}, func(string) {})
+		if split > 0 && split < len(data) {
+			tap.Write(data[:split])
+			data = data[split:]
+		}
+		if n, err := tap.Write(data); n != len(data) || err != nil {
+			t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(data))
+		}
+	})
+}
+
+// clientCapabilities are what a typical client announces: long password,
+// connect with database, protocol 4.1, secure connection and plugin auth
+const clientCapabilities = 0x00000001 | 0x00000008 | 0x00000200 | 0x00008000 | 0x00080000
+
+// mysqlPacket frames payload as one packet with sequence number seq
+func mysqlPacket(seq byte, payload []byte) []byte {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+	return append(header, payload...)
+}
+
+// handshakeResponse is a HandshakeResponse41 announcing capabilities
+func handshakeResponse(capabilities uint32) []byte {
+	payload := binary.LittleEndian.AppendUint32(nil, capabilities)
+	payload = binary.LittleEndian.AppendUint32(payload, 1<<24) // max packet size
+	payload = append(payload, 0xff)                            // utf8mb4
+	payload = append(payload, make([]byte, 23)...)
+	payload = append(payload, "app\x00"...)
+	payload = append(payload, 20)
+	payload = append(payload, "0123456789abcdefghij"...)
+	payload = append(payload, "orders\x00caching_sha2_password\x00"...)
+	return mysqlPacket(1, payload)
+}
+
+// command is a client command packet, which always starts a sequence
+func command(code byte, text string) []byte {
+	return mysqlPacket(0, append([]byte{code}, text...))
+}
+
+// tapStream writes stream to a new tap in chunks of chunk bytes and returns
+// what it recorded and why it stopped, if it did
+func tapStream(stream []byte, chunk int) (recorded, unrecorded []string) {
+	tap := (&Handler{}).StatementTap(
+		func(s string) { recorded = append(recorded, s) },
+		func(s string) { unrecorded = append(unrecorded, s) },
+	)
+	for len(stream) > 0 {
+		n := min(chunk, len(stream))
+		tap.Write(stream[:n])
+		stream = stream[n:]
+	}
+	return recorded, unrecorded
+}
+
+func TestStatementTapRecordsCommands(t *testing.T) {
+	stream := slices.Concat(
+		handshakeResponse(clientCapabilities),
+		mysqlPacket(3, []byte("auth switch response")),
+		command(comQuery, "SELECT * FROM orders WHERE email = 'ann@example.com' AND id = 7"),
+		command(comInitDB, "billing"),
+		command(comStmtPrepare, "UPDATE invoices SET paid = ? WHERE number = 'INV-1'"),
+		command(0x0e, ""), // COM_PING
+		command(comQuery, "CREATE USER 'bob'@'%' IDENTIFIED BY 's3cr3t'"),
+		command(0x17, "\x01\x00\x00\x00"), // COM_STMT_EXECUTE
+		command(comQuery, "ALTER USER CURRENT_USER() IDENTIFIED BY 'it\\'s' PASSWORD EXPIRE NEVER"),
+	)
+	want := []string{
+		"SELECT * FROM orders WHERE email = ? AND id = 7",
+		"USE `billing`",
+		"UPDATE invoices SET paid = ? WHERE number = ?",
+		"CREATE USER ?@? IDENTIFIED BY ?",
+		"ALTER USER CURRENT_USER() IDENTIFIED BY ? PASSWORD EXPIRE NEVER",
+	}
+
+	// However the stream is split, the same statements come out
+	for _, chunk := range []int{len(stream), 1, 3, 7, 64} {
+		recorded, unrecorded := tapStream(stream, chunk)
+		if !slices.Equal(recorded, want) {
+			t.Fatalf("in chunks of %d: recorded %q; want %q", chunk, recorded, want)
+		}
+		if len(unrecorded) != 0 {
+			t.Fatalf("in chunks of %d: stopped: %q", chunk, unrecorded)
+		}
+	}
+}
+
+func TestStatementTapQueryAttributes(t *testing.T) {
+	stream := slices.Concat(
+		handshakeResponse(clientCapabilities|clientQueryAttributes),
+		command(comQuery, "\x00\x01SELECT 'a'"),
+		command(comQuery, "\x01\x01\x00\xfe\x00\x03tag\x01trace-id"),
+	)
+	recorded, _ := tapStream(stream, len(stream))
+	want := []string{"SELECT ?", "-- query with attributes, text not recorded"}
+	if !slices.Equal(recorded, want) {
+		t.Fatalf("recorded %q; want %q", recorded, want)
+	}
+}
+
+func TestStatementTapMultiPacketCommand(t *testing.T) {
+	// A payload of exactly 0xffffff bytes continues in the next packet; the tap
+	// keeps the first maxStatementBytes of the command and notes the rest was cut
+	first := append([]byte{comQuery}, "SELECT 1"...)
+	first = append(first, strings.Repeat(" ", maxPacketPayload-len(first))...)
+	rest := []byte(" FROM dual")
+	stream := slices.Concat(
+		handshakeResponse(clientCapabilities),
+		mysqlPacket(0, first),
+		mysqlPacket(1, rest),
+		command(comQuery, "SELECT 2"),
+	)
+
+	recorded, _ := tapStream(stream, 1<<16)
+	if len(recorded) != 2 {
+		t.Fatalf("recorded %d statements; want 2", len(recorded))
+	}
+	suffix := fmt.Sprintf(" -- cut at %d of %d bytes", maxStatementBytes, len(first)+len(rest))
+	if !strings.HasPrefix(recorded[0], "SELECT 1 ") || !strings.HasSuffix(recorded[0], suffix) {
+		t.Fatalf("long statement recorded as %.20q...; want SELECT 1 ...%q", recorded[0], suffix)
+	}
+	if recorded[1] != "SELECT 2" {
+		t.Fatalf("statement after the long one recorded as %q", recorded[1])
+	}
+
+	// A payload of exactly 0xffffff bytes in total ends with an empty packet
+	stream = slices.Concat(
+		handshakeResponse(clientCapabilities),
+		mysqlPacket(0, first),
+		mysqlPacket(1, nil),
+		command(comQuery, "SELECT 3"),
+	)
+	recorded, _ = tapStream(stream, len(stream))
+	if len(recorded) != 2 || recorded[1] != "SELECT 3" {
+		t.Fatalf("after an exact multiple of the packet size, recorded %d statements; want 2 ending with SELECT 3", len(recorded))
+	}
+}
+
+func TestStatementTapStops(t *testing.T) {
+	sslRequest := binary.LittleEndian.AppendUint32(nil, clientCapabilities|clientSSL)
+	sslRequest = append(sslRequest, make([]byte, sslRequestPayloadLength-len(sslRequest))...)
+
+	tests := []struct {
+		name   string
+		first  []byte
+		reason string
+	}{
+		{"TLS", mysqlPacket(1, sslRequest), "client encrypted the connection with TLS"},
+		{"compression", handshakeResponse(clientCapabilities | clientCompress), "client compressed the connection"},
+		{"zstd compression", handshakeResponse(clientCapabilities | clientZstdCompression), "client compressed the connection"},
+		{"garbage", mysqlPacket(1, []byte{1}), "client sent an unexpected handshake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := slices.Concat(tt.first, command(comQuery, "SELECT 1"), command(comQuery, "SELECT 2"))
+			recorded, unrecorded := tapStream(stream, 5)
+			if len(recorded) != 0 {
+				t.Fatalf("recorded %q after stopping", recorded)
+			}
+			if !slices.Equal(unrecorded, []string{tt.reason}) {
+				t.Fatalf("unrecorded %q; want %q once", unrecorded, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = 'x' AND b = \"y\"", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"SET PASSWORD FOR 'bob'@'%' = 'hunter2'", "SET PASSWORD FOR ?@? = ?"},
+		{"CREATE USER bob IDENTIFIED WITH caching_sha2_password AS '$A$005$hash'", "CREATE USER bob IDENTIFIED WITH caching_sha2_password AS ?"},
+		{`SELECT 'it\'s', 'a''b', 'c\\' , 'd'`, "SELECT ?, ?, ? , ?"},
+		{"SELECT `we'ird` FROM `a``b` WHERE c = 'x'", "SELECT `we'ird` FROM `a``b` WHERE c = ?"},
+		{"SELECT 0xDEADBEEF, X'cafe', b'0101', 0b11, _utf8mb4'x'", "SELECT ?, ?, ?, ?, _utf8mb4?"},
+		{"SELECT t0x1, hex'' FROM max0b", "SELECT t0x1, hex? FROM max0b"},
+		{"SELECT 42, 3.14, -7e3", "SELECT 42, 3.14, -7e3"},
+		{"INSERT INTO t VALUES ('unterminated", "INSERT INTO t VALUES (?"},
+		{"SELECT 'é', `ü` -- 'comment'", "SELECT ?, `ü` -- ?"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.statement); got != tt.want {
+			t.Errorf("redact(%q) = %q; want %q", tt.statement, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/mssql"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/mysql"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// DefaultUsernameTemplate names temp principals when a database sets no username_template
+const DefaultUsernameTemplate = "zgate_{{user}}_{{rand}}"
+
+// Placeholders available in username templates:
+//   - {{user}} is the zGate login's local part, sanitized and truncated to fit
+//   - {{hash}} is a short hash of the full zGate login, for traceability without exposing it
+//   - {{rand}} is a random suffix that keeps names unique; every template must contain it
+const (
+	userPlaceholder = "{{user}}"
+	hashPlaceholder = "{{hash}}"
+	randPlaceholder = "{{rand}}"
+)
+
+// Lengths of the generated placeholder values, in hex characters
+const (
+	hashLength = 10
+	randLength = 8
+)
+
+// namingRule constrains temp principal names for one database type
+type namingRule struct {
+	maxLength int
+	allowed   func(r rune) bool
+}
+
+// namingRules holds the per-vendor limits. MySQL caps account names at 32
+// characters; MSSQL logins are sysname and may also use '.' and '-'.
+var namingRules = map[string]namingRule{
+	"mysql": {
+		maxLength: mysql.MaxUserLength,
+		allowed:   isNameRune,
+	},
+	"mssql": {
+		maxLength: mssql.MaxIdentifierLength,
+		allowed: func(r rune) bool {
+			return isNameRune(r) || r == '.' || r == '-'
+		},
+	},
+}
+
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_'
+}
+
+// ValidateUsernameTemplate checks that a template yields unique, legal names for a database type
+func ValidateUsernameTemplate(dbType, template string) error {
+	rule, ok := namingRules[dbType]
+	if !ok {
+		return fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	if !strings.Contains(template, randPlaceholder) {
+		return fmt.Errorf("username template must contain %s to keep names unique", randPlaceholder)
+	}
+
+	fixed := fixedPart(template)
+	for _, r := range fixed {
+		if !rule.allowed(r) {
+			return fmt.Errorf("username template contains %q, which %s names do not allow", r, dbType)
+		}
+	}
+	if length := fixedLength(template); length > rule.maxLength {
+		return fmt.Errorf("username template needs %d characters, %s allows %d", length, dbType, rule.maxLength)
+	}
+	return nil
+}
+
+// GenerateTempUsername creates a unique temporary username for zgateUser from
+// the database's naming template, sanitized and truncated for its vendor
+func GenerateTempUsername(database store.Database, zgateUser string) (string, error) {
+	template := database.UsernameTemplate
+	if template == "" {
+		template = DefaultUsernameTemplate
+	}
+	if err := ValidateUsernameTemplate(database.Type, template); err != nil {
+		return "", err
+	}
+	rule := namingRules[database.Type]
+
+	randomBytes := make([]byte, randLength/2)
+	rand.Read(randomBytes)
+	sum := sha256.Sum256([]byte(zgateUser))
+
+	// {{user}} gets whatever room the rest of the name leaves
+	user := sanitizeName(localPart(zgateUser), rule)
+	if count := strings.Count(template, userPlaceholder); count > 0 {
+		if room := (rule.maxLength - fixedLength(template)) / count; len(user) > room {
+			user = user[:room]
+		}
+	}
+
+	return strings.NewReplacer(
+		userPlaceholder, user,
+		hashPlaceholder, hex.EncodeToString(sum[:])[:hashLength],
+		randPlaceholder, hex.EncodeToString(randomBytes),
+	).Replace(template), nil
+}
+
+// fixedPart returns the template's literal text with every placeholder removed
+func fixedPart(template string) string {
+	return strings.NewReplacer(userPlaceholder, "", hashPlaceholder, "", randPlaceholder, "").Replace(template)
+}
+
+// fixedLength is the rendered length of everything except {{user}}
+func fixedLength(template string) int {
+	return len(fixedPart(template)) +
+		strings.Count(template, hashPlaceholder)*hashLength +
+		strings.Count(template, randPlaceholder)*randLength
+}
+
+// localPart returns the part of an email-style login before the @
+func localPart(login string) string {
+	if i := strings.Index(login, "@"); i >= 0 {
+		return login[:i]
+	}
+	return login
+}
+
+// sanitizeName lowercases name and replaces characters the vendor does not allow with '_'
+func sanitizeName(name string, rule namingRule) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if rule.allowed(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,96 @@
+package nativerole
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// Level roles are named zgrole_<level>_<key>_<version>. The level part is only
+// a readable hint; key hashes the zGate database and the exact level name, so two
+// levels or two databases on the same backend never share a role, and version is
+// a prefix of the level's fingerprint, so a changed definition gets a new role
+// instead of rebuilding one that live sessions belong to.
+// The result fits MySQL's 32 character account limit.
+const (
+	prefix         = "zgrole_"
+	maxLevelLength = 8
+	keyLength      = 8
+	versionLength  = 6
+)
+
+// Name returns the native role that holds level's current privileges on database
+func Name(database store.Database, level string) string {
+	return Prefix(database.Name, level) + Fingerprint(database, level)[:versionLength]
+}
+
+// Prefix returns the part of Name shared by every version of level's role on database
+func Prefix(database, level string) string {
+	sum := sha256.Sum256([]byte(database + "\x00" + level))
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(level))
+	if len(name) > maxLevelLength {
+		name = name[:maxLevelLength]
+	}
+	return prefix + name + "_" + hex.EncodeToString(sum[:])[:keyLength] + "_"
+}
+
+// Fingerprint summarizes everything that shapes a level role's definition.
+// A new role version is built whenever it changes.
+func Fingerprint(database store.Database, level string) string {
+	definition := struct {
+		Level          string
+		TargetDatabase string
+		TargetSchema   string
+		Custom         *store.PermissionLevel
+	}{
+		Level:          level,
+		TargetDatabase: database.TargetDatabase,
+		TargetSchema:   database.TargetSchema,
+	}
+	if custom, ok := database.Level(level); ok {
+		// Copy without bookkeeping fields so a re-save of the same definition is a no-op
+		definition.Custom = &store.PermissionLevel{
+			Statements:  custom.Statements,
+			NativeRoles: custom.NativeRoles,
+			Objects:     custom.Objects,
+		}
+	}
+
+	data, _ := json.Marshal(definition)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	// mu serializes reconciliation so concurrent sessions do not rebuild a role at the same time
+	mu sync.Mutex
+	// reconciled maps a backend role key to the fingerprint it was last built from
+	reconciled = map[string]string{}
+)
+
+// Ensure runs reconcile unless the role identified by key was already built from fingerprint.
+// State lives in memory, so every role is reconciled once after zGate starts; reconcile
+// must therefore only add to a role that already exists, never revoke from it.
+func Ensure(key, fingerprint string, reconcile func() error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if reconciled[key] == fingerprint {
+		return nil
+	}
+	if err := reconcile(); err != nil {
+		delete(reconciled, key)
+		return err
+	}
+	reconciled[key] = fingerprint
+	return nil
+}
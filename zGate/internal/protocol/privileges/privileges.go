@@ -0,0 +1,91 @@
+package privileges
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Privilege is a single privilege at a scope.
+// An empty Schema is the whole database (the whole server for MySQL),
+// an empty Table the whole schema and an empty Column the whole table.
+type Privilege struct {
+	Name   string
+	Schema string
+	Table  string
+	Column string
+}
+
+func (p Privilege) String() string {
+	switch {
+	case p.Schema == "":
+		return p.Name + " on database"
+	case p.Table == "":
+		return fmt.Sprintf("%s on schema %s", p.Name, p.Schema)
+	case p.Column == "":
+		return fmt.Sprintf("%s on %s.%s", p.Name, p.Schema, p.Table)
+	default:
+		return fmt.Sprintf("%s on %s.%s(%s)", p.Name, p.Schema, p.Table, p.Column)
+	}
+}
+
+// Covers reports whether holding p grants want: same privilege at the same or a broader scope
+func (p Privilege) Covers(want Privilege) bool {
+	if !strings.EqualFold(p.Name, want.Name) {
+		return false
+	}
+	if p.Schema == "" {
+		return true
+	}
+	if !strings.EqualFold(p.Schema, want.Schema) {
+		return false
+	}
+	if p.Table == "" {
+		return true
+	}
+	if !strings.EqualFold(p.Table, want.Table) {
+		return false
+	}
+	return p.Column == "" || strings.EqualFold(p.Column, want.Column)
+}
+
+// MismatchError reports privileges and roles policy asked for that the principal does not hold
+type MismatchError struct {
+	Principal  string
+	Privileges []Privilege
+	Roles      []string
+}
+
+func (e *MismatchError) Error() string {
+	var missing []string
+	for _, p := range e.Privileges {
+		missing = append(missing, p.String())
+	}
+	for _, role := range e.Roles {
+		missing = append(missing, "membership in role "+role)
+	}
+	return fmt.Sprintf("effective privileges of %s do not match policy, missing: %s",
+		e.Principal, strings.Join(missing, "; "))
+}
+
+// Check compares what policy asked for with what the principal effectively holds.
+// Extra privileges are allowed; anything wanted but not held returns a *MismatchError.
+func Check(principal string, want, have []Privilege, wantRoles, haveRoles []string) error {
+	mismatch := &MismatchError{Principal: principal}
+
+	for _, w := range want {
+		if !slices.ContainsFunc(have, func(h Privilege) bool { return h.Covers(w) }) {
+			mismatch.Privileges = append(mismatch.Privileges, w)
+		}
+	}
+	for _, role := range wantRoles {
+		if !slices.ContainsFunc(haveRoles, func(h string) bool { return strings.EqualFold(h, role) }) {
+			mismatch.Roles = append(mismatch.Roles, role)
+		}
+	}
+
+	if len(mismatch.Privileges) > 0 || len(mismatch.Roles) > 0 {
+		return mismatch
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Level statements are limited to a few grant shapes whose only principal is {{user}}:
+//   - GRANT ... TO {{user}}
+//   - DENY ... TO {{user}} (mssql)
+//   - ALTER ROLE <role> ADD MEMBER {{user}} (mssql)
+//
+// String literals, comments and statement separators are rejected, and GRANT, DENY
+// and TO may each appear only once, so a template cannot smuggle in a second statement.
+// MSSQL runs unseparated batches, which is why the keyword counts matter there too.
+
+// ValidateLevelStatement checks that a custom level statement only grants to {{user}}
+func ValidateLevelStatement(dbType, stmt string) error {
+	if !IsSupportedType(dbType) {
+		return fmt.Errorf("unsupported database type: %s", dbType)
+	}
+
+	tokens, err := statementTokens(dbType, stmt)
+	if err != nil {
+		return err
+	}
+	if len(tokens) < 3 {
+		return fmt.Errorf("statement is incomplete")
+	}
+
+	last := len(tokens) - 1
+	if tokens[last] != userPlaceholder {
+		return fmt.Errorf("statement must end with %s as its only principal", userPlaceholder)
+	}
+	for _, tok := range tokens[:last] {
+		if tok == userPlaceholder {
+			return fmt.Errorf("%s may only appear once, as the principal", userPlaceholder)
+		}
+	}
+
+	switch first := strings.ToUpper(tokens[0]); {
+	case first == "GRANT" || first == "DENY" && dbType == "mssql":
+		if tokens[last-1] != "TO" {
+			return fmt.Errorf("%s statements must end with TO %s", first, userPlaceholder)
+		}
+		for _, tok := range tokens[1 : last-1] {
+			switch tok {
+			case "GRANT", "DENY", "TO", "AS", "WITH", "IDENTIFIED", "REVOKE":
+				return fmt.Errorf("%s statements may not use %s before the principal", first, tok)
+			}
+		}
+		if last-1 < 2 {
+			return fmt.Errorf("%s statement names no privilege", first)
+		}
+		return nil
+	case first == "ALTER" && dbType == "mssql":
+		if len(tokens) != 6 || tokens[1] != "ROLE" || tokens[3] != "ADD" || tokens[4] != "MEMBER" || isKeywordToken(tokens[2]) {
+			return fmt.Errorf("ALTER statements must be ALTER ROLE <role> ADD MEMBER %s", userPlaceholder)
+		}
+		return nil
+	default:
+		if dbType == "mssql" {
+			return fmt.Errorf("statements must be GRANT, DENY or ALTER ROLE ... ADD MEMBER")
+		}
+		return fmt.Errorf("statements must be GRANT ... TO %s", userPlaceholder)
+	}
+}
+
+// statementTokens splits stmt into words, quoted identifiers, placeholders and
+// punctuation. Unquoted words are upper-cased so keywords compare exactly.
+func statementTokens(dbType, stmt string) ([]string, error) {
+	var tokens []string
+	runes := []rune(stmt)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '{':
+			end := strings.Index(string(runes[i:]), "}}")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated placeholder")
+			}
+			placeholder := string(runes[i : i+end+2])
+			switch placeholder {
+			case userPlaceholder, "{{database}}", "{{schema}}":
+			default:
+				return nil, fmt.Errorf("unknown placeholder %s", placeholder)
+			}
+			tokens = append(tokens, placeholder)
+			i += len([]rune(placeholder))
+		case r == '`' && dbType == "mysql", r == '[' && dbType == "mssql", r == '"' && dbType == "mssql":
+			closer := r
+			if r == '[' {
+				closer = ']'
+			}
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == closer {
+					if j+1 < len(runes) && runes[j+1] == closer {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted identifier")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case strings.ContainsRune(",.()*:", r):
+			tokens = append(tokens, string(r))
+			i++
+		case isWordRune(dbType, r):
+			j := i
+			for j < len(runes) && isWordRune(dbType, runes[j]) {
+				j++
+			}
+			tokens = append(tokens, strings.ToUpper(string(runes[i:j])))
+			i = j
+		default:
+			return nil, fmt.Errorf("statement may not contain %q; string literals, comments and separators are not allowed", r)
+		}
+	}
+	return tokens, nil
+}
+
+// isWordRune reports whether r may appear in an unquoted word. '#' starts a comment in MySQL.
+func isWordRune(dbType string, r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '@' || r == '#' && dbType == "mssql"
+}
+
+func isKeywordToken(tok string) bool {
+	switch tok {
+	case "ADD", "MEMBER", "ROLE", "TO", userPlaceholder:
+		return true
+	}
+	return false
+}
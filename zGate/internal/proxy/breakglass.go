@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/alert"
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+const (
+	breakGlassGroupsEnvVar = "ZGATE_BREAK_GLASS_GROUPS"
+	// MaxBreakGlassWindow caps how long a break-glass session may last
+	MaxBreakGlassWindow = 30 * time.Minute
+)
+
+// Break-glass errors returned to API handlers
+var (
+	ErrBreakGlassDisabled = errors.New("break-glass access is not enabled")
+	ErrBreakGlassDenied   = errors.New("break-glass access not allowed")
+)
+
+var (
+	bgGroups     []string
+	bgGroupsOnce sync.Once
+)
+
+// breakGlassGroups returns the groups named in ZGATE_BREAK_GLASS_GROUPS, whose
+// members may use break-glass access. None means break-glass is disabled.
+func breakGlassGroups() []string {
+	bgGroupsOnce.Do(func() {
+		for _, group := range strings.Split(os.Getenv(breakGlassGroupsEnvVar), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				bgGroups = append(bgGroups, group)
+			}
+		}
+	})
+	return bgGroups
+}
+
+// breakGlassRequest is what a user states when opening a break-glass session
+type breakGlassRequest struct {
+	incident      string
+	justification string
+}
+
+// StartBreakGlassSession opens an emergency session on a database the user may
+// have no grant for. It bypasses grants and denies and connects at the database's
+// highest level for at most MaxBreakGlassWindow. Only members of a break-glass
+// group may use it, and stored policies still apply.
+// The use is recorded for admin review before the temp user is created, and
+// alerts go out as soon as the session starts.
+func (m *Manager) StartBreakGlassSession(token string, claims *auth.Claims, databaseName, incident, justification string, clientIP net.IP) (*Session, error) {
+	if len(breakGlassGroups()) == 0 {
+		return nil, ErrBreakGlassDisabled
+	}
+	return m.startSession(token, claims, databaseName, "", clientIP, &breakGlassRequest{
+		incident:      incident,
+		justification: justification,
+	})
+}
+
+// recordBreakGlass stores the break-glass use behind a session about to start
+func (m *Manager) recordBreakGlass(sessionID, username string, database *store.Database, level string, req *breakGlassRequest, clientIP net.IP, expiresAt time.Time) (*store.BreakGlassSession, error) {
+	record := &store.BreakGlassSession{
+		SessionID:     sessionID,
+		Username:      username,
+		Database:      database.Name,
+		Level:         level,
+		Incident:      req.incident,
+		Justification: req.justification,
+		StartedAt:     time.Now(),
+		ExpiresAt:     expiresAt,
+	}
+	if clientIP != nil {
+		record.ClientIP = clientIP.String()
+	}
+	if err := m.store.RecordBreakGlassSession(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// endBreakGlass stamps the end time on a stored break-glass session, if there is one
+func (m *Manager) endBreakGlass(record *store.BreakGlassSession) {
+	if record == nil {
+		return
+	}
+	if err := m.store.EndBreakGlassSession(record.ID, time.Now()); err != nil {
+		utils.Logger.Warn("failed to record break-glass session end", "break_glass_id", record.ID, "error", err)
+	}
+}
+
+// alertBreakGlass logs a started break-glass session and alerts the configured channels
+func alertBreakGlass(record *store.BreakGlassSession) {
+	utils.Logger.Warn("break-glass session started",
+		"break_glass_id", record.ID,
+		"session_id", record.SessionID,
+		"zgate_user", record.Username,
+		"database", record.Database,
+		"level", record.Level,
+		"incident", record.Incident,
+		"justification", record.Justification,
+		"client_ip", record.ClientIP,
+		"expires_at", record.ExpiresAt,
+	)
+
+	alert.Send(alert.Event{
+		Kind: "break_glass_session_started",
+		Text: fmt.Sprintf("Break-glass: %s opened %s on %s for incident %s: %s",
+			record.Username, record.Level, record.Database, record.Incident, record.Justification),
+		Details: map[string]any{
+			"break_glass_id": record.ID,
+			"session_id":     record.SessionID,
+			"username":       record.Username,
+			"database":       record.Database,
+			"level":          record.Level,
+			"incident":       record.Incident,
+			"justification":  record.Justification,
+			"client_ip":      record.ClientIP,
+			"started_at":     record.StartedAt,
+			"expires_at":     record.ExpiresAt,
+		},
+	})
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// MaxElevationWindow caps how long a single elevation may last
+const MaxElevationWindow = time.Hour
+
+// Elevation errors returned to API handlers
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrElevationActive = errors.New("session is already elevated")
+	ErrNotElevated     = errors.New("session is not elevated")
+	ErrElevationDenied = errors.New("elevation not allowed")
+)
+
+// Elevate grants level to the live session behind token for window, or until the
+// session ends if that is sooner. Policy must offer the level through elevate_to.
+// The elevation and its justification are recorded before anything is granted,
+// and the extra privileges are revoked automatically when the window ends.
+// Privileges reach new backend connections; existing ones may need to reconnect.
+func (m *Manager) Elevate(token, level, justification string, window time.Duration) (*Elevation, error) {
+	session, err := m.session(token)
+	if err != nil {
+		return nil, err
+	}
+
+	session.grantsMu.Lock()
+	defer session.grantsMu.Unlock()
+
+	if session.Elevation != nil {
+		return nil, ErrElevationActive
+	}
+	if session.BreakGlass != nil {
+		return nil, fmt.Errorf("%w: break-glass sessions already hold the highest level", ErrElevationDenied)
+	}
+
+	database, err := m.store.GetDatabase(session.DatabaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load database: %w", err)
+	}
+	permission, err := policy.EvaluateElevation(m.store, session.Username, database, level, session.requestContext())
+	if err != nil {
+		if denied := (*policy.DeniedError)(nil); errors.As(err, &denied) {
+			return nil, fmt.Errorf("%w: %v", ErrElevationDenied, err)
+		}
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(min(window, MaxElevationWindow))
+	if expiresAt.After(session.ExpiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	record := store.Elevation{
+		SessionID:     session.ID,
+		Username:      session.Username,
+		Database:      session.DatabaseName,
+		Level:         level,
+		Justification: justification,
+		StartedAt:     now,
+		ExpiresAt:     expiresAt,
+	}
+	if err := m.store.RecordElevation(&record); err != nil {
+		return nil, err
+	}
+
+	dbMgr, err := protocol.NewManager(*database)
+	if err != nil {
+		m.endRecord(record.ID)
+		return nil, fmt.Errorf("failed to create DB manager: %w", err)
+	}
+	defer dbMgr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	if err := dbMgr.GrantPermissions(ctx, session.TempCredentials.Username, []store.Permission{*permission}); err != nil {
+		m.endRecord(record.ID)
+		// A partial grant must not outlive the failed request
+		if rerr := dbMgr.ReplacePermissions(ctx, session.TempCredentials.Username, []store.Permission{session.Permission}); rerr != nil {
+			utils.Logger.Error("failed to roll back elevation", "session_id", session.ID, "error", rerr)
+		}
+		return nil, fmt.Errorf("failed to grant elevation: %w", err)
+	}
+
+	elevation := &Elevation{Record: record, Permission: *permission}
+	elevation.timer = time.AfterFunc(time.Until(expiresAt), func() {
+		utils.Logger.Info("elevation expired", "session_id", session.ID, "zgate_user", session.Username, "level", level)
+		m.revokeElevation(token, session, elevation)
+	})
+	session.Elevation = elevation
+
+	utils.Logger.Info("session elevated",
+		"session_id", session.ID,
+		"zgate_user", session.Username,
+		"database", session.DatabaseName,
+		"from_level", session.Permission.Level,
+		"level", level,
+		"justification", justification,
+		"expires_at", expiresAt,
+	)
+	return elevation, nil
+}
+
+// EndElevation revokes the active elevation of the session behind token before its window ends
+func (m *Manager) EndElevation(token string) error {
+	session, err := m.session(token)
+	if err != nil {
+		return err
+	}
+
+	session.grantsMu.Lock()
+	elevation := session.Elevation
+	session.grantsMu.Unlock()
+	if elevation == nil {
+		return ErrNotElevated
+	}
+
+	utils.Logger.Info("elevation ended by user", "session_id", session.ID, "zgate_user", session.Username, "level", elevation.Record.Level)
+	if !m.revokeElevation(token, session, elevation) {
+		return fmt.Errorf("failed to revoke elevation, session stopped")
+	}
+	return nil
+}
+
+// revokeElevation takes elevation away from session, leaving its standing permission.
+// A session whose elevated privileges cannot be revoked is stopped instead; it
+// reports whether the session survived.
+func (m *Manager) revokeElevation(token string, session *Session, elevation *Elevation) bool {
+	err := m.dropElevation(session, elevation)
+	if err == nil {
+		return true
+	}
+
+	utils.Logger.Warn("stopping session after failed elevation revoke", "session_id", session.ID, "error", err)
+	if err := m.stopSession(token, session); err != nil {
+		utils.Logger.Warn("failed to stop session", "session_id", session.ID, "error", err)
+	}
+	return false
+}
+
+// dropElevation replaces the session's grants with its standing permission
+func (m *Manager) dropElevation(session *Session, elevation *Elevation) error {
+	session.grantsMu.Lock()
+	defer session.grantsMu.Unlock()
+
+	// Already revoked, e.g. by a sync or an earlier request
+	if session.Elevation != elevation {
+		return nil
+	}
+	session.Elevation = nil
+	elevation.timer.Stop()
+	m.endRecord(elevation.Record.ID)
+
+	database, err := m.store.GetDatabase(session.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	dbMgr, err := protocol.NewManager(*database)
+	if err != nil {
+		return fmt.Errorf("failed to create DB manager: %w", err)
+	}
+	defer dbMgr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	if err := dbMgr.ReplacePermissions(ctx, session.TempCredentials.Username, []store.Permission{session.Permission}); err != nil {
+		return err
+	}
+
+	utils.Logger.Info("elevation revoked",
+		"session_id", session.ID,
+		"zgate_user", session.Username,
+		"database", session.DatabaseName,
+		"level", elevation.Record.Level,
+	)
+	return nil
+}
+
+// endRecord stamps the end time on a stored elevation
+func (m *Manager) endRecord(id int64) {
+	if err := m.store.EndElevation(id, time.Now()); err != nil {
+		utils.Logger.Warn("failed to record elevation end", "elevation_id", id, "error", err)
+	}
+}
+
+// session returns the live session behind token
+func (m *Manager) session(token string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, exists := m.sessions[token]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
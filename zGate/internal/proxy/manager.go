@@ -1,220 +1,553 @@
-package proxy
-
-import (
-	"context"
-	"fmt"
-	"net"
-	"strings"
-	"sync"
-
-	"github.com/zGate-Team/zGate-Platform/internal/auth"
-	"github.com/zGate-Team/zGate-Platform/internal/gateway"
-	"github.com/zGate-Team/zGate-Platform/internal/protocol"
-	"github.com/zGate-Team/zGate-Platform/internal/store"
-	"github.com/zGate-Team/zGate-Platform/internal/utils"
-)
-
-// Manager manages dynamic proxy sessions
-type Manager struct {
-	sessions map[string]*Session
-	store    *store.Store
-	gwServer *gateway.Server
-	mu       sync.RWMutex
-}
-
-// NewManager creates a new proxy manager
-func NewManager(store *store.Store, gwServer *gateway.Server) *Manager {
-	return &Manager{
-		sessions: make(map[string]*Session),
-		store:    store,
-		gwServer: gwServer,
-	}
-}
-
-// StartSession creates a new dynamic proxy with temp database user
-func (m *Manager) StartSession(token string, claims *auth.Claims, databaseName string) (*Session, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// Check if session already exists
-	if existing, exists := m.sessions[token]; exists {
-		return existing, nil
-	}
-
-	// Find database config
-	database, err := m.store.GetDatabase(databaseName)
-	if err != nil {
-		return nil, fmt.Errorf("database not found: %s", databaseName)
-	}
-
-	// Create DB manager
-	dbMgr, err := protocol.NewManager(*database)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create DB manager: %w", err)
-	}
-
-	// Generate temp credentials
-	baseUsername := extractUsername(claims.Username)
-	tempUsername := protocol.GenerateTempUsername(baseUsername)
-	tempPassword := protocol.GenerateTempPassword()
-
-	tempCreds := &protocol.TempCredentials{
-		Username: tempUsername,
-		Password: tempPassword,
-	}
-
-	utils.Logger.Info("generated temp credentials",
-		"database", databaseName,
-		"zgate_user", claims.Username,
-		"temp_user", tempUsername,
-	)
-
-	// Fetch user permissions from store (real-time, not from cached claims)
-	user, err := m.store.GetUser(claims.Username)
-	if err != nil {
-		dbMgr.Close()
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	// Get role permissions
-	rolePerms, err := m.store.GetPermissionsForRoles(user.Roles)
-	if err != nil {
-		dbMgr.Close()
-		return nil, fmt.Errorf("failed to get role permissions: %w", err)
-	}
-
-	// Combine role permissions and custom permissions
-	allPermissions := append(rolePerms, user.CustomPermissions...)
-
-	// Determine permissions for this specific database
-	permissions := determinePermissionsForDatabase(allPermissions, databaseName)
-
-	// Create temp user in database
-	ctx := context.Background()
-	if err := dbMgr.CreateTempUser(ctx, tempUsername, tempPassword, permissions); err != nil {
-		dbMgr.Close()
-		return nil, fmt.Errorf("failed to create temp user: %w", err)
-	}
-
-	// Find available port
-	port, err := getFreePort()
-	if err != nil {
-		dbMgr.DeleteTempUser(ctx, tempUsername)
-		dbMgr.Close()
-		return nil, fmt.Errorf("failed to find free port: %w", err)
-	}
-
-	// Create session
-	ctx, cancel := context.WithCancel(context.Background())
-	session := &Session{
-		Username:        claims.Username,
-		DatabaseName:    databaseName,
-		Port:            port,
-		Claims:          claims,
-		Cancel:          cancel,
-		TempCredentials: tempCreds,
-		DBManager:       dbMgr,
-	}
-
-	// Start dynamic proxy in background
-	go m.startDynamicProxy(ctx, session, database)
-
-	// Store session
-	m.sessions[token] = session
-
-	utils.Logger.Info("session started",
-		"zgate_user", claims.Username,
-		"database", databaseName,
-		"port", port,
-		"temp_user", tempUsername,
-	)
-
-	return session, nil
-}
-
-// StopSession stops a session and deletes temp database user
-func (m *Manager) StopSession(token string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	session, exists := m.sessions[token]
-	if !exists {
-		return fmt.Errorf("session not found")
-	}
-
-	utils.Logger.Info("stopping session",
-		"zgate_user", session.Username,
-		"database", session.DatabaseName,
-		"temp_user", session.TempCredentials.Username,
-	)
-
-	// Delete temp database user
-	ctx := context.Background()
-	if err := session.DBManager.DeleteTempUser(ctx, session.TempCredentials.Username); err != nil {
-		utils.Logger.Error("failed to delete temp user", "error", err)
-	}
-
-	// Close DB manager connection
-	if err := session.DBManager.Close(); err != nil {
-		utils.Logger.Error("failed to close DB manager", "error", err)
-	}
-
-	// Cancel context (stops the listener)
-	session.Cancel()
-
-	// Remove from map
-	delete(m.sessions, token)
-
-	utils.Logger.Info("session stopped",
-		"zgate_user", session.Username,
-		"database", session.DatabaseName,
-	)
-
-	return nil
-}
-
-// startDynamicProxy starts a listener on the dynamic port
-func (m *Manager) startDynamicProxy(ctx context.Context, session *Session, database *store.Database) {
-	listenAddr := fmt.Sprintf(":%d", session.Port)
-
-	handler := m.gwServer.GetHandler(database.Type)
-	if handler == nil {
-		utils.Logger.Error("handler not found", "type", database.Type)
-		return
-	}
-
-	listener := gateway.NewListener(*database, handler)
-	if err := listener.Start(ctx, listenAddr); err != nil {
-		utils.Logger.Error("dynamic proxy stopped", "error", err)
-	}
-}
-
-// Helper functions
-
-func extractUsername(email string) string {
-	parts := strings.Split(email, "@")
-	if len(parts) > 0 {
-		return parts[0]
-	}
-	return email
-}
-
-func determinePermissionsForDatabase(permissions []store.Permission, databaseName string) []string {
-	for _, perm := range permissions {
-		if perm.Database == databaseName {
-			return []string{perm.Level}
-		}
-	}
-	return []string{}
-}
-
-func getFreePort() (int, error) {
-	listener, err := net.Listen("tcp", ":0")
-	if err != nil {
-		return 0, err
-	}
-	defer listener.Close()
-
-	addr := listener.Addr().(*net.TCPAddr)
-	return addr.Port, nil
-}
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/gateway"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/audit"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol/nativerole"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// syncInterval re-checks live sessions even without a change notification,
+// e.g. after the store was edited outside the API
+const syncInterval = time.Minute
+
+// syncTimeout bounds how long updating one session's backend grants may take
+const syncTimeout = 30 * time.Second
+
+// Manager manages dynamic proxy sessions
+type Manager struct {
+	sessions map[string]*Session
+	store    *store.Store
+	gwServer *gateway.Server
+	mu       sync.RWMutex
+
+	// changes signals the sync loop that policy data changed
+	changes chan struct{}
+}
+
+// NewManager creates a new proxy manager and keeps live sessions in sync with policy changes
+func NewManager(store *store.Store, gwServer *gateway.Server) *Manager {
+	m := &Manager{
+		sessions: make(map[string]*Session),
+		store:    store,
+		gwServer: gwServer,
+		changes:  make(chan struct{}, 1),
+	}
+	// Sessions end with the process, so any still open were cut short by a restart
+	if n, err := store.EndOpenConnections(time.Now()); err != nil {
+		utils.Logger.Warn("failed to end connections left open", "error", err)
+	} else if n > 0 {
+		utils.Logger.Info("ended connections left open by a restart", "count", n)
+	}
+
+	store.Subscribe(m.onChange)
+	go m.syncLoop()
+	return m
+}
+
+// StartSession creates a new dynamic proxy with temp database user.
+// ticket is an optional change or incident reference recorded with the session.
+// clientIP is the address policy conditions are checked against, now and on every sync.
+func (m *Manager) StartSession(token string, claims *auth.Claims, databaseName, ticket string, clientIP net.IP) (*Session, error) {
+	return m.startSession(token, claims, databaseName, ticket, clientIP, nil)
+}
+
+// startSession starts a session at the level policy resolves, or as a
+// break-glass session when breakGlass is set
+// The backend is provisioned without holding m.mu, so a slow backend does not
+// stall every other session; the session is only added to the map at the end.
+func (m *Manager) startSession(token string, claims *auth.Claims, databaseName, ticket string, clientIP net.IP, breakGlass *breakGlassRequest) (*Session, error) {
+	// Check if session already exists
+	m.mu.RLock()
+	existing, err := m.existingSession(token, breakGlass)
+	m.mu.RUnlock()
+	if existing != nil || err != nil {
+		return existing, err
+	}
+
+	// Find database config
+	database, err := m.store.GetDatabase(databaseName)
+	if err != nil {
+		return nil, fmt.Errorf("database not found: %s", databaseName)
+	}
+
+	// Resolve permissions from the store (real-time, not from cached claims)
+	var permission store.Permission
+	source := policy.BreakGlassSource
+	lifetime := protocol.SessionLifetime(*database)
+	rc := policy.RequestContext{ClientIP: clientIP, BreakGlass: breakGlass != nil}
+	if breakGlass == nil {
+		resolution, err := policy.Evaluate(m.store, claims.Username, database, rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user permissions: %w", err)
+		}
+		if !resolution.Allowed {
+			return nil, fmt.Errorf("access denied to database %s: %s", databaseName, resolution.Reason)
+		}
+		permission, source = *resolution.Permission(), resolution.Source
+	} else {
+		granted, err := policy.EvaluateBreakGlass(m.store, claims.Username, database, breakGlassGroups(), rc)
+		if err != nil {
+			if denied := (*policy.DeniedError)(nil); errors.As(err, &denied) {
+				return nil, fmt.Errorf("%w: %v", ErrBreakGlassDenied, err)
+			}
+			return nil, fmt.Errorf("failed to get user permissions: %w", err)
+		}
+		permission = *granted
+		lifetime = min(lifetime, MaxBreakGlassWindow)
+	}
+	permissions := []store.Permission{permission}
+
+	utils.Logger.Info("permissions resolved",
+		"zgate_user", claims.Username,
+		"database", databaseName,
+		"level", permission.Level,
+		"source", source,
+	)
+
+	// Create DB manager
+	dbMgr, err := protocol.NewManager(*database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DB manager: %w", err)
+	}
+
+	// Generate temp credentials
+	tempUsername, err := protocol.GenerateTempUsername(*database, claims.Username)
+	if err != nil {
+		dbMgr.Close()
+		return nil, fmt.Errorf("failed to name temp user: %w", err)
+	}
+	tempPassword := protocol.GenerateTempPassword()
+
+	tempCreds := &protocol.TempCredentials{
+		Username: tempUsername,
+		Password: tempPassword,
+	}
+
+	utils.Logger.Info("generated temp credentials",
+		"database", databaseName,
+		"zgate_user", claims.Username,
+		"temp_user", tempUsername,
+	)
+
+	// Sessions end after the database's lifetime; the backend disables the
+	// temp user shortly after that on its own in case zGate is gone by then
+	expiresAt := time.Now().Add(lifetime)
+
+	// Break-glass use is recorded before the temp user exists
+	sessionID := newSessionID()
+	var record *store.BreakGlassSession
+	if breakGlass != nil {
+		record, err = m.recordBreakGlass(sessionID, claims.Username, database, permission.Level, breakGlass, clientIP, expiresAt)
+		if err != nil {
+			dbMgr.Close()
+			return nil, err
+		}
+		ticket = audit.BreakGlassPrefix + breakGlass.incident
+	}
+
+	// Create temp user in database
+	ctx := context.Background()
+	if err := dbMgr.CreateTempUser(ctx, tempUsername, tempPassword, permissions, expiresAt.Add(protocol.BackendExpiryGrace)); err != nil {
+		dbMgr.Close()
+		m.endBreakGlass(record)
+		return nil, fmt.Errorf("failed to create temp user: %w", err)
+	}
+
+	// Tag the temp user with who is behind it. Backends without the needed
+	// hooks or rights only lose attribution, so the session goes ahead and
+	// the client is told its actions will not be attributed.
+	identity := audit.Identity{User: claims.Username, SessionID: sessionID, Ticket: ticket}
+	var identityError string
+	if err := dbMgr.SetIdentity(ctx, tempUsername, identity); err != nil {
+		utils.Logger.Warn("failed to attach identity to temp user",
+			"zgate_user", claims.Username,
+			"temp_user", tempUsername,
+			"error", err,
+		)
+		identityError = err.Error()
+	}
+
+	// Find available port
+	port, err := getFreePort()
+	if err != nil {
+		dbMgr.DeleteTempUser(ctx, tempUsername)
+		dbMgr.Close()
+		m.endBreakGlass(record)
+		return nil, fmt.Errorf("failed to find free port: %w", err)
+	}
+
+	// A concurrent request for the same token may have finished first; keep its session
+	m.mu.Lock()
+	if existing, err := m.existingSession(token, breakGlass); existing != nil || err != nil {
+		m.mu.Unlock()
+		dbMgr.DeleteTempUser(ctx, tempUsername)
+		dbMgr.Close()
+		m.endBreakGlass(record)
+		return existing, err
+	}
+	defer m.mu.Unlock()
+
+	// Record the connection so owners can audit it; the backend audit log
+	// shows what ran under the session ID and temp user
+	conn := &store.Connection{
+		SessionID:    sessionID,
+		Username:     claims.Username,
+		Database:     databaseName,
+		Level:        permission.Level,
+		Source:       source,
+		Ticket:       ticket,
+		TempUsername: tempUsername,
+		StartedAt:    time.Now(),
+	}
+	if clientIP != nil {
+		conn.ClientIP = clientIP.String()
+	}
+	if err := m.store.RecordConnection(conn); err != nil {
+		dbMgr.DeleteTempUser(ctx, tempUsername)
+		dbMgr.Close()
+		m.endBreakGlass(record)
+		return nil, fmt.Errorf("failed to record connection: %w", err)
+	}
+
+	// Create session
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &Session{
+		ID:              sessionID,
+		Ticket:          ticket,
+		ClientIP:        clientIP,
+		Username:        claims.Username,
+		DatabaseName:    databaseName,
+		Port:            port,
+		Claims:          claims,
+		Cancel:          cancel,
+		TempCredentials: tempCreds,
+		DBManager:       dbMgr,
+		ExpiresAt:       expiresAt,
+		Permission:      permission,
+		BreakGlass:      record,
+		ConnectionID:    conn.ID,
+		IdentityError:   identityError,
+
+		statements:       newStatementLog(m.store, conn.ID),
+		levelFingerprint: nativerole.Fingerprint(*database, permission.Level),
+	}
+	session.expiryTimer = time.AfterFunc(time.Until(expiresAt), func() {
+		utils.Logger.Info("session expired", "zgate_user", claims.Username, "database", databaseName)
+		if err := m.stopSession(token, session); err != nil {
+			utils.Logger.Warn("failed to stop expired session", "zgate_user", claims.Username, "error", err)
+		}
+	})
+
+	// Start dynamic proxy in background
+	go m.startDynamicProxy(ctx, session, database)
+
+	// Store session
+	m.sessions[token] = session
+
+	utils.Logger.Info("session started",
+		"session_id", sessionID,
+		"ticket", ticket,
+		"zgate_user", claims.Username,
+		"database", databaseName,
+		"port", port,
+		"temp_user", tempUsername,
+		"expires_at", expiresAt,
+	)
+
+	if record != nil {
+		alertBreakGlass(record)
+	}
+
+	return session, nil
+}
+
+// existingSession returns the live session for token, if any; the caller holds m.mu.
+// A break-glass request is refused while a normal session is open.
+func (m *Manager) existingSession(token string, breakGlass *breakGlassRequest) (*Session, error) {
+	existing, exists := m.sessions[token]
+	if !exists {
+		return nil, nil
+	}
+	if breakGlass != nil && existing.BreakGlass == nil {
+		return nil, fmt.Errorf("%w: disconnect the current session first", ErrBreakGlassDenied)
+	}
+	return existing, nil
+}
+
+// StopSession stops a session and deletes temp database user
+func (m *Manager) StopSession(token string) error {
+	return m.stopSession(token, nil)
+}
+
+// stopSession stops the session under token. When want is set, it is only
+// stopped if it is still want, not a session started since under the same token.
+func (m *Manager) stopSession(token string, want *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[token]
+	if !exists || want != nil && session != want {
+		return fmt.Errorf("session not found")
+	}
+
+	utils.Logger.Info("stopping session",
+		"zgate_user", session.Username,
+		"database", session.DatabaseName,
+		"temp_user", session.TempCredentials.Username,
+	)
+
+	// Delete temp database user
+	ctx := context.Background()
+	if err := session.DBManager.DeleteTempUser(ctx, session.TempCredentials.Username); err != nil {
+		utils.Logger.Error("failed to delete temp user", "error", err)
+	}
+
+	// Close DB manager connection
+	if err := session.DBManager.Close(); err != nil {
+		utils.Logger.Error("failed to close DB manager", "error", err)
+	}
+
+	// Cancel context (stops the listener)
+	session.Cancel()
+	session.statements.Close()
+	session.expiryTimer.Stop()
+
+	// The temp user is gone, so an active elevation only needs closing out
+	session.grantsMu.Lock()
+	if session.Elevation != nil {
+		session.Elevation.timer.Stop()
+		m.endRecord(session.Elevation.Record.ID)
+		session.Elevation = nil
+	}
+	session.grantsMu.Unlock()
+	m.endBreakGlass(session.BreakGlass)
+	if err := m.store.EndConnection(session.ConnectionID, time.Now()); err != nil {
+		utils.Logger.Warn("failed to record connection end", "session_id", session.ID, "error", err)
+	}
+
+	// Remove from map
+	delete(m.sessions, token)
+
+	utils.Logger.Info("session stopped",
+		"zgate_user", session.Username,
+		"database", session.DatabaseName,
+	)
+
+	return nil
+}
+
+// onChange schedules a sync of live sessions; bursts of changes coalesce into one sync
+func (m *Manager) onChange(store.Change) {
+	select {
+	case m.changes <- struct{}{}:
+	default:
+	}
+}
+
+// syncLoop syncs live sessions after every change and every syncInterval
+func (m *Manager) syncLoop() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.changes:
+		case <-ticker.C:
+		}
+		m.syncSessions()
+	}
+}
+
+// syncSessions re-resolves every live session against the store. Sessions whose
+// permission changed get their backend grants replaced; sessions that lost access,
+// or whose grants cannot be updated, are stopped.
+func (m *Manager) syncSessions() {
+	m.mu.RLock()
+	sessions := maps.Clone(m.sessions)
+	m.mu.RUnlock()
+
+	for token, session := range sessions {
+		if err := m.syncSession(session); err != nil {
+			utils.Logger.Warn("stopping session after policy change",
+				"session_id", session.ID,
+				"zgate_user", session.Username,
+				"database", session.DatabaseName,
+				"reason", err,
+			)
+			if err := m.stopSession(token, session); err != nil {
+				utils.Logger.Warn("failed to stop session", "session_id", session.ID, "error", err)
+			}
+		}
+	}
+}
+
+// syncSession brings one session's backend grants in line with the store.
+// An elevation the store no longer allows is revoked along the way.
+// It returns an error only when the session has to end; transient store errors are logged.
+func (m *Manager) syncSession(session *Session) error {
+	session.grantsMu.Lock()
+	defer session.grantsMu.Unlock()
+
+	database, err := m.store.GetDatabase(session.DatabaseName)
+	if err != nil {
+		if store.IsNotFound(err) {
+			return fmt.Errorf("database was removed")
+		}
+		utils.Logger.Warn("failed to load database for session sync", "session_id", session.ID, "error", err)
+		return nil
+	}
+
+	var permission store.Permission
+	source := policy.BreakGlassSource
+	if session.BreakGlass == nil {
+		resolution, err := policy.Evaluate(m.store, session.Username, database, session.requestContext())
+		if err != nil {
+			if store.IsNotFound(err) {
+				return fmt.Errorf("user was removed")
+			}
+			utils.Logger.Warn("failed to load grants for session sync", "session_id", session.ID, "error", err)
+			return nil
+		}
+		if !resolution.Allowed {
+			return fmt.Errorf("access to %s was revoked: %s", database.Name, resolution.Reason)
+		}
+		permission, source = *resolution.Permission(), resolution.Source
+	} else {
+		// Break-glass sessions lose access with their break-glass eligibility, not with grants
+		granted, err := policy.EvaluateBreakGlass(m.store, session.Username, database, breakGlassGroups(), session.requestContext())
+		if denied := (*policy.DeniedError)(nil); errors.As(err, &denied) {
+			return fmt.Errorf("break-glass access to %s was revoked: %s", database.Name, denied.Reason)
+		}
+		if err != nil {
+			if store.IsNotFound(err) {
+				return fmt.Errorf("user was removed")
+			}
+			utils.Logger.Warn("failed to check break-glass access for session sync", "session_id", session.ID, "error", err)
+			return nil
+		}
+		permission = *granted
+	}
+
+	// Resource limits are fixed when the temp user is created, so only grants are compared
+	permission.Limits = session.Permission.Limits
+	fingerprint := nativerole.Fingerprint(*database, permission.Level)
+	permissions := []store.Permission{permission}
+
+	elevation := session.Elevation
+	if elevation != nil {
+		_, err := policy.EvaluateElevation(m.store, session.Username, database, elevation.Record.Level, session.requestContext())
+		if denied := (*policy.DeniedError)(nil); err != nil && !errors.As(err, &denied) {
+			utils.Logger.Warn("failed to check elevation for session sync", "session_id", session.ID, "error", err)
+			return nil
+		}
+		if err != nil {
+			utils.Logger.Info("revoking elevation after policy change", "session_id", session.ID, "reason", err)
+			elevation.timer.Stop()
+			m.endRecord(elevation.Record.ID)
+			session.Elevation = nil
+		} else {
+			permissions = append(permissions, elevation.Permission)
+		}
+	}
+
+	if reflect.DeepEqual(permission, session.Permission) && fingerprint == session.levelFingerprint && session.Elevation == elevation {
+		return nil
+	}
+
+	dbMgr, err := protocol.NewManager(*database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to update grants: %w", err)
+	}
+	defer dbMgr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	if err := dbMgr.ReplacePermissions(ctx, session.TempCredentials.Username, permissions); err != nil {
+		return fmt.Errorf("failed to update grants: %w", err)
+	}
+
+	session.Permission = permission
+	session.levelFingerprint = fingerprint
+
+	utils.Logger.Info("session permissions updated",
+		"session_id", session.ID,
+		"zgate_user", session.Username,
+		"database", session.DatabaseName,
+		"level", permission.Level,
+		"source", source,
+	)
+	return nil
+}
+
+// StopSessionsForUser stops every session owned by the given zGate user
+func (m *Manager) StopSessionsForUser(username string) int {
+	m.mu.RLock()
+	sessions := map[string]*Session{}
+	for token, session := range m.sessions {
+		if session.Username == username {
+			sessions[token] = session
+		}
+	}
+	m.mu.RUnlock()
+
+	stopped := 0
+	for token, session := range sessions {
+		if err := m.stopSession(token, session); err != nil {
+			utils.Logger.Warn("failed to stop session", "zgate_user", username, "error", err)
+			continue
+		}
+		stopped++
+	}
+	return stopped
+}
+
+// startDynamicProxy starts a listener on the dynamic port
+func (m *Manager) startDynamicProxy(ctx context.Context, session *Session, database *store.Database) {
+	listenAddr := fmt.Sprintf(":%d", session.Port)
+
+	handler := m.gwServer.GetHandler(database.Type)
+	if handler == nil {
+		utils.Logger.Error("handler not found", "type", database.Type)
+		return
+	}
+
+	listener := gateway.NewListener(*database, handler, session.statements)
+	if err := listener.Start(ctx, listenAddr); err != nil {
+		utils.Logger.Error("dynamic proxy stopped", "error", err)
+	}
+}
+
+// Helper functions
+
+// newSessionID returns a random 16 character hex session ID
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func getFreePort() (int, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.Port, nil
+}
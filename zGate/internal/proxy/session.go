@@ -1,19 +1,59 @@
-package proxy
-
-import (
-	"context"
-
-	"github.com/zGate-Team/zGate-Platform/internal/auth"
-	"github.com/zGate-Team/zGate-Platform/internal/protocol"
-)
-
-// Session represents an active user session with temp database user
-type Session struct {
-	Username        string
-	DatabaseName    string
-	Port            int
-	Claims          *auth.Claims
-	Cancel          context.CancelFunc
-	TempCredentials *protocol.TempCredentials
-	DBManager       protocol.Manager
-}
\ No newline at end of file
+package proxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/auth"
+	"github.com/zGate-Team/zGate-Platform/internal/policy"
+	"github.com/zGate-Team/zGate-Platform/internal/protocol"
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+// Session represents an active user session with temp database user
+type Session struct {
+	ID              string // random ID, exposed to the backend for audit
+	Ticket          string // optional ticket reference given at connect
+	ClientIP        net.IP // client address at connect, for policy conditions
+	Username        string
+	DatabaseName    string
+	Port            int
+	Claims          *auth.Claims
+	Cancel          context.CancelFunc
+	TempCredentials *protocol.TempCredentials
+	DBManager       protocol.Manager
+	ExpiresAt       time.Time
+	Permission      store.Permission // standing permission granted to the temp user
+	Elevation       *Elevation       // active elevation, if any
+	// BreakGlass records the emergency access behind a session opened without a grant
+	BreakGlass *store.BreakGlassSession
+	// ConnectionID identifies the stored connection record owners audit
+	ConnectionID int64
+	// IdentityError says why the backend could not attribute the session to Username, if it could not
+	IdentityError string
+
+	// statements records what clients send through the session's proxy
+	statements *statementLog
+	// expiryTimer stops the session at ExpiresAt
+	expiryTimer *time.Timer
+	// levelFingerprint identifies the level definition Permission was granted under
+	levelFingerprint string
+	// grantsMu serializes backend grant changes and guards Permission, Elevation and levelFingerprint
+	grantsMu sync.Mutex
+}
+
+// Elevation is a higher level granted to a live session for a bounded time
+type Elevation struct {
+	Record     store.Elevation
+	Permission store.Permission
+
+	// timer revokes the elevation at Record.ExpiresAt
+	timer *time.Timer
+}
+
+// requestContext is what policy conditions are re-checked against during the session
+func (s *Session) requestContext() policy.RequestContext {
+	return policy.RequestContext{ClientIP: s.ClientIP, BreakGlass: s.BreakGlass != nil}
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+	"github.com/zGate-Team/zGate-Platform/internal/utils"
+)
+
+// statementBacklog bounds the statements of one session waiting to be stored
+const statementBacklog = 1024
+
+// statementLog stores the statements a session's clients send. Statements are
+// queued and written by a goroutine of their own, so the store never slows the
+// forwarding path; when the queue is full they are dropped and the connection
+// record says so.
+type statementLog struct {
+	store        *store.Store
+	connectionID int64
+	queue        chan store.Statement
+	done         chan struct{}
+	closeOnce    sync.Once
+	dropped      atomic.Bool
+}
+
+// newStatementLog starts storing statements for the recorded connection connectionID
+func newStatementLog(s *store.Store, connectionID int64) *statementLog {
+	l := &statementLog{
+		store:        s,
+		connectionID: connectionID,
+		queue:        make(chan store.Statement, statementBacklog),
+		done:         make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Statement queues a statement a client sent
+func (l *statementLog) Statement(text string) {
+	select {
+	case <-l.done:
+		return
+	default:
+	}
+	select {
+	case l.queue <- store.Statement{Statement: text, ExecutedAt: time.Now()}:
+	default:
+		if !l.dropped.Swap(true) {
+			l.Unrecorded("statements arrived faster than they could be stored and some were dropped")
+		}
+	}
+}
+
+// Unrecorded notes on the connection record why some statements were not recorded
+func (l *statementLog) Unrecorded(reason string) {
+	if err := l.store.NoteUnrecordedStatements(l.connectionID, reason); err != nil {
+		utils.Logger.Warn("failed to note unrecorded statements", "connection_id", l.connectionID, "error", err)
+	}
+}
+
+// Close stops the log once the statements already queued are stored
+func (l *statementLog) Close() {
+	l.closeOnce.Do(func() { close(l.done) })
+}
+
+func (l *statementLog) run() {
+	for {
+		select {
+		case st := <-l.queue:
+			l.write(st)
+		case <-l.done:
+			for {
+				select {
+				case st := <-l.queue:
+					l.write(st)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *statementLog) write(st store.Statement) {
+	if err := l.store.RecordStatement(l.connectionID, st.Statement, st.ExecutedAt); err != nil {
+		utils.Logger.Warn("failed to record statement", "connection_id", l.connectionID, "error", err)
+	}
+}
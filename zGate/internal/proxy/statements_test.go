@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zGate-Team/zGate-Platform/internal/store"
+)
+
+func TestStatementLogDropsWhenFull(t *testing.T) {
+	s, err := store.NewStore(filepath.Join(t.TempDir(), "zgate.db"), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	conn := &store.Connection{SessionID: "s1", Username: "alice", Database: "orders", Level: "read", TempUsername: "zg_alice", StartedAt: time.Now()}
+	if err := s.RecordConnection(conn); err != nil {
+		t.Fatalf("RecordConnection: %v", err)
+	}
+
+	// The writer is started only once the queue has overflowed, so the first
+	// statementBacklog statements are queued and the rest dropped
+	l := &statementLog{
+		store:        s,
+		connectionID: conn.ID,
+		queue:        make(chan store.Statement, statementBacklog),
+		done:         make(chan struct{}),
+	}
+	for i := range statementBacklog + 10 {
+		l.Statement(fmt.Sprintf("SELECT %d", i))
+	}
+
+	recorded, err := s.GetConnection(conn.ID)
+	if err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	if recorded.StatementsNote == "" {
+		t.Fatalf("connection has no note about the dropped statements")
+	}
+
+	go l.run()
+	l.Close()
+	l.Statement("SELECT 'after close'")
+
+	var statements []store.Statement
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if statements, err = s.ListStatements(conn.ID); err != nil {
+			t.Fatalf("ListStatements: %v", err)
+		}
+		if len(statements) >= statementBacklog {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if statements, err = s.ListStatements(conn.ID); err != nil {
+		t.Fatalf("ListStatements: %v", err)
+	}
+	if len(statements) != statementBacklog {
+		t.Fatalf("stored %d statements; want the %d queued before the overflow", len(statements), statementBacklog)
+	}
+	if first, last := statements[0].Statement, statements[len(statements)-1].Statement; first != "SELECT 0" || last != fmt.Sprintf("SELECT %d", statementBacklog-1) {
+		t.Fatalf("stored %q to %q; want the first %d in order", first, last, statementBacklog)
+	}
+}
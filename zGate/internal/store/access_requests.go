@@ -0,0 +1,224 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrRequestState is returned when an access request is not in the state an action needs.
+var ErrRequestState = errors.New("action not allowed in this state")
+
+// accessRequestColumns lists the columns read by scanAccessRequest, in order.
+const accessRequestColumns = `id, username, database_name, level, minutes, justification, status,
+	requested_at, decided_by, decided_at, decision_note, expires_at, ended_at`
+
+// scanAccessRequest reads a row selected with accessRequestColumns.
+func scanAccessRequest(row interface{ Scan(...any) error }) (AccessRequest, error) {
+	var req AccessRequest
+	err := row.Scan(&req.ID, &req.Username, &req.Database, &req.Level, &req.Minutes, &req.Justification, &req.Status,
+		&req.RequestedAt, &req.DecidedBy, &req.DecidedAt, &req.DecisionNote, &req.ExpiresAt, &req.EndedAt)
+	return req, err
+}
+
+// CreateAccessRequest stores a new pending request and sets its ID, status and request time.
+func (s *Store) CreateAccessRequest(req *AccessRequest) error {
+	if req == nil {
+		return fmt.Errorf("access request is nil")
+	}
+
+	req.Status = AccessPending
+	req.RequestedAt = time.Now().UTC()
+	result, err := s.db.Exec(`
+		INSERT INTO access_requests (username, database_name, level, minutes, justification, status, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, req.Username, req.Database, req.Level, req.Minutes, req.Justification, req.Status, req.RequestedAt)
+	if err != nil {
+		return fmt.Errorf("create access request: %w", err)
+	}
+
+	req.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("create access request: %w", err)
+	}
+	return nil
+}
+
+// GetAccessRequest fetches an access request by ID.
+func (s *Store) GetAccessRequest(id int64) (*AccessRequest, error) {
+	row := s.db.QueryRow(`SELECT `+accessRequestColumns+` FROM access_requests WHERE id = ?`, id)
+	req, err := scanAccessRequest(row)
+	if err != nil {
+		return nil, fmt.Errorf("fetch access request: %w", err)
+	}
+	return &req, nil
+}
+
+// ListAccessRequests returns matching access requests, newest first.
+func (s *Store) ListAccessRequests(filter AccessRequestFilter) ([]AccessRequest, error) {
+	var conds []string
+	var args []any
+	for column, value := range map[string]string{
+		"username":      filter.Username,
+		"database_name": filter.Database,
+		"status":        filter.Status,
+	} {
+		if value != "" {
+			conds = append(conds, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	query := `SELECT ` + accessRequestColumns + ` FROM access_requests`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY requested_at DESC, id DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list access requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []AccessRequest{}
+	for rows.Next() {
+		req, err := scanAccessRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan access request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate access requests: %w", err)
+	}
+	return requests, nil
+}
+
+// DecideAccessRequest approves or denies a pending request. An approved request
+// grants access from now for its requested minutes.
+func (s *Store) DecideAccessRequest(id int64, approve bool, decidedBy, note string) (*AccessRequest, error) {
+	req, err := s.GetAccessRequest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	status := AccessDenied
+	var expiresAt *time.Time
+	if approve {
+		status = AccessApproved
+		end := now.Add(time.Duration(req.Minutes) * time.Minute)
+		expiresAt = &end
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE access_requests SET status = ?, decided_by = ?, decided_at = ?, decision_note = ?, expires_at = ?
+		WHERE id = ? AND status = ?
+	`, status, decidedBy, now, note, expiresAt, id, AccessPending)
+	if err != nil {
+		return nil, fmt.Errorf("decide access request: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, fmt.Errorf("access request %d is %s: %w", id, req.Status, ErrRequestState)
+	}
+
+	if approve {
+		s.notify(Change{Kind: UserChanged, Name: req.Username})
+	}
+	return s.GetAccessRequest(id)
+}
+
+// CancelAccessRequest lets a user withdraw their own pending request.
+func (s *Store) CancelAccessRequest(id int64, username string) error {
+	req, err := s.GetAccessRequest(id)
+	if err != nil {
+		return err
+	}
+	if req.Username != username {
+		return fmt.Errorf("cancel access request: %w", sql.ErrNoRows)
+	}
+	return s.endAccessRequest(req, AccessPending, AccessCancelled)
+}
+
+// RevokeAccessRequest ends an approved request's grant before it expires.
+func (s *Store) RevokeAccessRequest(id int64) error {
+	req, err := s.GetAccessRequest(id)
+	if err != nil {
+		return err
+	}
+	return s.endAccessRequest(req, AccessApproved, AccessRevoked)
+}
+
+// ExpireAccessRequests marks approved requests whose grant has run out as expired and returns them.
+func (s *Store) ExpireAccessRequests() ([]AccessRequest, error) {
+	rows, err := s.db.Query(`SELECT `+accessRequestColumns+` FROM access_requests WHERE status = ? AND expires_at <= ?`,
+		AccessApproved, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list expired access requests: %w", err)
+	}
+	var due []AccessRequest
+	for rows.Next() {
+		req, err := scanAccessRequest(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan access request: %w", err)
+		}
+		due = append(due, req)
+	}
+	rows.Close()
+
+	var expired []AccessRequest
+	for i := range due {
+		if err := s.endAccessRequest(&due[i], AccessApproved, AccessExpired); err != nil {
+			if errors.Is(err, ErrRequestState) {
+				continue
+			}
+			return expired, err
+		}
+		expired = append(expired, due[i])
+	}
+	return expired, nil
+}
+
+// ActiveAccessGrants returns the approved requests of username whose grant is in effect now.
+func (s *Store) ActiveAccessGrants(username string) ([]AccessRequest, error) {
+	rows, err := s.db.Query(`SELECT `+accessRequestColumns+` FROM access_requests WHERE username = ? AND status = ? AND expires_at > ?`,
+		username, AccessApproved, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("active access grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []AccessRequest
+	for rows.Next() {
+		req, err := scanAccessRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan access request: %w", err)
+		}
+		grants = append(grants, req)
+	}
+	return grants, rows.Err()
+}
+
+// endAccessRequest moves req from one status to a final one and records when.
+// Ending an approved request notifies subscribers, since it takes access away.
+func (s *Store) endAccessRequest(req *AccessRequest, from, to string) error {
+	now := time.Now().UTC()
+	result, err := s.db.Exec(`UPDATE access_requests SET status = ?, ended_at = ? WHERE id = ? AND status = ?`, to, now, req.ID, from)
+	if err != nil {
+		return fmt.Errorf("update access request: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("access request %d is %s: %w", req.ID, req.Status, ErrRequestState)
+	}
+
+	req.Status = to
+	req.EndedAt = &now
+	if from == AccessApproved {
+		s.notify(Change{Kind: UserChanged, Name: req.Username})
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// breakGlassColumns lists the columns read by scanBreakGlassSession, in order.
+const breakGlassColumns = `id, session_id, username, database_name, level, incident, justification, client_ip,
+	started_at, expires_at, ended_at, reviewed_by, reviewed_at, review_note`
+
+// scanBreakGlassSession reads a row selected with breakGlassColumns.
+func scanBreakGlassSession(row interface{ Scan(...any) error }) (BreakGlassSession, error) {
+	var b BreakGlassSession
+	err := row.Scan(&b.ID, &b.SessionID, &b.Username, &b.Database, &b.Level, &b.Incident, &b.Justification, &b.ClientIP,
+		&b.StartedAt, &b.ExpiresAt, &b.EndedAt, &b.ReviewedBy, &b.ReviewedAt, &b.ReviewNote)
+	return b, err
+}
+
+// RecordBreakGlassSession stores a new break-glass session and sets its ID.
+func (s *Store) RecordBreakGlassSession(b *BreakGlassSession) error {
+	if b == nil {
+		return fmt.Errorf("break-glass session is nil")
+	}
+
+	b.StartedAt = b.StartedAt.UTC()
+	b.ExpiresAt = b.ExpiresAt.UTC()
+	result, err := s.db.Exec(`
+		INSERT INTO break_glass_sessions (session_id, username, database_name, level, incident, justification, client_ip, started_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, b.SessionID, b.Username, b.Database, b.Level, b.Incident, b.Justification, b.ClientIP, b.StartedAt, b.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("record break-glass session: %w", err)
+	}
+
+	b.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("record break-glass session: %w", err)
+	}
+	return nil
+}
+
+// EndBreakGlassSession marks a break-glass session as ended at endedAt, unless it already ended.
+func (s *Store) EndBreakGlassSession(id int64, endedAt time.Time) error {
+	if _, err := s.db.Exec(`UPDATE break_glass_sessions SET ended_at = ? WHERE id = ? AND ended_at IS NULL`, endedAt.UTC(), id); err != nil {
+		return fmt.Errorf("end break-glass session: %w", err)
+	}
+	return nil
+}
+
+// GetBreakGlassSession fetches a break-glass session by ID.
+func (s *Store) GetBreakGlassSession(id int64) (*BreakGlassSession, error) {
+	row := s.db.QueryRow(`SELECT `+breakGlassColumns+` FROM break_glass_sessions WHERE id = ?`, id)
+	b, err := scanBreakGlassSession(row)
+	if err != nil {
+		return nil, fmt.Errorf("fetch break-glass session: %w", err)
+	}
+	return &b, nil
+}
+
+// ListBreakGlassSessions returns break-glass sessions newest first, only those
+// not yet reviewed when unreviewed is set.
+func (s *Store) ListBreakGlassSessions(unreviewed bool) ([]BreakGlassSession, error) {
+	rows, err := s.db.Query(`
+		SELECT `+breakGlassColumns+` FROM break_glass_sessions
+		WHERE NOT ? OR reviewed_at IS NULL
+		ORDER BY started_at DESC, id DESC
+	`, unreviewed)
+	if err != nil {
+		return nil, fmt.Errorf("list break-glass sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []BreakGlassSession{}
+	for rows.Next() {
+		b, err := scanBreakGlassSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan break-glass session: %w", err)
+		}
+		sessions = append(sessions, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate break-glass sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// ReviewBreakGlassSession signs off a break-glass session once it is over.
+// Each session is reviewed once; reviewing a live or already reviewed session
+// returns ErrRequestState.
+func (s *Store) ReviewBreakGlassSession(id int64, reviewedBy, note string) (*BreakGlassSession, error) {
+	b, err := s.GetBreakGlassSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	result, err := s.db.Exec(`
+		UPDATE break_glass_sessions SET reviewed_by = ?, reviewed_at = ?, review_note = ?
+		WHERE id = ? AND reviewed_at IS NULL AND (ended_at IS NOT NULL OR expires_at <= ?)
+	`, reviewedBy, now, note, id, now)
+	if err != nil {
+		return nil, fmt.Errorf("review break-glass session: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		state := "still active"
+		if b.ReviewedAt != nil {
+			state = "already reviewed"
+		}
+		return nil, fmt.Errorf("break-glass session %d is %s: %w", id, state, ErrRequestState)
+	}
+	return s.GetBreakGlassSession(id)
+}
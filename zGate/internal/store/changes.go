@@ -0,0 +1,35 @@
+package store
+
+// ChangeKind identifies the kind of policy data a change touched.
+type ChangeKind string
+
+const (
+	UserChanged     ChangeKind = "user"
+	RoleChanged     ChangeKind = "role"
+	DatabaseChanged ChangeKind = "database" // includes the database's permission levels
+	PolicyChanged   ChangeKind = "policy"
+	GroupChanged    ChangeKind = "group" // includes membership and role assignments
+)
+
+// Change describes a committed write to users, groups, roles, databases, permission levels or policies.
+type Change struct {
+	Kind ChangeKind
+	Name string
+}
+
+// Subscribe registers fn to be called after every committed policy change.
+// fn runs on the writer's goroutine, so it must return quickly.
+func (s *Store) Subscribe(fn func(Change)) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// notify tells every subscriber about a committed change.
+func (s *Store) notify(change Change) {
+	s.subscribersMu.RLock()
+	defer s.subscribersMu.RUnlock()
+	for _, fn := range s.subscribers {
+		fn(change)
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordConnection stores a new proxy session and sets its ID.
+func (s *Store) RecordConnection(c *Connection) error {
+	if c == nil {
+		return fmt.Errorf("connection is nil")
+	}
+
+	c.StartedAt = c.StartedAt.UTC()
+	result, err := s.db.Exec(`
+		INSERT INTO connections (session_id, username, database_name, level, source, ticket, client_ip, temp_username, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.SessionID, c.Username, c.Database, c.Level, c.Source, c.Ticket, c.ClientIP, c.TempUsername, c.StartedAt)
+	if err != nil {
+		return fmt.Errorf("record connection: %w", err)
+	}
+
+	c.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("record connection: %w", err)
+	}
+	return nil
+}
+
+// EndConnection marks a proxy session as ended at endedAt, unless it already ended.
+func (s *Store) EndConnection(id int64, endedAt time.Time) error {
+	if _, err := s.db.Exec(`UPDATE connections SET ended_at = ? WHERE id = ? AND ended_at IS NULL`, endedAt.UTC(), id); err != nil {
+		return fmt.Errorf("end connection: %w", err)
+	}
+	return nil
+}
+
+// EndOpenConnections marks every proxy session that has not ended as ended at endedAt.
+// Sessions do not outlive the process, so rows left open were cut short by a restart.
+func (s *Store) EndOpenConnections(endedAt time.Time) (int64, error) {
+	result, err := s.db.Exec(`UPDATE connections SET ended_at = ? WHERE ended_at IS NULL`, endedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("end open connections: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("end open connections: %w", err)
+	}
+	return n, nil
+}
+
+// GetConnection fetches a recorded proxy session.
+func (s *Store) GetConnection(id int64) (*Connection, error) {
+	c, err := scanConnection(s.db.QueryRow(`SELECT `+connectionColumns+` FROM connections WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("fetch connection: %w", err)
+	}
+	return c, nil
+}
+
+// RecordStatement stores a statement a client sent through a proxy session.
+func (s *Store) RecordStatement(connectionID int64, statement string, executedAt time.Time) error {
+	if _, err := s.db.Exec(`INSERT INTO connection_statements (connection_id, statement, executed_at) VALUES (?, ?, ?)`,
+		connectionID, statement, executedAt.UTC()); err != nil {
+		return fmt.Errorf("record statement: %w", err)
+	}
+	return nil
+}
+
+// NoteUnrecordedStatements records why some of a proxy session's statements were
+// not recorded. The first note is kept.
+func (s *Store) NoteUnrecordedStatements(connectionID int64, note string) error {
+	if _, err := s.db.Exec(`UPDATE connections SET statements_note = ? WHERE id = ? AND statements_note = ''`, note, connectionID); err != nil {
+		return fmt.Errorf("note unrecorded statements: %w", err)
+	}
+	return nil
+}
+
+// ListStatements returns the statements recorded for a proxy session in the order they were sent.
+func (s *Store) ListStatements(connectionID int64) ([]Statement, error) {
+	rows, err := s.db.Query(`
+		SELECT id, connection_id, statement, executed_at FROM connection_statements
+		WHERE connection_id = ? ORDER BY executed_at, id
+	`, connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("list statements: %w", err)
+	}
+	defer rows.Close()
+
+	statements := []Statement{}
+	for rows.Next() {
+		var st Statement
+		if err := rows.Scan(&st.ID, &st.ConnectionID, &st.Statement, &st.ExecutedAt); err != nil {
+			return nil, fmt.Errorf("scan statement: %w", err)
+		}
+		statements = append(statements, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate statements: %w", err)
+	}
+	return statements, nil
+}
+
+// connectionColumns lists the columns read by scanConnection, in order.
+const connectionColumns = `id, session_id, username, database_name, level, source, ticket, client_ip, temp_username, started_at, ended_at, statements_note`
+
+// scanConnection reads a row selected with connectionColumns.
+func scanConnection(row interface{ Scan(...any) error }) (*Connection, error) {
+	var c Connection
+	if err := row.Scan(&c.ID, &c.SessionID, &c.Username, &c.Database, &c.Level, &c.Source, &c.Ticket, &c.ClientIP,
+		&c.TempUsername, &c.StartedAt, &c.EndedAt, &c.StatementsNote); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListConnections returns recorded proxy sessions newest first.
+func (s *Store) ListConnections(filter ConnectionFilter) ([]Connection, error) {
+	rows, err := s.db.Query(`
+		SELECT `+connectionColumns+`
+		FROM connections
+		WHERE (? = '' OR username = ?) AND (? = '' OR database_name = ?)
+		ORDER BY started_at DESC, id DESC
+	`, filter.Username, filter.Username, filter.Database, filter.Database)
+	if err != nil {
+		return nil, fmt.Errorf("list connections: %w", err)
+	}
+	defer rows.Close()
+
+	connections := []Connection{}
+	for rows.Next() {
+		c, err := scanConnection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan connection: %w", err)
+		}
+		connections = append(connections, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate connections: %w", err)
+	}
+	return connections, nil
+}
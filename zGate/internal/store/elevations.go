@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordElevation stores a new elevation and sets its ID.
+func (s *Store) RecordElevation(e *Elevation) error {
+	if e == nil {
+		return fmt.Errorf("elevation is nil")
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO elevations (session_id, username, database_name, level, justification, started_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.SessionID, e.Username, e.Database, e.Level, e.Justification, e.StartedAt, e.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("record elevation: %w", err)
+	}
+
+	e.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("record elevation: %w", err)
+	}
+	return nil
+}
+
+// EndElevation marks an elevation as ended at endedAt, unless it already ended.
+func (s *Store) EndElevation(id int64, endedAt time.Time) error {
+	if _, err := s.db.Exec(`UPDATE elevations SET ended_at = ? WHERE id = ? AND ended_at IS NULL`, endedAt, id); err != nil {
+		return fmt.Errorf("end elevation: %w", err)
+	}
+	return nil
+}
+
+// ListElevations returns elevations newest first, only those of username when it is not empty.
+func (s *Store) ListElevations(username string) ([]Elevation, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session_id, username, database_name, level, justification, started_at, expires_at, ended_at
+		FROM elevations
+		WHERE ? = '' OR username = ?
+		ORDER BY started_at DESC, id DESC
+	`, username, username)
+	if err != nil {
+		return nil, fmt.Errorf("list elevations: %w", err)
+	}
+	defer rows.Close()
+
+	elevations := []Elevation{}
+	for rows.Next() {
+		var e Elevation
+		if err := rows.Scan(&e.ID, &e.SessionID, &e.Username, &e.Database, &e.Level, &e.Justification, &e.StartedAt, &e.ExpiresAt, &e.EndedAt); err != nil {
+			return nil, fmt.Errorf("scan elevation: %w", err)
+		}
+		elevations = append(elevations, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate elevations: %w", err)
+	}
+	return elevations, nil
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrGroupCycle is returned when saving a group would make it its own ancestor.
+var ErrGroupCycle = errors.New("group nesting cycle")
+
+// userGroupsQuery selects every group a user belongs to, directly or through a
+// group nested in it. UNION drops rows already seen, so the recursion also ends on a cycle.
+const userGroupsQuery = `
+	WITH RECURSIVE member_of(name) AS (
+		SELECT group_name FROM group_members WHERE username = ?
+		UNION
+		SELECT gp.parent_name FROM group_parents gp JOIN member_of m ON gp.group_name = m.name
+	)
+	SELECT name FROM member_of`
+
+// groupAncestorsQuery selects every group a group is nested in, directly or indirectly.
+const groupAncestorsQuery = `
+	WITH RECURSIVE ancestors(name) AS (
+		SELECT parent_name FROM group_parents WHERE group_name = ?
+		UNION
+		SELECT gp.parent_name FROM group_parents gp JOIN ancestors a ON gp.group_name = a.name
+	)
+	SELECT name FROM ancestors`
+
+// groupMembersQuery selects every user who belongs to a group, directly or through a
+// group nested in it.
+const groupMembersQuery = `
+	WITH RECURSIVE nested(name) AS (
+		SELECT ?
+		UNION
+		SELECT gp.group_name FROM group_parents gp JOIN nested n ON gp.parent_name = n.name
+	)
+	SELECT DISTINCT username FROM group_members WHERE group_name IN (SELECT name FROM nested)`
+
+// SaveGroup inserts or updates a group and replaces its parents, members and roles.
+// It fails with ErrSoDViolation, saving nothing, when a member would newly break a separation-of-duties rule.
+func (s *Store) SaveGroup(group *Group) error {
+	if group == nil {
+		return fmt.Errorf("group is nil")
+	}
+
+	for _, parent := range group.Parents {
+		if parent == group.Name {
+			return fmt.Errorf("%w: %s cannot be nested in itself", ErrGroupCycle, group.Name)
+		}
+		ancestors, err := s.queryNames(groupAncestorsQuery, parent)
+		if err != nil {
+			return fmt.Errorf("group ancestors: %w", err)
+		}
+		if slices.Contains(ancestors, group.Name) {
+			return fmt.Errorf("%w: %s is already nested in %s", ErrGroupCycle, parent, group.Name)
+		}
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Members of groups nested in this one gain its roles too
+	var members []string
+	if members, err = queryNamesIn(tx, groupMembersQuery, group.Name); err != nil {
+		return fmt.Errorf("group members: %w", err)
+	}
+	for _, member := range group.Members {
+		if !slices.Contains(members, member) {
+			members = append(members, member)
+		}
+	}
+	var baseline map[string][]string
+	if baseline, err = sodBaseline(tx, members); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO groups (name, description) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET description=excluded.description
+	`, group.Name, group.Description); err != nil {
+		return err
+	}
+
+	links := []struct {
+		table, column string
+		values        []string
+	}{
+		{"group_parents", "parent_name", group.Parents},
+		{"group_members", "username", group.Members},
+		{"group_roles", "role_name", group.Roles},
+	}
+	for _, link := range links {
+		if _, err = tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE group_name = ?`, link.table), group.Name); err != nil {
+			return err
+		}
+		for _, value := range link.values {
+			if _, err = tx.Exec(fmt.Sprintf(`INSERT INTO %s (group_name, %s) VALUES (?, ?)`, link.table, link.column), group.Name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = checkSoD(tx, members, baseline); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	s.notify(Change{Kind: GroupChanged, Name: group.Name})
+	return nil
+}
+
+// GetGroup fetches a group with its parents, members and roles.
+func (s *Store) GetGroup(name string) (*Group, error) {
+	var group Group
+	if err := s.db.QueryRow(`SELECT name, description, created_at FROM groups WHERE name = ?`, name).
+		Scan(&group.Name, &group.Description, &group.CreatedAt); err != nil {
+		return nil, fmt.Errorf("fetch group: %w", err)
+	}
+	if err := s.loadGroupLinks(&group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ListGroups returns all groups with their parents, members and roles.
+func (s *Store) ListGroups() ([]Group, error) {
+	rows, err := s.db.Query(`SELECT name, description, created_at FROM groups ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []Group
+	for rows.Next() {
+		var group Group
+		if err := rows.Scan(&group.Name, &group.Description, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range groups {
+		if err := s.loadGroupLinks(&groups[i]); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// DeleteGroup removes a group; its memberships, role assignments and nesting links cascade.
+func (s *Store) DeleteGroup(name string) error {
+	result, err := s.db.Exec(`DELETE FROM groups WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete group: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("delete group: %w", sql.ErrNoRows)
+	}
+	s.notify(Change{Kind: GroupChanged, Name: name})
+	return nil
+}
+
+// GetGroupsForUser returns every group username belongs to, directly or through nested groups.
+func (s *Store) GetGroupsForUser(username string) ([]string, error) {
+	groups, err := s.queryNames(userGroupsQuery, username)
+	if err != nil {
+		return nil, fmt.Errorf("groups for user: %w", err)
+	}
+	return groups, nil
+}
+
+// GetGroupRolesForUser returns the roles username holds through groups, each once.
+func (s *Store) GetGroupRolesForUser(username string) ([]string, error) {
+	roles, err := s.queryNames(`SELECT DISTINCT role_name FROM group_roles WHERE group_name IN (`+userGroupsQuery+`) ORDER BY role_name`, username)
+	if err != nil {
+		return nil, fmt.Errorf("group roles for user: %w", err)
+	}
+	return roles, nil
+}
+
+// loadGroupLinks reads a group's parents, members and roles.
+func (s *Store) loadGroupLinks(group *Group) error {
+	var err error
+	if group.Parents, err = s.queryNames(`SELECT parent_name FROM group_parents WHERE group_name = ? ORDER BY parent_name`, group.Name); err != nil {
+		return fmt.Errorf("group parents: %w", err)
+	}
+	if group.Members, err = s.queryNames(`SELECT username FROM group_members WHERE group_name = ? ORDER BY username`, group.Name); err != nil {
+		return fmt.Errorf("group members: %w", err)
+	}
+	if group.Roles, err = s.queryNames(`SELECT role_name FROM group_roles WHERE group_name = ? ORDER BY role_name`, group.Name); err != nil {
+		return fmt.Errorf("group roles: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNestedGroups(t *testing.T) {
+	s := newTestStore(t)
+	saveTestRole(t, s, Role{Name: "employee"})
+	saveTestRole(t, s, Role{Name: "developer"})
+	saveTestRole(t, s, Role{Name: "oncall"})
+	saveTestUser(t, s, "alice")
+	saveTestUser(t, s, "bob")
+	saveTestGroup(t, s, Group{Name: "staff", Roles: []string{"employee"}})
+	saveTestGroup(t, s, Group{Name: "engineering", Parents: []string{"staff"}, Roles: []string{"developer"}})
+	saveTestGroup(t, s, Group{Name: "sre", Parents: []string{"engineering"}, Members: []string{"alice"}, Roles: []string{"oncall"}})
+	saveTestGroup(t, s, Group{Name: "platform", Parents: []string{"engineering", "staff"}, Members: []string{"alice", "bob"}})
+
+	tests := []struct {
+		username string
+		groups   []string
+		roles    []string
+	}{
+		{"alice", []string{"engineering", "platform", "sre", "staff"}, []string{"developer", "employee", "oncall"}},
+		{"bob", []string{"engineering", "platform", "staff"}, []string{"developer", "employee"}},
+		{"carol", []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		groups, err := s.GetGroupsForUser(tt.username)
+		if err != nil {
+			t.Fatalf("GetGroupsForUser(%s): %v", tt.username, err)
+		}
+		if !slices.Equal(sorted(groups), tt.groups) {
+			t.Errorf("GetGroupsForUser(%s) = %q; want %q", tt.username, groups, tt.groups)
+		}
+		roles, err := s.GetGroupRolesForUser(tt.username)
+		if err != nil {
+			t.Fatalf("GetGroupRolesForUser(%s): %v", tt.username, err)
+		}
+		if !slices.Equal(nonNil(roles), tt.roles) {
+			t.Errorf("GetGroupRolesForUser(%s) = %q; want %q", tt.username, roles, tt.roles)
+		}
+	}
+
+	// Unnesting a group takes its ancestors' roles away from its members
+	saveTestGroup(t, s, Group{Name: "sre", Members: []string{"alice"}, Roles: []string{"oncall"}})
+	saveTestGroup(t, s, Group{Name: "platform", Members: []string{"alice", "bob"}})
+	roles, err := s.GetGroupRolesForUser("alice")
+	if err != nil {
+		t.Fatalf("GetGroupRolesForUser: %v", err)
+	}
+	if want := []string{"oncall"}; !slices.Equal(roles, want) {
+		t.Fatalf("alice holds %q through groups after unnesting; want %q", roles, want)
+	}
+}
+
+func TestSaveGroupRejectsCycles(t *testing.T) {
+	s := newTestStore(t)
+	saveTestGroup(t, s, Group{Name: "a"})
+	saveTestGroup(t, s, Group{Name: "b", Parents: []string{"a"}})
+	saveTestGroup(t, s, Group{Name: "c", Parents: []string{"b"}})
+
+	tests := []struct {
+		name  string
+		group Group
+	}{
+		{"self", Group{Name: "a", Parents: []string{"a"}}},
+		{"two groups", Group{Name: "a", Parents: []string{"b"}}},
+		{"longer cycle", Group{Name: "a", Parents: []string{"c"}}},
+		{"cycle among several parents", Group{Name: "b", Parents: []string{"a", "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.SaveGroup(&tt.group); !errors.Is(err, ErrGroupCycle) {
+				t.Fatalf("SaveGroup(%s with parents %q) = %v; want ErrGroupCycle", tt.group.Name, tt.group.Parents, err)
+			}
+		})
+	}
+
+	for name, want := range map[string][]string{"a": {}, "b": {"a"}, "c": {"b"}} {
+		group, err := s.GetGroup(name)
+		if err != nil {
+			t.Fatalf("GetGroup(%s): %v", name, err)
+		}
+		if !slices.Equal(nonNil(group.Parents), want) {
+			t.Fatalf("%s has parents %q; want %q", name, group.Parents, want)
+		}
+	}
+
+	// A diamond is not a cycle
+	saveTestGroup(t, s, Group{Name: "d", Parents: []string{"b", "c"}})
+}
+
+func TestSaveGroupChecksSoDOnMembership(t *testing.T) {
+	s := newSoDStore(t)
+	saveTestUser(t, s, "alice", "requester")
+	saveTestUser(t, s, "bob", "requester")
+	saveTestUser(t, s, "erin")
+	saveTestGroup(t, s, Group{Name: "approvers", Roles: []string{"approver"}, Members: []string{"erin"}})
+	saveTestGroup(t, s, Group{Name: "team", Parents: []string{"approvers"}})
+
+	// alice would gain approver as a direct member
+	if err := s.SaveGroup(&Group{Name: "approvers", Roles: []string{"approver"}, Members: []string{"erin", "alice"}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("adding alice to approvers = %v; want ErrSoDViolation", err)
+	}
+	// bob would gain approver as a member of a group nested in approvers
+	if err := s.SaveGroup(&Group{Name: "team", Parents: []string{"approvers"}, Members: []string{"bob"}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("adding bob to team = %v; want ErrSoDViolation", err)
+	}
+	for _, username := range []string{"alice", "bob"} {
+		if groups, err := s.GetGroupsForUser(username); err != nil || len(groups) != 0 {
+			t.Fatalf("%s is in %q, %v after refused saves", username, groups, err)
+		}
+	}
+
+	// Members without a conflicting role join freely, and anyone may leave
+	saveTestUser(t, s, "frank")
+	saveTestGroup(t, s, Group{Name: "team", Parents: []string{"approvers"}, Members: []string{"frank"}})
+	saveTestGroup(t, s, Group{Name: "approvers", Roles: []string{"approver"}})
+	groups, err := s.GetGroupsForUser("erin")
+	if err != nil {
+		t.Fatalf("GetGroupsForUser: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Fatalf("erin is still in %q after leaving", groups)
+	}
+}
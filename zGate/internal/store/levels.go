@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// SavePermissionLevel inserts or updates a custom permission level for a database
+// and, in the same transaction, sets the database's available permissions to
+// availablePermissions, whose order ranks the level among the others.
+func (s *Store) SavePermissionLevel(level *PermissionLevel, availablePermissions []string) error {
+	if level == nil {
+		return fmt.Errorf("permission level is nil")
+	}
+
+	statements, err := json.Marshal(nonNil(level.Statements))
+	if err != nil {
+		return fmt.Errorf("serialize statements: %w", err)
+	}
+	nativeRoles, err := json.Marshal(nonNil(level.NativeRoles))
+	if err != nil {
+		return fmt.Errorf("serialize native roles: %w", err)
+	}
+	objects, err := encodeObjects(level.Objects)
+	if err != nil {
+		return err
+	}
+	limits, err := encodeLimits(level.Limits)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`
+		INSERT INTO permission_levels (database_name, name, description, statements, native_roles, objects, limits)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(database_name, name) DO UPDATE SET
+			description=excluded.description,
+			statements=excluded.statements,
+			native_roles=excluded.native_roles,
+			objects=excluded.objects,
+			limits=excluded.limits,
+			updated_at=CURRENT_TIMESTAMP
+	`, level.Database, level.Name, level.Description, string(statements), string(nativeRoles), objects, limits); err != nil {
+		return fmt.Errorf("upsert permission level: %w", err)
+	}
+	if err = setAvailablePermissions(tx, level.Database, availablePermissions); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	s.notify(Change{Kind: DatabaseChanged, Name: level.Database})
+	return nil
+}
+
+// ListPermissionLevels returns the custom permission levels defined for a database.
+func (s *Store) ListPermissionLevels(databaseName string) ([]PermissionLevel, error) {
+	rows, err := s.db.Query(`
+		SELECT database_name, name, description, statements, native_roles, objects, limits, updated_at
+		FROM permission_levels WHERE database_name = ? ORDER BY name
+	`, databaseName)
+	if err != nil {
+		return nil, fmt.Errorf("list permission levels: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []PermissionLevel
+	for rows.Next() {
+		var level PermissionLevel
+		var description sql.NullString
+		var statements, nativeRoles, objects, limits string
+		if err := rows.Scan(&level.Database, &level.Name, &description, &statements, &nativeRoles, &objects, &limits, &level.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan permission level: %w", err)
+		}
+		level.Description = description.String
+		if err := json.Unmarshal([]byte(statements), &level.Statements); err != nil {
+			return nil, fmt.Errorf("parse statements: %w", err)
+		}
+		if err := json.Unmarshal([]byte(nativeRoles), &level.NativeRoles); err != nil {
+			return nil, fmt.Errorf("parse native roles: %w", err)
+		}
+		if err := json.Unmarshal([]byte(objects), &level.Objects); err != nil {
+			return nil, fmt.Errorf("parse object grants: %w", err)
+		}
+		if level.Limits, err = decodeLimits(limits); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate permission levels: %w", err)
+	}
+	return levels, nil
+}
+
+// DeletePermissionLevel removes a custom permission level and, in the same
+// transaction, takes it off the database's available permissions.
+func (s *Store) DeletePermissionLevel(databaseName, name string) error {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var result sql.Result
+	if result, err = tx.Exec(`DELETE FROM permission_levels WHERE database_name = ? AND name = ?`, databaseName, name); err != nil {
+		return fmt.Errorf("delete permission level: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		err = fmt.Errorf("delete permission level: %w", sql.ErrNoRows)
+		return err
+	}
+
+	var permsJSON string
+	if err = tx.QueryRow(`SELECT available_permissions FROM databases WHERE name = ?`, databaseName).Scan(&permsJSON); err != nil {
+		return fmt.Errorf("fetch available permissions: %w", err)
+	}
+	var perms []string
+	if err = json.Unmarshal([]byte(permsJSON), &perms); err != nil {
+		return fmt.Errorf("parse permissions: %w", err)
+	}
+	perms = slices.DeleteFunc(perms, func(level string) bool { return level == name })
+	if err = setAvailablePermissions(tx, databaseName, perms); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	s.notify(Change{Kind: DatabaseChanged, Name: databaseName})
+	return nil
+}
+
+// setAvailablePermissions replaces the levels a database offers, least privileged first.
+func setAvailablePermissions(tx *sql.Tx, databaseName string, availablePermissions []string) error {
+	permsJSON, err := json.Marshal(nonNil(availablePermissions))
+	if err != nil {
+		return fmt.Errorf("serialize permissions: %w", err)
+	}
+	result, err := tx.Exec(`UPDATE databases SET available_permissions = ?, updated_at = CURRENT_TIMESTAMP WHERE name = ?`,
+		string(permsJSON), databaseName)
+	if err != nil {
+		return fmt.Errorf("update available permissions: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("update available permissions: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSavePermissionLevelRanksLevel(t *testing.T) {
+	s := newTestStore(t)
+	saveTestDatabase(t, s, "orders", "read", "write", "admin")
+
+	level := &PermissionLevel{Database: "orders", Name: "reporting", NativeRoles: []string{"reporting"}}
+	if err := s.SavePermissionLevel(level, []string{"read", "write", "reporting", "admin"}); err != nil {
+		t.Fatalf("SavePermissionLevel: %v", err)
+	}
+
+	db, err := s.GetDatabase("orders")
+	if err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	if want := []string{"read", "write", "reporting", "admin"}; !slices.Equal(db.AvailablePermissions, want) {
+		t.Fatalf("AvailablePermissions = %q; want %q", db.AvailablePermissions, want)
+	}
+	if _, ok := db.Level("reporting"); !ok {
+		t.Fatalf("level reporting was not saved")
+	}
+	if db.Rank("reporting") <= db.Rank("write") || db.Rank("reporting") >= db.Rank("admin") {
+		t.Fatalf("reporting ranks %d; want between write and admin", db.Rank("reporting"))
+	}
+}
+
+func TestSavePermissionLevelWritesNothingOnFailure(t *testing.T) {
+	s := newTestStore(t)
+
+	// The database does not exist, so neither write may take effect
+	level := &PermissionLevel{Database: "missing", Name: "reporting", NativeRoles: []string{"reporting"}}
+	if err := s.SavePermissionLevel(level, []string{"read", "reporting"}); err == nil {
+		t.Fatalf("SavePermissionLevel on a missing database succeeded")
+	}
+	levels, err := s.ListPermissionLevels("missing")
+	if err != nil {
+		t.Fatalf("ListPermissionLevels: %v", err)
+	}
+	if len(levels) != 0 {
+		t.Fatalf("level saved without its database: %+v", levels)
+	}
+}
+
+func TestDeletePermissionLevelRemovesRank(t *testing.T) {
+	s := newTestStore(t)
+	saveTestDatabase(t, s, "orders", "read", "write", "admin")
+	level := &PermissionLevel{Database: "orders", Name: "reporting", NativeRoles: []string{"reporting"}}
+	if err := s.SavePermissionLevel(level, []string{"read", "reporting", "write", "admin"}); err != nil {
+		t.Fatalf("SavePermissionLevel: %v", err)
+	}
+
+	if err := s.DeletePermissionLevel("orders", "reporting"); err != nil {
+		t.Fatalf("DeletePermissionLevel: %v", err)
+	}
+	db, err := s.GetDatabase("orders")
+	if err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	if want := []string{"read", "write", "admin"}; !slices.Equal(db.AvailablePermissions, want) {
+		t.Fatalf("AvailablePermissions = %q; want %q", db.AvailablePermissions, want)
+	}
+	if _, ok := db.Level("reporting"); ok {
+		t.Fatalf("level reporting was not deleted")
+	}
+
+	// Deleting it again changes nothing
+	if err := s.DeletePermissionLevel("orders", "reporting"); !IsNotFound(err) {
+		t.Fatalf("second DeletePermissionLevel = %v; want not found", err)
+	}
+	if db, _ = s.GetDatabase("orders"); len(db.AvailablePermissions) != 3 {
+		t.Fatalf("AvailablePermissions = %q after a failed delete", db.AvailablePermissions)
+	}
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// permissionColumns lists the columns read by scanPermission, in order.
+const permissionColumns = `database_name, level, objects, deny, limits, elevate_to, valid_from, valid_until, conditions`
+
+// scanPermission reads a row selected with permissionColumns.
+func scanPermission(row interface{ Scan(...any) error }) (Permission, error) {
+	var perm Permission
+	var objectsJSON, limitsJSON, conditionsJSON string
+	if err := row.Scan(&perm.Database, &perm.Level, &objectsJSON, &perm.Deny, &limitsJSON, &perm.ElevateTo, &perm.ValidFrom, &perm.ValidUntil, &conditionsJSON); err != nil {
+		return Permission{}, err
+	}
+	if err := json.Unmarshal([]byte(objectsJSON), &perm.Objects); err != nil {
+		return Permission{}, fmt.Errorf("parse object grants: %w", err)
+	}
+	if len(perm.Objects) == 0 {
+		perm.Objects = nil
+	}
+	limits, err := decodeLimits(limitsJSON)
+	if err != nil {
+		return Permission{}, err
+	}
+	perm.Limits = limits
+	if conditionsJSON != "" {
+		perm.Conditions = &Conditions{}
+		if err := json.Unmarshal([]byte(conditionsJSON), perm.Conditions); err != nil {
+			return Permission{}, fmt.Errorf("parse conditions: %w", err)
+		}
+	}
+	return perm, nil
+}
+
+// encodeConditions serializes grant conditions for storage; no conditions are stored as an empty string.
+func encodeConditions(conditions *Conditions) (string, error) {
+	if conditions == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(conditions)
+	if err != nil {
+		return "", fmt.Errorf("serialize conditions: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeObjects serializes object grants for storage.
+func encodeObjects(objects []ObjectGrant) (string, error) {
+	if len(objects) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal(objects)
+	if err != nil {
+		return "", fmt.Errorf("serialize object grants: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeLimits serializes resource limits for storage; no limits are stored as an empty string.
+func encodeLimits(limits *ResourceLimits) (string, error) {
+	if limits == nil || *limits == (ResourceLimits{}) {
+		return "", nil
+	}
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return "", fmt.Errorf("serialize limits: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeLimits parses limits written by encodeLimits.
+func decodeLimits(data string) (*ResourceLimits, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var limits ResourceLimits
+	if err := json.Unmarshal([]byte(data), &limits); err != nil {
+		return nil, fmt.Errorf("parse limits: %w", err)
+	}
+	return &limits, nil
+}
+
+// encodeStringMap serializes user attributes or database tags for storage.
+func encodeStringMap(m map[string]string) (string, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeStringMap parses a map written by encodeStringMap; an empty map decodes to nil.
+func decodeStringMap(data string) (map[string]string, error) {
+	var m map[string]string
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return nil, err
+		}
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// ExpiredGrant describes a grant or role assignment removed after its validity window ended.
+type ExpiredGrant struct {
+	Kind       string // "custom_permission", "role_permission" or "role_assignment"
+	Username   string // set for custom permissions and role assignments
+	Role       string // set for role permissions and role assignments
+	Database   string // set for permissions
+	Level      string // set for permissions
+	ValidUntil time.Time
+}
+
+// PurgeExpiredGrants deletes custom permissions, role permissions and role assignments
+// whose valid_until has passed, and returns what was removed.
+func (s *Store) PurgeExpiredGrants() ([]ExpiredGrant, error) {
+	return s.purgeExpiredGrants(time.Now().UTC())
+}
+
+// purgeExpiredGrants removes what expired at or before now. A grant expires at its
+// valid_until, so one whose valid_until equals now is removed.
+func (s *Store) purgeExpiredGrants(now time.Time) ([]ExpiredGrant, error) {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	queries := []struct {
+		kind   string
+		query  string
+		delete string
+	}{
+		{"custom_permission",
+			`SELECT username, '', database_name, level, valid_until FROM user_custom_permissions WHERE valid_until <= ?`,
+			`DELETE FROM user_custom_permissions WHERE valid_until <= ?`},
+		{"role_permission",
+			`SELECT '', role_name, database_name, level, valid_until FROM role_permissions WHERE valid_until <= ?`,
+			`DELETE FROM role_permissions WHERE valid_until <= ?`},
+		{"role_assignment",
+			`SELECT username, role_name, '', '', valid_until FROM user_roles WHERE valid_until <= ?`,
+			`DELETE FROM user_roles WHERE valid_until <= ?`},
+	}
+
+	var expired []ExpiredGrant
+	for _, q := range queries {
+		var found []ExpiredGrant
+		if found, err = scanExpiredGrants(tx.Query(q.query, now)); err != nil {
+			return nil, fmt.Errorf("list expired grants: %w", err)
+		}
+		for i := range found {
+			found[i].Kind = q.kind
+		}
+		expired = append(expired, found...)
+
+		if _, err = tx.Exec(q.delete, now); err != nil {
+			return nil, fmt.Errorf("purge expired grants: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, grant := range expired {
+		if grant.Kind == "role_permission" {
+			s.notify(Change{Kind: RoleChanged, Name: grant.Role})
+		} else {
+			s.notify(Change{Kind: UserChanged, Name: grant.Username})
+		}
+	}
+	return expired, nil
+}
+
+// scanExpiredGrants reads the rows of one PurgeExpiredGrants query.
+func scanExpiredGrants(rows *sql.Rows, err error) ([]ExpiredGrant, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []ExpiredGrant
+	for rows.Next() {
+		var g ExpiredGrant
+		if err := rows.Scan(&g.Username, &g.Role, &g.Database, &g.Level, &g.ValidUntil); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
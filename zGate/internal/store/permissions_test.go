@@ -0,0 +1,153 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidityActiveAt(t *testing.T) {
+	from := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 10, 31, 17, 0, 0, 0, time.UTC)
+	window := Validity{ValidFrom: &from, ValidUntil: &until}
+
+	tests := []struct {
+		name     string
+		validity Validity
+		at       time.Time
+		active   bool
+	}{
+		{"no window", Validity{}, from, true},
+		{"before valid_from", window, from.Add(-time.Nanosecond), false},
+		{"exactly valid_from", window, from, true},
+		{"inside", window, from.Add(time.Hour), true},
+		{"just before valid_until", window, until.Add(-time.Nanosecond), true},
+		{"exactly valid_until", window, until, false},
+		{"after valid_until", window, until.Add(time.Hour), false},
+		{"only valid_from", Validity{ValidFrom: &from}, until.AddDate(1, 0, 0), true},
+		{"only valid_until", Validity{ValidUntil: &until}, from.AddDate(-1, 0, 0), true},
+		{"other time zone", window, until.In(time.FixedZone("UTC+2", 2*60*60)), false},
+	}
+	for _, tt := range tests {
+		if got := tt.validity.ActiveAt(tt.at); got != tt.active {
+			t.Errorf("%s: ActiveAt(%v) = %v; want %v", tt.name, tt.at, got, tt.active)
+		}
+	}
+}
+
+func TestValidityIntersect(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	got := Validity{ValidFrom: day(1), ValidUntil: day(20)}.Intersect(Validity{ValidFrom: day(5), ValidUntil: day(30)})
+	if !got.ValidFrom.Equal(*day(5)) || !got.ValidUntil.Equal(*day(20)) {
+		t.Fatalf("Intersect = %v to %v; want Oct 5 to Oct 20", got.ValidFrom, got.ValidUntil)
+	}
+	got = Validity{}.Intersect(Validity{ValidUntil: day(20)})
+	if got.ValidFrom != nil || !got.ValidUntil.Equal(*day(20)) {
+		t.Fatalf("Intersect with an open window = %v to %v; want until Oct 20", got.ValidFrom, got.ValidUntil)
+	}
+}
+
+func TestValidityRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	saveTestDatabase(t, s, "orders", "read", "write", "admin")
+	saveTestRole(t, s, Role{Name: "dev"})
+
+	from := time.Date(2026, 10, 1, 11, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	until := time.Date(2026, 10, 31, 17, 30, 0, 0, time.UTC)
+	user := &User{
+		Username:          "alice",
+		Roles:             []string{"dev"},
+		RoleValidity:      map[string]Validity{"dev": {ValidUntil: &until}},
+		CustomPermissions: []Permission{{Database: "orders", Level: "read", Validity: Validity{ValidFrom: &from, ValidUntil: &until}}},
+	}
+	if err := s.CreateUserWithPassword(user, "secret"); err != nil {
+		t.Fatalf("CreateUserWithPassword: %v", err)
+	}
+
+	got := getTestUser(t, s, "alice")
+	if window := got.RoleValidity["dev"]; window.ValidFrom != nil || window.ValidUntil == nil || !window.ValidUntil.Equal(until) {
+		t.Fatalf("role validity = %+v; want until %v", window, until)
+	}
+	if len(got.CustomPermissions) != 1 {
+		t.Fatalf("%d custom permissions; want 1", len(got.CustomPermissions))
+	}
+	window := got.CustomPermissions[0].Validity
+	if window.ValidFrom == nil || !window.ValidFrom.Equal(from) || window.ValidUntil == nil || !window.ValidUntil.Equal(until) {
+		t.Fatalf("custom permission validity = %v to %v; want %v to %v", window.ValidFrom, window.ValidUntil, from, until)
+	}
+}
+
+func TestPurgeExpiredGrants(t *testing.T) {
+	s := newTestStore(t)
+	for _, name := range []string{"orders", "billing", "reports"} {
+		saveTestDatabase(t, s, name, "read", "write", "admin")
+	}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	past, later := now.Add(-time.Hour), now.Add(time.Second)
+	saveTestRole(t, s, Role{Name: "dev", Permissions: []Permission{
+		{Database: "orders", Level: "read"},
+		{Database: "billing", Level: "write", Validity: Validity{ValidUntil: &now}},
+		{Database: "reports", Level: "admin", Validity: Validity{ValidUntil: &later}},
+	}})
+	saveTestRole(t, s, Role{Name: "oncall"})
+	saveTestRole(t, s, Role{Name: "intern"})
+	user := &User{
+		Username:     "alice",
+		Roles:        []string{"dev", "intern", "oncall"},
+		RoleValidity: map[string]Validity{"oncall": {ValidUntil: &past}, "intern": {ValidFrom: &past, ValidUntil: &later}},
+		CustomPermissions: []Permission{
+			{Database: "orders", Level: "admin", Validity: Validity{ValidFrom: &past, ValidUntil: &now}},
+		},
+	}
+	if err := s.CreateUserWithPassword(user, "secret"); err != nil {
+		t.Fatalf("CreateUserWithPassword: %v", err)
+	}
+
+	expired, err := s.purgeExpiredGrants(now)
+	if err != nil {
+		t.Fatalf("purgeExpiredGrants: %v", err)
+	}
+	var got []string
+	for _, g := range expired {
+		got = append(got, fmt.Sprintf("%s %s/%s %s/%s until %s", g.Kind, g.Username, g.Role, g.Database, g.Level, g.ValidUntil.UTC().Format(time.TimeOnly)))
+	}
+	want := []string{
+		"custom_permission alice/ orders/admin until 12:00:00",
+		"role_assignment alice/oncall / until 11:00:00",
+		"role_permission /dev billing/write until 12:00:00",
+	}
+	if !slices.Equal(sorted(got), want) {
+		t.Fatalf("purged %q; want %q", got, want)
+	}
+
+	// Grants valid until a later instant, or without an end, are kept
+	alice := getTestUser(t, s, "alice")
+	if !slices.Equal(alice.Roles, []string{"dev", "intern"}) || len(alice.CustomPermissions) != 0 {
+		t.Fatalf("alice keeps roles %q and %d custom permissions; want dev, intern and none", alice.Roles, len(alice.CustomPermissions))
+	}
+	dev, err := s.GetRole("dev")
+	if err != nil {
+		t.Fatalf("GetRole: %v", err)
+	}
+	var databases []string
+	for _, perm := range dev.Permissions {
+		databases = append(databases, perm.Database)
+	}
+	if !slices.Equal(sorted(databases), []string{"orders", "reports"}) {
+		t.Fatalf("dev keeps permissions on %q; want orders and reports", databases)
+	}
+
+	// A second purge finds nothing more until the later grants expire
+	if expired, err = s.purgeExpiredGrants(now); err != nil || len(expired) != 0 {
+		t.Fatalf("second purge = %+v, %v; want nothing", expired, err)
+	}
+	if expired, err = s.purgeExpiredGrants(later); err != nil || len(expired) != 2 {
+		t.Fatalf("purge at %v = %+v, %v; want the reports permission and the intern assignment", later, expired, err)
+	}
+}
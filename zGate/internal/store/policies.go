@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// policyColumns lists the columns read by scanPolicy, in order.
+const policyColumns = `name, version, description, expression, enabled, deleted, created_by, created_at`
+
+// latestPolicies selects the newest version of every policy.
+const latestPolicies = `
+	SELECT ` + policyColumns + ` FROM policies p
+	WHERE version = (SELECT MAX(version) FROM policies WHERE name = p.name)`
+
+// SavePolicy stores p as a new version of the policy named p.Name and sets its
+// Version and CreatedAt.
+func (s *Store) SavePolicy(p *Policy) error {
+	if p == nil {
+		return fmt.Errorf("policy is nil")
+	}
+	p.CreatedAt = time.Now().UTC()
+	p.Deleted = false
+	if err := s.insertPolicyVersion(p); err != nil {
+		return fmt.Errorf("save policy: %w", err)
+	}
+	s.notify(Change{Kind: PolicyChanged, Name: p.Name})
+	return nil
+}
+
+// DeletePolicy records a deletion as the policy's newest version, so its history is kept.
+func (s *Store) DeletePolicy(name, deletedBy string) error {
+	if _, err := s.GetPolicy(name); err != nil {
+		return fmt.Errorf("delete policy: %w", err)
+	}
+	tombstone := &Policy{Name: name, Deleted: true, CreatedBy: deletedBy, CreatedAt: time.Now().UTC()}
+	if err := s.insertPolicyVersion(tombstone); err != nil {
+		return fmt.Errorf("delete policy: %w", err)
+	}
+	s.notify(Change{Kind: PolicyChanged, Name: name})
+	return nil
+}
+
+// insertPolicyVersion writes p with the next version number for its name.
+func (s *Store) insertPolicyVersion(p *Policy) error {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var latest int
+	if err = tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM policies WHERE name = ?`, p.Name).Scan(&latest); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO policies (name, version, description, expression, enabled, deleted, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.Name, latest+1, p.Description, p.Expression, p.Enabled, p.Deleted, p.CreatedBy, p.CreatedAt); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	p.Version = latest + 1
+	return nil
+}
+
+// GetPolicy returns the version of a policy in force; a deleted policy is not found.
+func (s *Store) GetPolicy(name string) (*Policy, error) {
+	p, err := scanPolicy(s.db.QueryRow(latestPolicies+` AND name = ?`, name))
+	if err == nil && p.Deleted {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetch policy: %w", err)
+	}
+	return p, nil
+}
+
+// GetPolicyVersion returns one version of a policy.
+func (s *Store) GetPolicyVersion(name string, version int) (*Policy, error) {
+	p, err := scanPolicy(s.db.QueryRow(`SELECT `+policyColumns+` FROM policies WHERE name = ? AND version = ?`, name, version))
+	if err != nil {
+		return nil, fmt.Errorf("fetch policy version: %w", err)
+	}
+	return p, nil
+}
+
+// ListPolicies returns the version in force of every policy that is not deleted, by name.
+func (s *Store) ListPolicies() ([]Policy, error) {
+	policies, err := s.queryPolicies(latestPolicies + ` AND deleted = 0 ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list policies: %w", err)
+	}
+	return policies, nil
+}
+
+// ListPolicyVersions returns every version of a policy, newest first, including deletions.
+func (s *Store) ListPolicyVersions(name string) ([]Policy, error) {
+	policies, err := s.queryPolicies(`SELECT `+policyColumns+` FROM policies WHERE name = ? ORDER BY version DESC`, name)
+	if err != nil {
+		return nil, fmt.Errorf("list policy versions: %w", err)
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("list policy versions: %w", sql.ErrNoRows)
+	}
+	return policies, nil
+}
+
+func (s *Store) queryPolicies(query string, args ...any) ([]Policy, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []Policy{}
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *p)
+	}
+	return policies, rows.Err()
+}
+
+// scanPolicy reads a row selected with policyColumns.
+func scanPolicy(row interface{ Scan(...any) error }) (*Policy, error) {
+	var p Policy
+	if err := row.Scan(&p.Name, &p.Version, &p.Description, &p.Expression, &p.Enabled, &p.Deleted, &p.CreatedBy, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
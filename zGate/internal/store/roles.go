@@ -1,167 +1,330 @@
-package store
-
-import (
-	"context"
-	"fmt"
-	"strings"
-)
-
-// SaveRole inserts or updates a role and its permissions.
-func (s *Store) SaveRole(role *Role) error {
-	if role == nil {
-		return fmt.Errorf("role is nil")
-	}
-
-	tx, err := s.db.BeginTx(context.Background(), nil)
-	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
-	if _, err = tx.Exec(`
-		INSERT INTO roles (name, description) VALUES (?, ?)
-		ON CONFLICT(name) DO UPDATE SET description=excluded.description
-	`, role.Name, role.Description); err != nil {
-		return err
-	}
-
-	if _, err = tx.Exec(`DELETE FROM role_permissions WHERE role_name = ?`, role.Name); err != nil {
-		return err
-	}
-
-	for _, perm := range role.Permissions {
-		if _, err = tx.Exec(`INSERT INTO role_permissions (role_name, database_name, level) VALUES (?, ?, ?)`, role.Name, perm.Database, perm.Level); err != nil {
-			return err
-		}
-	}
-
-	err = tx.Commit()
-	return err
-}
-
-// GetRole fetches a role by name.
-func (s *Store) GetRole(name string) (*Role, error) {
-	row := s.db.QueryRow(`SELECT name, description FROM roles WHERE name = ?`, name)
-	var role Role
-	if err := row.Scan(&role.Name, &role.Description); err != nil {
-		return nil, fmt.Errorf("fetch role: %w", err)
-	}
-
-	perms, err := s.getPermissionsForRole(name)
-	if err != nil {
-		return nil, err
-	}
-	role.Permissions = perms
-
-	return &role, nil
-}
-
-// ListRoles returns all roles with permissions.
-func (s *Store) ListRoles() ([]Role, error) {
-	rows, err := s.db.Query(`SELECT name, description FROM roles ORDER BY name`)
-	if err != nil {
-		return nil, fmt.Errorf("list roles: %w", err)
-	}
-	defer rows.Close()
-
-	var roles []Role
-	for rows.Next() {
-		var role Role
-		if err := rows.Scan(&role.Name, &role.Description); err != nil {
-			return nil, err
-		}
-
-		perms, err := s.getPermissionsForRole(role.Name)
-		if err != nil {
-			return nil, err
-		}
-		role.Permissions = perms
-
-		roles = append(roles, role)
-	}
-
-	return roles, nil
-}
-
-func (s *Store) getPermissionsForRole(roleName string) ([]Permission, error) {
-	rows, err := s.db.Query(`SELECT database_name, level FROM role_permissions WHERE role_name = ?`, roleName)
-	if err != nil {
-		return nil, fmt.Errorf("role permissions: %w", err)
-	}
-	defer rows.Close()
-
-	var perms []Permission
-	for rows.Next() {
-		var perm Permission
-		if err := rows.Scan(&perm.Database, &perm.Level); err != nil {
-			return nil, err
-		}
-		perms = append(perms, perm)
-	}
-
-	return perms, nil
-}
-
-// GetPermissionsForRoles aggregates permissions for the supplied roles.
-func (s *Store) GetPermissionsForRoles(roleNames []string) ([]Permission, error) {
-	if len(roleNames) == 0 {
-		return nil, nil
-	}
-
-	placeholders := strings.Repeat("?,", len(roleNames))
-	placeholders = strings.TrimSuffix(placeholders, ",")
-
-	query := fmt.Sprintf(`SELECT database_name, level FROM role_permissions WHERE role_name IN (%s)`, placeholders)
-	args := make([]any, len(roleNames))
-	for i, name := range roleNames {
-		args[i] = name
-	}
-
-	rows, err := s.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("aggregate perms: %w", err)
-	}
-	defer rows.Close()
-
-	var perms []Permission
-	for rows.Next() {
-		var perm Permission
-		if err := rows.Scan(&perm.Database, &perm.Level); err != nil {
-			return nil, err
-		}
-		perms = append(perms, perm)
-	}
-
-	return perms, nil
-}
-
-// GetUsersForRole lists all usernames that are directly assigned the specified role.
-// It does not include users who might receive equivalent permissions through other roles.
-func (s *Store) GetUsersForRole(roleName string) ([]string, error) {
-	if roleName == "" {
-		return nil, fmt.Errorf("role name is empty")
-	}
-
-	rows, err := s.db.Query(`SELECT username FROM user_roles WHERE role_name = ? ORDER BY username`, roleName)
-	if err != nil {
-		return nil, fmt.Errorf("list users for role: %w", err)
-	}
-	defer rows.Close()
-
-	var users []string
-	for rows.Next() {
-		var username string
-		if err := rows.Scan(&username); err != nil {
-			return nil, fmt.Errorf("scan username: %w", err)
-		}
-		users = append(users, username)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate usernames: %w", err)
-	}
-	return users, nil
-}
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrRoleCycle is returned when saving a role would make it its own ancestor.
+var ErrRoleCycle = errors.New("role inheritance cycle")
+
+// ancestorsQuery selects every role a role inherits from, directly or through other parents.
+// UNION drops rows already seen, so the recursion also ends on a cycle.
+const ancestorsQuery = `
+	WITH RECURSIVE ancestors(name) AS (
+		SELECT parent_name FROM role_parents WHERE role_name = ?
+		UNION
+		SELECT rp.parent_name FROM role_parents rp JOIN ancestors a ON rp.role_name = a.name
+	)
+	SELECT name FROM ancestors`
+
+// roleHoldersQuery selects every user holding a role: assigned directly or through a
+// group (including groups nested in it), either the role itself or a role inheriting from it.
+const roleHoldersQuery = `
+	WITH RECURSIVE holders(name) AS (
+		SELECT ?
+		UNION
+		SELECT rp.role_name FROM role_parents rp JOIN holders h ON rp.parent_name = h.name
+	),
+	holding_groups(name) AS (
+		SELECT group_name FROM group_roles WHERE role_name IN (SELECT name FROM holders)
+		UNION
+		SELECT gp.group_name FROM group_parents gp JOIN holding_groups g ON gp.parent_name = g.name
+	)
+	SELECT username FROM user_roles WHERE role_name IN (SELECT name FROM holders)
+	UNION
+	SELECT username FROM group_members WHERE group_name IN (SELECT name FROM holding_groups)
+	ORDER BY username`
+
+// SaveRole inserts or updates a role and its permissions.
+// It fails with ErrSoDViolation, saving nothing, when a holder would newly break a separation-of-duties rule.
+func (s *Store) SaveRole(role *Role) error {
+	if role == nil {
+		return fmt.Errorf("role is nil")
+	}
+
+	for _, parent := range role.Parents {
+		if parent == role.Name {
+			return fmt.Errorf("%w: %s cannot inherit from itself", ErrRoleCycle, role.Name)
+		}
+		ancestors, err := s.RoleAncestors(parent)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(ancestors, role.Name) {
+			return fmt.Errorf("%w: %s already inherits from %s", ErrRoleCycle, parent, role.Name)
+		}
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Saving a role never changes who holds it, only what they hold
+	var holders []string
+	if holders, err = queryNamesIn(tx, roleHoldersQuery, role.Name); err != nil {
+		return fmt.Errorf("role holders: %w", err)
+	}
+	var baseline map[string][]string
+	if baseline, err = sodBaseline(tx, holders); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO roles (name, description) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET description=excluded.description
+	`, role.Name, role.Description); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM role_parents WHERE role_name = ?`, role.Name); err != nil {
+		return err
+	}
+	for _, parent := range role.Parents {
+		if _, err = tx.Exec(`INSERT INTO role_parents (role_name, parent_name) VALUES (?, ?)`, role.Name, parent); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(`DELETE FROM role_permissions WHERE role_name = ?`, role.Name); err != nil {
+		return err
+	}
+
+	for _, perm := range role.Permissions {
+		var objects string
+		if objects, err = encodeObjects(perm.Objects); err != nil {
+			return err
+		}
+		var limits string
+		if limits, err = encodeLimits(perm.Limits); err != nil {
+			return err
+		}
+		var conditions string
+		if conditions, err = encodeConditions(perm.Conditions); err != nil {
+			return err
+		}
+		validity := perm.Validity.utc()
+		if _, err = tx.Exec(`INSERT INTO role_permissions (role_name, database_name, level, objects, deny, limits, elevate_to, valid_from, valid_until, conditions) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			role.Name, perm.Database, perm.Level, objects, perm.Deny, limits, perm.ElevateTo, validity.ValidFrom, validity.ValidUntil, conditions); err != nil {
+			return err
+		}
+	}
+
+	if err = checkSoD(tx, holders, baseline); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	s.notify(Change{Kind: RoleChanged, Name: role.Name})
+	return nil
+}
+
+// GetRole fetches a role by name.
+func (s *Store) GetRole(name string) (*Role, error) {
+	row := s.db.QueryRow(`SELECT name, description FROM roles WHERE name = ?`, name)
+	var role Role
+	if err := row.Scan(&role.Name, &role.Description); err != nil {
+		return nil, fmt.Errorf("fetch role: %w", err)
+	}
+
+	parents, err := s.getRoleParents(name)
+	if err != nil {
+		return nil, err
+	}
+	role.Parents = parents
+
+	perms, err := s.getPermissionsForRole(name)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = perms
+
+	return &role, nil
+}
+
+// ListRoles returns all roles with permissions.
+func (s *Store) ListRoles() ([]Role, error) {
+	rows, err := s.db.Query(`SELECT name, description FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.Name, &role.Description); err != nil {
+			return nil, err
+		}
+
+		role.Parents, err = s.getRoleParents(role.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		perms, err := s.getPermissionsForRole(role.Name)
+		if err != nil {
+			return nil, err
+		}
+		role.Permissions = perms
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// DeleteRole removes a role; its permissions, user assignments and links to parent and child roles cascade.
+func (s *Store) DeleteRole(name string) error {
+	result, err := s.db.Exec(`DELETE FROM roles WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete role: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("delete role: %w", sql.ErrNoRows)
+	}
+	s.notify(Change{Kind: RoleChanged, Name: name})
+	return nil
+}
+
+func (s *Store) getPermissionsForRole(roleName string) ([]Permission, error) {
+	rows, err := s.db.Query(`SELECT `+permissionColumns+` FROM role_permissions WHERE role_name = ?`, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	var perms []Permission
+	for rows.Next() {
+		perm, err := scanPermission(rows)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, perm)
+	}
+
+	return perms, nil
+}
+
+// RoleAncestors returns every role name inherits from, directly or through other parents.
+func (s *Store) RoleAncestors(name string) ([]string, error) {
+	ancestors, err := s.queryNames(ancestorsQuery, name)
+	if err != nil {
+		return nil, fmt.Errorf("role ancestors: %w", err)
+	}
+	return ancestors, nil
+}
+
+// ExpandRoles returns roleNames followed by every role they inherit from, each once.
+func (s *Store) ExpandRoles(roleNames []string) ([]string, error) {
+	expanded := slices.Clone(roleNames)
+	for _, name := range roleNames {
+		ancestors, err := s.RoleAncestors(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ancestor := range ancestors {
+			if !slices.Contains(expanded, ancestor) {
+				expanded = append(expanded, ancestor)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+func (s *Store) getRoleParents(name string) ([]string, error) {
+	parents, err := s.queryNames(`SELECT parent_name FROM role_parents WHERE role_name = ? ORDER BY parent_name`, name)
+	if err != nil {
+		return nil, fmt.Errorf("role parents: %w", err)
+	}
+	return parents, nil
+}
+
+// queryNames runs a query selecting one text column.
+func (s *Store) queryNames(query string, args ...any) ([]string, error) {
+	return queryNamesIn(s.db, query, args...)
+}
+
+// queryNamesIn runs a query selecting one text column through q.
+func queryNamesIn(q querier, query string, args ...any) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// GetPermissionsForRoles aggregates permissions for the supplied roles and every role they inherit from.
+func (s *Store) GetPermissionsForRoles(roleNames []string) ([]Permission, error) {
+	if len(roleNames) == 0 {
+		return nil, nil
+	}
+
+	roleNames, err := s.ExpandRoles(roleNames)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate perms: %w", err)
+	}
+
+	placeholders := strings.Repeat("?,", len(roleNames))
+	placeholders = strings.TrimSuffix(placeholders, ",")
+
+	query := fmt.Sprintf(`SELECT %s FROM role_permissions WHERE role_name IN (%s)`, permissionColumns, placeholders)
+	args := make([]any, len(roleNames))
+	for i, name := range roleNames {
+		args[i] = name
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate perms: %w", err)
+	}
+	defer rows.Close()
+
+	var perms []Permission
+	for rows.Next() {
+		perm, err := scanPermission(rows)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, perm)
+	}
+
+	return perms, nil
+}
+
+// GetUsersForRole lists all usernames that hold the specified role: assigned directly
+// or through a group (including groups nested in it), either the role itself or a role
+// that inherits from it.
+func (s *Store) GetUsersForRole(roleName string) ([]string, error) {
+	if roleName == "" {
+		return nil, fmt.Errorf("role name is empty")
+	}
+
+	users, err := s.queryNames(roleHoldersQuery, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("list users for role: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// sorted returns a sorted copy of names, never nil
+func sorted(names []string) []string {
+	names = slices.Clone(nonNil(names))
+	slices.Sort(names)
+	return names
+}
+
+func TestSaveRoleRejectsCycles(t *testing.T) {
+	s := newTestStore(t)
+	saveTestRole(t, s, Role{Name: "a"})
+	saveTestRole(t, s, Role{Name: "b", Parents: []string{"a"}})
+	saveTestRole(t, s, Role{Name: "c", Parents: []string{"b"}})
+
+	tests := []struct {
+		name string
+		role Role
+	}{
+		{"self", Role{Name: "a", Parents: []string{"a"}}},
+		{"two roles", Role{Name: "a", Parents: []string{"b"}}},
+		{"longer cycle", Role{Name: "a", Parents: []string{"c"}}},
+		{"cycle among several parents", Role{Name: "b", Parents: []string{"a", "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.SaveRole(&tt.role); !errors.Is(err, ErrRoleCycle) {
+				t.Fatalf("SaveRole(%s with parents %q) = %v; want ErrRoleCycle", tt.role.Name, tt.role.Parents, err)
+			}
+		})
+	}
+
+	// The refused saves left the hierarchy as it was
+	for name, want := range map[string][]string{"a": {}, "b": {"a"}, "c": {"b"}} {
+		role, err := s.GetRole(name)
+		if err != nil {
+			t.Fatalf("GetRole(%s): %v", name, err)
+		}
+		if !slices.Equal(sorted(role.Parents), want) {
+			t.Fatalf("%s has parents %q; want %q", name, role.Parents, want)
+		}
+	}
+
+	// A diamond is not a cycle
+	saveTestRole(t, s, Role{Name: "d", Parents: []string{"b", "c"}})
+}
+
+func TestRoleInheritance(t *testing.T) {
+	s := newTestStore(t)
+	saveTestDatabase(t, s, "orders", "read", "write", "admin")
+	saveTestDatabase(t, s, "billing", "read", "write", "admin")
+	saveTestRole(t, s, Role{Name: "reader", Permissions: []Permission{{Database: "orders", Level: "read"}}})
+	saveTestRole(t, s, Role{Name: "writer", Parents: []string{"reader"}, Permissions: []Permission{{Database: "orders", Level: "write"}}})
+	saveTestRole(t, s, Role{Name: "lead", Parents: []string{"writer"}, Permissions: []Permission{{Database: "billing", Level: "read"}}})
+	saveTestRole(t, s, Role{Name: "auditor", Parents: []string{"reader"}})
+
+	ancestors, err := s.RoleAncestors("lead")
+	if err != nil {
+		t.Fatalf("RoleAncestors: %v", err)
+	}
+	if want := []string{"reader", "writer"}; !slices.Equal(sorted(ancestors), want) {
+		t.Fatalf("RoleAncestors(lead) = %q; want %q", ancestors, want)
+	}
+
+	expanded, err := s.ExpandRoles([]string{"lead", "auditor"})
+	if err != nil {
+		t.Fatalf("ExpandRoles: %v", err)
+	}
+	if want := []string{"lead", "auditor"}; !slices.Equal(expanded[:2], want) || len(expanded) != 4 {
+		t.Fatalf("ExpandRoles(lead, auditor) = %q; want %q then writer and reader once each", expanded, want)
+	}
+	if want := []string{"auditor", "lead", "reader", "writer"}; !slices.Equal(sorted(expanded), want) {
+		t.Fatalf("ExpandRoles(lead, auditor) = %q; want %q", expanded, want)
+	}
+
+	perms, err := s.GetPermissionsForRoles([]string{"lead"})
+	if err != nil {
+		t.Fatalf("GetPermissionsForRoles: %v", err)
+	}
+	var got []string
+	for _, perm := range perms {
+		got = append(got, perm.Database+"/"+perm.Level)
+	}
+	if want := []string{"billing/read", "orders/read", "orders/write"}; !slices.Equal(sorted(got), want) {
+		t.Fatalf("permissions of lead = %q; want %q", got, want)
+	}
+
+	// Holders of reader include those who hold it through inheritance and groups
+	saveTestUser(t, s, "alice", "lead")
+	saveTestUser(t, s, "bob", "reader")
+	saveTestUser(t, s, "carol")
+	saveTestUser(t, s, "dave")
+	saveTestGroup(t, s, Group{Name: "audit", Roles: []string{"auditor"}})
+	saveTestGroup(t, s, Group{Name: "interns", Parents: []string{"audit"}, Members: []string{"carol"}})
+	holders, err := s.GetUsersForRole("reader")
+	if err != nil {
+		t.Fatalf("GetUsersForRole: %v", err)
+	}
+	if want := []string{"alice", "bob", "carol"}; !slices.Equal(sorted(holders), want) {
+		t.Fatalf("GetUsersForRole(reader) = %q; want %q", holders, want)
+	}
+
+	// Dropping a parent takes its permissions away from every descendant
+	saveTestRole(t, s, Role{Name: "writer", Permissions: []Permission{{Database: "orders", Level: "write"}}})
+	if perms, err = s.GetPermissionsForRoles([]string{"lead"}); err != nil {
+		t.Fatalf("GetPermissionsForRoles: %v", err)
+	}
+	if len(perms) != 2 {
+		t.Fatalf("lead has %d permissions after writer dropped reader; want 2", len(perms))
+	}
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrSoDViolation is returned when a change would give a user two entries of one separation-of-duties rule.
+var ErrSoDViolation = errors.New("separation of duties violation")
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so checks can run inside a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// heldRolesQuery selects every role a user holds: assigned directly or through a group
+// (including nested groups), plus every role those inherit from. Validity windows are
+// ignored, so an assignment that has not started yet still counts.
+const heldRolesQuery = `
+	WITH RECURSIVE member_of(name) AS (
+		SELECT group_name FROM group_members WHERE username = ?
+		UNION
+		SELECT gp.parent_name FROM group_parents gp JOIN member_of m ON gp.group_name = m.name
+	),
+	assigned(name) AS (
+		SELECT role_name FROM user_roles WHERE username = ?
+		UNION
+		SELECT role_name FROM group_roles WHERE group_name IN (SELECT name FROM member_of)
+	),
+	held(name) AS (
+		SELECT name FROM assigned
+		UNION
+		SELECT rp.parent_name FROM role_parents rp JOIN held h ON rp.role_name = h.name
+	)
+	SELECT name FROM held`
+
+// SaveSoDRule inserts or updates a separation-of-duties rule. Users already holding
+// conflicting entries are not changed; ListSoDViolations reports them.
+func (s *Store) SaveSoDRule(rule *SoDRule) error {
+	if rule == nil {
+		return fmt.Errorf("sod rule is nil")
+	}
+
+	roles, err := json.Marshal(nonNil(rule.Roles))
+	if err != nil {
+		return fmt.Errorf("serialize sod roles: %w", err)
+	}
+	perms := rule.Permissions
+	if perms == nil {
+		perms = []SoDPermission{}
+	}
+	permissions, err := json.Marshal(perms)
+	if err != nil {
+		return fmt.Errorf("serialize sod permissions: %w", err)
+	}
+
+	if _, err := s.db.Exec(`
+		INSERT INTO sod_rules (name, description, roles, permissions, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET description=excluded.description, roles=excluded.roles, permissions=excluded.permissions
+	`, rule.Name, rule.Description, string(roles), string(permissions), time.Now().UTC()); err != nil {
+		return fmt.Errorf("save sod rule: %w", err)
+	}
+	return nil
+}
+
+// GetSoDRule fetches a separation-of-duties rule by name.
+func (s *Store) GetSoDRule(name string) (*SoDRule, error) {
+	rule, err := scanSoDRule(s.db.QueryRow(`SELECT name, description, roles, permissions, created_at FROM sod_rules WHERE name = ?`, name))
+	if err != nil {
+		return nil, fmt.Errorf("fetch sod rule: %w", err)
+	}
+	return rule, nil
+}
+
+// ListSoDRules returns all separation-of-duties rules ordered by name.
+func (s *Store) ListSoDRules() ([]SoDRule, error) {
+	return listSoDRules(s.db)
+}
+
+// DeleteSoDRule removes a separation-of-duties rule.
+func (s *Store) DeleteSoDRule(name string) error {
+	result, err := s.db.Exec(`DELETE FROM sod_rules WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete sod rule: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("delete sod rule: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ListSoDViolations returns every user who currently holds two or more entries of a rule.
+func (s *Store) ListSoDViolations() ([]SoDViolation, error) {
+	usernames, err := s.queryNames(`SELECT username FROM users ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	return sodViolations(s.db, usernames)
+}
+
+// sodBaseline returns what usernames already hold of each rule they violate, as
+// seen through q, keyed by sodKey, so that saves only fail on violations they
+// introduce. Saves read it inside their transaction, before changing anything.
+func sodBaseline(q querier, usernames []string) (map[string][]string, error) {
+	violations, err := sodViolations(q, usernames)
+	if err != nil {
+		return nil, err
+	}
+	baseline := make(map[string][]string, len(violations))
+	for _, v := range violations {
+		baseline[sodKey(v)] = v.Holds
+	}
+	return baseline, nil
+}
+
+// checkSoD fails with ErrSoDViolation when any of usernames breaks a rule, as seen
+// through q, with an entry of it the baseline does not already record them holding.
+func checkSoD(q querier, usernames []string, baseline map[string][]string) error {
+	violations, err := sodViolations(q, usernames)
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		held := baseline[sodKey(v)]
+		if slices.ContainsFunc(v.Holds, func(h string) bool { return !slices.Contains(held, h) }) {
+			return fmt.Errorf("%w: %s would hold %s, which rule %q keeps apart", ErrSoDViolation, v.Username, strings.Join(v.Holds, " and "), v.Rule)
+		}
+	}
+	return nil
+}
+
+func sodKey(v SoDViolation) string {
+	return v.Rule + "\x00" + v.Username
+}
+
+// sodViolations evaluates every rule for each of usernames.
+func sodViolations(q querier, usernames []string) ([]SoDViolation, error) {
+	rules, err := listSoDRules(q)
+	if err != nil {
+		return nil, err
+	}
+	violations := []SoDViolation{}
+	if len(rules) == 0 {
+		return violations, nil
+	}
+
+	for _, username := range usernames {
+		roles, err := queryNamesIn(q, heldRolesQuery, username, username)
+		if err != nil {
+			return nil, fmt.Errorf("held roles: %w", err)
+		}
+		grants, err := heldGrants(q, username, roles)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			var holds []string
+			for _, role := range rule.Roles {
+				if slices.Contains(roles, role) {
+					holds = append(holds, "role:"+role)
+				}
+			}
+			for _, perm := range rule.Permissions {
+				if slices.ContainsFunc(grants, perm.Matches) {
+					holds = append(holds, "permission:"+perm.String())
+				}
+			}
+			if len(holds) > 1 {
+				violations = append(violations, SoDViolation{Rule: rule.Name, Username: username, Holds: holds})
+			}
+		}
+	}
+	return violations, nil
+}
+
+// heldGrants returns the database and level of every allow entry a user holds through
+// custom permissions or the given roles.
+func heldGrants(q querier, username string, roles []string) ([]SoDPermission, error) {
+	query := `SELECT database_name, level FROM user_custom_permissions WHERE username = ? AND deny = 0`
+	args := []any{username}
+	if len(roles) > 0 {
+		query += fmt.Sprintf(` UNION SELECT database_name, level FROM role_permissions WHERE deny = 0 AND role_name IN (%s)`,
+			strings.TrimSuffix(strings.Repeat("?,", len(roles)), ","))
+		for _, role := range roles {
+			args = append(args, role)
+		}
+	}
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("held grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []SoDPermission
+	for rows.Next() {
+		var grant SoDPermission
+		if err := rows.Scan(&grant.Database, &grant.Level); err != nil {
+			return nil, fmt.Errorf("scan held grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// listSoDRules reads every rule through q.
+func listSoDRules(q querier) ([]SoDRule, error) {
+	rows, err := q.Query(`SELECT name, description, roles, permissions, created_at FROM sod_rules ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list sod rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []SoDRule{}
+	for rows.Next() {
+		rule, err := scanSoDRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sod rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// scanSoDRule reads a rule row, decoding its roles and permissions.
+func scanSoDRule(row interface{ Scan(...any) error }) (*SoDRule, error) {
+	var rule SoDRule
+	var roles, permissions string
+	if err := row.Scan(&rule.Name, &rule.Description, &roles, &permissions, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(roles), &rule.Roles); err != nil {
+		return nil, fmt.Errorf("decode sod roles: %w", err)
+	}
+	if err := json.Unmarshal([]byte(permissions), &rule.Permissions); err != nil {
+		return nil, fmt.Errorf("decode sod permissions: %w", err)
+	}
+	return &rule, nil
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// newSoDStore returns a store with a payments database, roles that a rule keeps
+// apart and the rule itself
+func newSoDStore(t *testing.T) *Store {
+	t.Helper()
+	s := newTestStore(t)
+	saveTestDatabase(t, s, "payments", "read", "write", "admin")
+	saveTestRole(t, s, Role{Name: "requester", Permissions: []Permission{{Database: "payments", Level: "write"}}})
+	saveTestRole(t, s, Role{Name: "approver", Permissions: []Permission{{Database: "payments", Level: "read"}}})
+	saveTestRole(t, s, Role{Name: "clerk"})
+	if err := s.SaveSoDRule(&SoDRule{
+		Name:        "pay-apart",
+		Roles:       []string{"requester", "approver"},
+		Permissions: []SoDPermission{{Database: "payments", Level: "admin"}},
+	}); err != nil {
+		t.Fatalf("SaveSoDRule: %v", err)
+	}
+	return s
+}
+
+func TestSoDRefusesNewViolationOnUserSave(t *testing.T) {
+	s := newSoDStore(t)
+	saveTestUser(t, s, "alice", "requester")
+
+	alice := getTestUser(t, s, "alice")
+	alice.Roles = append(alice.Roles, "approver")
+	if err := s.SaveUser(alice); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveUser with both roles = %v; want ErrSoDViolation", err)
+	}
+
+	alice = getTestUser(t, s, "alice")
+	alice.CustomPermissions = []Permission{{Database: "payments", Level: "admin"}}
+	if err := s.SaveUser(alice); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveUser with a conflicting permission = %v; want ErrSoDViolation", err)
+	}
+
+	// Nothing of the refused saves was kept
+	alice = getTestUser(t, s, "alice")
+	if !slices.Equal(alice.Roles, []string{"requester"}) || len(alice.CustomPermissions) != 0 {
+		t.Fatalf("alice holds %q and %+v after refused saves", alice.Roles, alice.CustomPermissions)
+	}
+
+	// A custom permission at a level the rule does not name is fine
+	alice.CustomPermissions = []Permission{{Database: "payments", Level: "read"}}
+	if err := s.SaveUser(alice); err != nil {
+		t.Fatalf("SaveUser with an unrelated permission: %v", err)
+	}
+}
+
+func TestSoDRefusesNewViolationOnRoleSave(t *testing.T) {
+	s := newSoDStore(t)
+	saveTestUser(t, s, "bob", "requester", "clerk")
+
+	// bob would inherit approver through clerk
+	if err := s.SaveRole(&Role{Name: "clerk", Parents: []string{"approver"}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveRole inheriting approver = %v; want ErrSoDViolation", err)
+	}
+	// bob would hold admin on payments through clerk
+	if err := s.SaveRole(&Role{Name: "clerk", Permissions: []Permission{{Database: "payments", Level: "admin"}}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveRole granting admin = %v; want ErrSoDViolation", err)
+	}
+
+	clerk, err := s.GetRole("clerk")
+	if err != nil {
+		t.Fatalf("GetRole: %v", err)
+	}
+	if len(clerk.Parents) != 0 || len(clerk.Permissions) != 0 {
+		t.Fatalf("clerk is %+v after refused saves", clerk)
+	}
+
+	// Deny entries hold nothing
+	saveTestRole(t, s, Role{Name: "clerk", Permissions: []Permission{{Database: "payments", Deny: true}}})
+}
+
+func TestSoDRefusesNewViolationOnGroupSave(t *testing.T) {
+	s := newSoDStore(t)
+	saveTestUser(t, s, "alice", "requester")
+	saveTestUser(t, s, "carol", "requester")
+	saveTestUser(t, s, "erin")
+	saveTestGroup(t, s, Group{Name: "finance", Roles: []string{"approver"}, Members: []string{"erin"}})
+	saveTestGroup(t, s, Group{Name: "ops", Members: []string{"carol"}})
+
+	// alice would gain approver as a member
+	if err := s.SaveGroup(&Group{Name: "finance", Roles: []string{"approver"}, Members: []string{"erin", "alice"}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveGroup adding alice = %v; want ErrSoDViolation", err)
+	}
+	// carol would gain approver through ops nested in finance
+	if err := s.SaveGroup(&Group{Name: "ops", Parents: []string{"finance"}, Members: []string{"carol"}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveGroup nesting ops in finance = %v; want ErrSoDViolation", err)
+	}
+	// erin would gain requester through the group's roles
+	if err := s.SaveGroup(&Group{Name: "finance", Roles: []string{"approver", "requester"}, Members: []string{"erin"}}); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveGroup adding requester = %v; want ErrSoDViolation", err)
+	}
+
+	for name, want := range map[string][]string{"alice": {}, "carol": {"ops"}, "erin": {"finance"}} {
+		groups, err := s.GetGroupsForUser(name)
+		if err != nil {
+			t.Fatalf("GetGroupsForUser(%s): %v", name, err)
+		}
+		if !slices.Equal(nonNil(groups), want) {
+			t.Fatalf("%s is in %q after refused saves; want %q", name, groups, want)
+		}
+	}
+}
+
+func TestSoDKeepsExistingViolation(t *testing.T) {
+	s := newSoDStore(t)
+	if err := s.DeleteSoDRule("pay-apart"); err != nil {
+		t.Fatalf("DeleteSoDRule: %v", err)
+	}
+	saveTestUser(t, s, "dave", "requester", "approver")
+	saveTestUser(t, s, "alice", "requester")
+	if err := s.SaveSoDRule(&SoDRule{
+		Name:        "pay-apart",
+		Roles:       []string{"requester", "approver"},
+		Permissions: []SoDPermission{{Database: "payments", Level: "admin"}},
+	}); err != nil {
+		t.Fatalf("SaveSoDRule: %v", err)
+	}
+
+	// Saves that do not add to what dave holds of the rule go through
+	dave := getTestUser(t, s, "dave")
+	dave.Attributes = map[string]string{"team": "payments"}
+	if err := s.SaveUser(dave); err != nil {
+		t.Fatalf("unrelated SaveUser: %v", err)
+	}
+	saveTestRole(t, s, Role{Name: "requester", Description: "raises payments", Permissions: []Permission{{Database: "payments", Level: "write"}}})
+	saveTestGroup(t, s, Group{Name: "finance", Members: []string{"dave"}})
+
+	// Dropping one entry and adding another is a new violation
+	dave = getTestUser(t, s, "dave")
+	dave.Roles = []string{"requester"}
+	dave.CustomPermissions = []Permission{{Database: "payments", Level: "admin"}}
+	if err := s.SaveUser(dave); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveUser swapping approver for admin = %v; want ErrSoDViolation", err)
+	}
+
+	violations, err := s.ListSoDViolations()
+	if err != nil {
+		t.Fatalf("ListSoDViolations: %v", err)
+	}
+	want := []SoDViolation{{Rule: "pay-apart", Username: "dave", Holds: []string{"role:requester", "role:approver"}}}
+	if len(violations) != len(want) || violations[0].Rule != want[0].Rule || violations[0].Username != want[0].Username ||
+		!slices.Equal(violations[0].Holds, want[0].Holds) {
+		t.Fatalf("ListSoDViolations = %+v; want %+v", violations, want)
+	}
+}
+
+func TestListSoDViolations(t *testing.T) {
+	s := newSoDStore(t)
+	if violations, err := s.ListSoDViolations(); err != nil || len(violations) != 0 {
+		t.Fatalf("ListSoDViolations on a clean store = %+v, %v", violations, err)
+	}
+
+	// Write violations straight to the tables, as an older release might have left them
+	if _, err := s.db.Exec(`INSERT INTO users (username, password_hash) VALUES ('frank', 'x'), ('gina', 'x')`); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO user_roles (username, role_name) VALUES ('frank', 'requester'), ('frank', 'approver'), ('gina', 'clerk')`); err != nil {
+		t.Fatalf("insert user roles: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO role_parents (role_name, parent_name) VALUES ('clerk', 'requester')`); err != nil {
+		t.Fatalf("insert role parent: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO user_custom_permissions (username, database_name, level) VALUES ('gina', 'payments', 'admin')`); err != nil {
+		t.Fatalf("insert custom permission: %v", err)
+	}
+
+	violations, err := s.ListSoDViolations()
+	if err != nil {
+		t.Fatalf("ListSoDViolations: %v", err)
+	}
+	want := map[string][]string{
+		"frank": {"role:requester", "role:approver"},
+		"gina":  {"role:requester", "permission:payments/admin"},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("ListSoDViolations = %+v; want violations by %d users", violations, len(want))
+	}
+	for _, v := range violations {
+		if v.Rule != "pay-apart" || !slices.Equal(v.Holds, want[v.Username]) {
+			t.Fatalf("violation %+v; want %s holding %q", v, v.Username, want[v.Username])
+		}
+	}
+}
@@ -1,138 +1,392 @@
-package store
-
-import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
-
-	_ "github.com/mattn/go-sqlite3"
-)
-
-// Store owns the SQLite connection and exposes CRUD helpers for zGate metadata.
-type Store struct {
-	db            *sql.DB
-	encryptionKey []byte
-}
-
-// NewStore opens (or creates) the SQLite database at dbPath and prepares the schema.
-func NewStore(dbPath string, encryptionKey []byte) (*Store, error) {
-	if len(encryptionKey) != 32 {
-		return nil, errors.New("encryption key must be exactly 32 bytes for AES-256")
-	}
-
-	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
-		return nil, fmt.Errorf("failed creating data directory: %w", err)
-	}
-
-	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=5000&_foreign_keys=on", dbPath))
-	if err != nil {
-		return nil, fmt.Errorf("failed opening sqlite database: %w", err)
-	}
-
-	store := &Store{db: db, encryptionKey: encryptionKey}
-	if err := store.initSchema(); err != nil {
-		_ = db.Close()
-		return nil, err
-	}
-
-	return store, nil
-}
-
-// Close releases the database resources.
-func (s *Store) Close() error {
-	if s == nil || s.db == nil {
-		return nil
-	}
-	return s.db.Close()
-}
-
-func (s *Store) initSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS databases (
-		name TEXT PRIMARY KEY,
-		type TEXT NOT NULL,
-		description TEXT,
-		backend_addr TEXT NOT NULL,
-		admin_username TEXT NOT NULL,
-		admin_password BLOB NOT NULL,
-		available_permissions TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS roles (
-		name TEXT PRIMARY KEY,
-		description TEXT
-	);
-
-	CREATE TABLE IF NOT EXISTS role_permissions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		role_name TEXT NOT NULL,
-		database_name TEXT NOT NULL,
-		level TEXT NOT NULL,
-		UNIQUE(role_name, database_name),
-		FOREIGN KEY(role_name) REFERENCES roles(name) ON DELETE CASCADE,
-		FOREIGN KEY(database_name) REFERENCES databases(name) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS users (
-		username TEXT PRIMARY KEY,
-		password_hash BLOB NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS admins (
-		username TEXT PRIMARY KEY,
-		password_hash BLOB NOT NULL,
-		name TEXT NOT NULL,
-		email TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_login_at TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS user_roles (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL,
-		role_name TEXT NOT NULL,
-		UNIQUE(username, role_name),
-		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE,
-		FOREIGN KEY(role_name) REFERENCES roles(name) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS user_custom_permissions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL,
-		database_name TEXT NOT NULL,
-		level TEXT NOT NULL,
-		UNIQUE(username, database_name, level),
-		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS refresh_tokens (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		token_hash TEXT NOT NULL UNIQUE,
-		username TEXT NOT NULL,
-		expires_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		revoked BOOLEAN DEFAULT 0,
-		revoked_at TIMESTAMP,
-		user_agent TEXT,
-		ip_address TEXT,
-		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_username ON refresh_tokens(username);
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
-	`
-
-	if _, err := s.db.Exec(schema); err != nil {
-		return fmt.Errorf("failed applying schema: %w", err)
-	}
-
-	return nil
-}
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Store owns the SQLite connection and exposes CRUD helpers for zGate metadata.
+type Store struct {
+	db            *sql.DB
+	encryptionKey []byte
+
+	subscribersMu sync.RWMutex
+	subscribers   []func(Change)
+}
+
+// NewStore opens (or creates) the SQLite database at dbPath and prepares the schema.
+func NewStore(dbPath string, encryptionKey []byte) (*Store, error) {
+	if len(encryptionKey) != 32 {
+		return nil, errors.New("encryption key must be exactly 32 bytes for AES-256")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, fmt.Errorf("failed creating data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=5000&_foreign_keys=on", dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed opening sqlite database: %w", err)
+	}
+
+	store := &Store{db: db, encryptionKey: encryptionKey}
+	if err := store.initSchema(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// IsNotFound reports whether err was caused by a missing row.
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+// Close releases the database resources.
+func (s *Store) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *Store) initSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS databases (
+		name TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		description TEXT,
+		backend_addr TEXT NOT NULL,
+		admin_username TEXT NOT NULL,
+		admin_password BLOB NOT NULL,
+		available_permissions TEXT NOT NULL,
+		target_database TEXT NOT NULL DEFAULT '',
+		target_schema TEXT NOT NULL DEFAULT '',
+		username_template TEXT NOT NULL DEFAULT '',
+		max_session_minutes INTEGER NOT NULL DEFAULT 0,
+		tags TEXT NOT NULL DEFAULT '{}',
+		owners TEXT NOT NULL DEFAULT '{}',
+		identity_trigger INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS permission_levels (
+		database_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT,
+		statements TEXT NOT NULL DEFAULT '[]',
+		native_roles TEXT NOT NULL DEFAULT '[]',
+		objects TEXT NOT NULL DEFAULT '[]',
+		limits TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(database_name, name),
+		FOREIGN KEY(database_name) REFERENCES databases(name) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS roles (
+		name TEXT PRIMARY KEY,
+		description TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS role_parents (
+		role_name TEXT NOT NULL,
+		parent_name TEXT NOT NULL,
+		PRIMARY KEY(role_name, parent_name),
+		FOREIGN KEY(role_name) REFERENCES roles(name) ON DELETE CASCADE,
+		FOREIGN KEY(parent_name) REFERENCES roles(name) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		role_name TEXT NOT NULL,
+		database_name TEXT NOT NULL,
+		level TEXT NOT NULL,
+		objects TEXT NOT NULL DEFAULT '[]',
+		deny INTEGER NOT NULL DEFAULT 0,
+		limits TEXT NOT NULL DEFAULT '',
+		elevate_to TEXT NOT NULL DEFAULT '',
+		valid_from TIMESTAMP,
+		valid_until TIMESTAMP,
+		conditions TEXT NOT NULL DEFAULT '',
+		UNIQUE(role_name, database_name),
+		FOREIGN KEY(role_name) REFERENCES roles(name) ON DELETE CASCADE,
+		FOREIGN KEY(database_name) REFERENCES databases(name) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS users (
+		username TEXT PRIMARY KEY,
+		password_hash BLOB NOT NULL,
+		attributes TEXT NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS admins (
+		username TEXT PRIMARY KEY,
+		password_hash BLOB NOT NULL,
+		name TEXT NOT NULL,
+		email TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_login_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS user_roles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		role_name TEXT NOT NULL,
+		valid_from TIMESTAMP,
+		valid_until TIMESTAMP,
+		UNIQUE(username, role_name),
+		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE,
+		FOREIGN KEY(role_name) REFERENCES roles(name) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS user_custom_permissions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		database_name TEXT NOT NULL,
+		level TEXT NOT NULL,
+		objects TEXT NOT NULL DEFAULT '[]',
+		deny INTEGER NOT NULL DEFAULT 0,
+		limits TEXT NOT NULL DEFAULT '',
+		elevate_to TEXT NOT NULL DEFAULT '',
+		valid_from TIMESTAMP,
+		valid_until TIMESTAMP,
+		conditions TEXT NOT NULL DEFAULT '',
+		UNIQUE(username, database_name, level),
+		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS groups (
+		name TEXT PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS group_parents (
+		group_name TEXT NOT NULL,
+		parent_name TEXT NOT NULL,
+		PRIMARY KEY(group_name, parent_name),
+		FOREIGN KEY(group_name) REFERENCES groups(name) ON DELETE CASCADE,
+		FOREIGN KEY(parent_name) REFERENCES groups(name) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS group_members (
+		group_name TEXT NOT NULL,
+		username TEXT NOT NULL,
+		PRIMARY KEY(group_name, username),
+		FOREIGN KEY(group_name) REFERENCES groups(name) ON DELETE CASCADE,
+		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS group_roles (
+		group_name TEXT NOT NULL,
+		role_name TEXT NOT NULL,
+		PRIMARY KEY(group_name, role_name),
+		FOREIGN KEY(group_name) REFERENCES groups(name) ON DELETE CASCADE,
+		FOREIGN KEY(role_name) REFERENCES roles(name) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		username TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		revoked BOOLEAN DEFAULT 0,
+		revoked_at TIMESTAMP,
+		user_agent TEXT,
+		ip_address TEXT,
+		FOREIGN KEY(username) REFERENCES users(username) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS elevations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		username TEXT NOT NULL,
+		database_name TEXT NOT NULL,
+		level TEXT NOT NULL,
+		justification TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS break_glass_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		username TEXT NOT NULL,
+		database_name TEXT NOT NULL,
+		level TEXT NOT NULL,
+		incident TEXT NOT NULL,
+		justification TEXT NOT NULL,
+		client_ip TEXT NOT NULL DEFAULT '',
+		started_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP,
+		reviewed_by TEXT NOT NULL DEFAULT '',
+		reviewed_at TIMESTAMP,
+		review_note TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS access_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		database_name TEXT NOT NULL,
+		level TEXT NOT NULL,
+		minutes INTEGER NOT NULL,
+		justification TEXT NOT NULL,
+		status TEXT NOT NULL,
+		requested_at TIMESTAMP NOT NULL,
+		decided_by TEXT NOT NULL DEFAULT '',
+		decided_at TIMESTAMP,
+		decision_note TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMP,
+		ended_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS policies (
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		expression TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		deleted INTEGER NOT NULL DEFAULT 0,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY(name, version)
+	);
+
+	CREATE TABLE IF NOT EXISTS sod_rules (
+		name TEXT PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		roles TEXT NOT NULL DEFAULT '[]',
+		permissions TEXT NOT NULL DEFAULT '[]',
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS connections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		username TEXT NOT NULL,
+		database_name TEXT NOT NULL,
+		level TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		ticket TEXT NOT NULL DEFAULT '',
+		client_ip TEXT NOT NULL DEFAULT '',
+		temp_username TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP,
+		statements_note TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS connection_statements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		connection_id INTEGER NOT NULL,
+		statement TEXT NOT NULL,
+		executed_at TIMESTAMP NOT NULL,
+		FOREIGN KEY(connection_id) REFERENCES connections(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_group_members_username ON group_members(username);
+	CREATE INDEX IF NOT EXISTS idx_access_requests_username ON access_requests(username);
+	CREATE INDEX IF NOT EXISTS idx_access_requests_status ON access_requests(status);
+	CREATE INDEX IF NOT EXISTS idx_connections_database ON connections(database_name, started_at);
+	CREATE INDEX IF NOT EXISTS idx_connection_statements_connection ON connection_statements(connection_id);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_username ON refresh_tokens(username);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+	`
+
+	if _, err := s.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed applying schema: %w", err)
+	}
+
+	return s.migrateColumns()
+}
+
+// columnMigrations lists columns added after a table was first released.
+// CREATE TABLE IF NOT EXISTS does not touch existing tables, so these are added on startup.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"databases", "target_database", "TEXT NOT NULL DEFAULT ''"},
+	{"databases", "target_schema", "TEXT NOT NULL DEFAULT ''"},
+	{"role_permissions", "objects", "TEXT NOT NULL DEFAULT '[]'"},
+	{"user_custom_permissions", "objects", "TEXT NOT NULL DEFAULT '[]'"},
+	{"role_permissions", "deny", "INTEGER NOT NULL DEFAULT 0"},
+	{"user_custom_permissions", "deny", "INTEGER NOT NULL DEFAULT 0"},
+	{"databases", "username_template", "TEXT NOT NULL DEFAULT ''"},
+	{"databases", "max_session_minutes", "INTEGER NOT NULL DEFAULT 0"},
+	{"permission_levels", "limits", "TEXT NOT NULL DEFAULT ''"},
+	{"role_permissions", "limits", "TEXT NOT NULL DEFAULT ''"},
+	{"user_custom_permissions", "limits", "TEXT NOT NULL DEFAULT ''"},
+	{"role_permissions", "elevate_to", "TEXT NOT NULL DEFAULT ''"},
+	{"user_custom_permissions", "elevate_to", "TEXT NOT NULL DEFAULT ''"},
+	{"role_permissions", "valid_from", "TIMESTAMP"},
+	{"role_permissions", "valid_until", "TIMESTAMP"},
+	{"user_custom_permissions", "valid_from", "TIMESTAMP"},
+	{"user_custom_permissions", "valid_until", "TIMESTAMP"},
+	{"user_roles", "valid_from", "TIMESTAMP"},
+	{"user_roles", "valid_until", "TIMESTAMP"},
+	{"role_permissions", "conditions", "TEXT NOT NULL DEFAULT ''"},
+	{"user_custom_permissions", "conditions", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "attributes", "TEXT NOT NULL DEFAULT '{}'"},
+	{"databases", "tags", "TEXT NOT NULL DEFAULT '{}'"},
+	{"databases", "owners", "TEXT NOT NULL DEFAULT '{}'"},
+	{"databases", "identity_trigger", "INTEGER NOT NULL DEFAULT 0"},
+	{"connections", "statements_note", "TEXT NOT NULL DEFAULT ''"},
+}
+
+func (s *Store) migrateColumns() error {
+	for _, m := range columnMigrations {
+		exists, err := s.columnExists(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("failed adding column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) columnExists(table, column string) (bool, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, fmt.Errorf("failed reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("failed scanning columns of %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+// newTestStore returns a store backed by an in-memory SQLite database of the test's own
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_busy_timeout=5000&_foreign_keys=on", url.PathEscape(t.Name()))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	s := &Store{db: db, encryptionKey: make([]byte, 32)}
+	if err := s.initSchema(); err != nil {
+		_ = db.Close()
+		t.Fatalf("init schema: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// saveTestDatabase stores a mysql database offering levels
+func saveTestDatabase(t *testing.T, s *Store, name string, levels ...string) {
+	t.Helper()
+	db := &Database{
+		Name:                 name,
+		Type:                 "mysql",
+		BackendAddr:          "db.internal:3306",
+		AdminUsername:        "zgate",
+		AdminPassword:        "secret",
+		AvailablePermissions: levels,
+		TargetDatabase:       name,
+	}
+	if err := s.SaveDatabase(db); err != nil {
+		t.Fatalf("SaveDatabase(%s): %v", name, err)
+	}
+}
+
+// saveTestRole stores a role, failing the test on error
+func saveTestRole(t *testing.T, s *Store, role Role) {
+	t.Helper()
+	if err := s.SaveRole(&role); err != nil {
+		t.Fatalf("SaveRole(%s): %v", role.Name, err)
+	}
+}
+
+// saveTestUser creates a user holding roles, failing the test on error
+func saveTestUser(t *testing.T, s *Store, username string, roles ...string) {
+	t.Helper()
+	if err := s.CreateUserWithPassword(&User{Username: username, Roles: roles}, "secret"); err != nil {
+		t.Fatalf("CreateUserWithPassword(%s): %v", username, err)
+	}
+}
+
+// saveTestGroup stores a group, failing the test on error
+func saveTestGroup(t *testing.T, s *Store, group Group) {
+	t.Helper()
+	if err := s.SaveGroup(&group); err != nil {
+		t.Fatalf("SaveGroup(%s): %v", group.Name, err)
+	}
+}
+
+// getTestUser fetches a user, failing the test on error
+func getTestUser(t *testing.T, s *Store, username string) *User {
+	t.Helper()
+	user, err := s.GetUser(username)
+	if err != nil {
+		t.Fatalf("GetUser(%s): %v", username, err)
+	}
+	return user
+}
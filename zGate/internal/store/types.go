@@ -1,52 +1,393 @@
-package store
-
-import "time"
-
-// Permission mirrors the YAML permission structure.
-type Permission struct {
-	Database string `json:"database"`
-	Level    string `json:"level"`
-}
-
-// Database represents a backend database definition.
-type Database struct {
-	Name                 string    `json:"name"`
-	Type                 string    `json:"type"`
-	Description          string    `json:"description"`
-	BackendAddr          string    `json:"backend_addr"`
-	AdminUsername        string    `json:"admin_username"`
-	AdminPassword        string    `json:"admin_password"`
-	AvailablePermissions []string  `json:"available_permissions"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
-}
-
-// Role contains description and permissions.
-type Role struct {
-	Name        string       `json:"name"`
-	Description string       `json:"description"`
-	Permissions []Permission `json:"permissions"`
-}
-
-// User captures the credentials and role bindings.
-type User struct {
-	Username          string       `json:"username"`
-	PasswordHash      string       `json:"-"`
-	Roles             []string     `json:"roles"`
-	CustomPermissions []Permission `json:"custom_permissions"`
-	CreatedAt         time.Time    `json:"created_at"`
-}
-
-// RefreshToken represents a stored refresh token for session management.
-type RefreshToken struct {
-	ID         int64      `json:"id"`
-	TokenHash  string     `json:"-"`
-	Username   string     `json:"username"`
-	ExpiresAt  time.Time  `json:"expires_at"`
-	CreatedAt  time.Time  `json:"created_at"`
-	LastUsedAt time.Time  `json:"last_used_at"`
-	Revoked    bool       `json:"revoked"`
-	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
-	UserAgent  string     `json:"user_agent,omitempty"`
-	IPAddress  string     `json:"ip_address,omitempty"`
-}
+package store
+
+import (
+	"slices"
+	"time"
+)
+
+// Permission mirrors the YAML permission structure.
+// When Objects is empty the level applies to the whole target database;
+// otherwise only the listed object grants are issued under that level.
+// A Deny entry blocks the database regardless of any other grant; its Level is ignored.
+type Permission struct {
+	Database string        `json:"database"`
+	Level    string        `json:"level"`
+	Objects  []ObjectGrant `json:"objects,omitempty"`
+	Deny     bool          `json:"deny,omitempty"`
+	// Limits caps what the temp principal for this grant may consume
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	// ElevateTo is a higher level the holder may temporarily elevate a live session to.
+	// An elevation grants the whole level, even when this grant is narrowed to objects.
+	ElevateTo string `json:"elevate_to,omitempty"`
+	// Conditions restrict when and from where the grant applies
+	Conditions *Conditions `json:"conditions,omitempty"`
+	Validity
+}
+
+// Conditions restrict a grant to a time of day, weekdays and client networks.
+// Unset fields impose no restriction; all set fields must hold.
+type Conditions struct {
+	Timezone    string   `json:"timezone,omitempty"`     // IANA zone for weekdays and times; UTC when empty
+	Weekdays    []string `json:"weekdays,omitempty"`     // "mon" ... "sun"
+	StartTime   string   `json:"start_time,omitempty"`   // "HH:MM", inclusive
+	EndTime     string   `json:"end_time,omitempty"`     // "HH:MM", exclusive; before StartTime wraps past midnight
+	SourceCIDRs []string `json:"source_cidrs,omitempty"` // client address must fall in one of these
+}
+
+// Validity limits when a grant or role assignment applies. A nil bound is open.
+type Validity struct {
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"` // exclusive
+}
+
+// ActiveAt reports whether t falls inside the window.
+func (v Validity) ActiveAt(t time.Time) bool {
+	if v.ValidFrom != nil && t.Before(*v.ValidFrom) {
+		return false
+	}
+	if v.ValidUntil != nil && !t.Before(*v.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// Intersect returns the window during which both v and other apply.
+func (v Validity) Intersect(other Validity) Validity {
+	out := v
+	if other.ValidFrom != nil && (out.ValidFrom == nil || other.ValidFrom.After(*out.ValidFrom)) {
+		out.ValidFrom = other.ValidFrom
+	}
+	if other.ValidUntil != nil && (out.ValidUntil == nil || other.ValidUntil.Before(*out.ValidUntil)) {
+		out.ValidUntil = other.ValidUntil
+	}
+	return out
+}
+
+// utc returns the window with both bounds in UTC, so stored bounds compare as text.
+func (v Validity) utc() Validity {
+	out := Validity{}
+	if v.ValidFrom != nil {
+		t := v.ValidFrom.UTC()
+		out.ValidFrom = &t
+	}
+	if v.ValidUntil != nil {
+		t := v.ValidUntil.UTC()
+		out.ValidUntil = &t
+	}
+	return out
+}
+
+// ResourceLimits caps what a temp principal may consume on the backend.
+// Zero means no limit for that resource.
+type ResourceLimits struct {
+	MaxConnections      int `json:"max_connections,omitempty"`
+	MaxQueriesPerHour   int `json:"max_queries_per_hour,omitempty"`
+	MaxExecutionSeconds int `json:"max_execution_seconds,omitempty"`
+}
+
+// StricterLimits combines two sets of limits, keeping the lower non-zero value for each resource.
+// It returns nil when neither sets a limit.
+func StricterLimits(a, b *ResourceLimits) *ResourceLimits {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	stricter := func(x, y int) int {
+		if x == 0 || (y != 0 && y < x) {
+			return y
+		}
+		return x
+	}
+	return &ResourceLimits{
+		MaxConnections:      stricter(a.MaxConnections, b.MaxConnections),
+		MaxQueriesPerHour:   stricter(a.MaxQueriesPerHour, b.MaxQueriesPerHour),
+		MaxExecutionSeconds: stricter(a.MaxExecutionSeconds, b.MaxExecutionSeconds),
+	}
+}
+
+// ObjectGrant grants privileges on a schema, a table, or specific columns of a table.
+// An empty Schema falls back to the database's target schema/database.
+type ObjectGrant struct {
+	Schema     string   `json:"schema,omitempty"`
+	Table      string   `json:"table,omitempty"`
+	Columns    []string `json:"columns,omitempty"`
+	Privileges []string `json:"privileges"`
+}
+
+// Database represents a backend database definition.
+type Database struct {
+	Name                 string            `json:"name"`
+	Type                 string            `json:"type"`
+	Description          string            `json:"description"`
+	BackendAddr          string            `json:"backend_addr"`
+	AdminUsername        string            `json:"admin_username"`
+	AdminPassword        string            `json:"admin_password"`
+	AvailablePermissions []string          `json:"available_permissions"`
+	TargetDatabase       string            `json:"target_database"`     // database/catalog temp users are created in and scoped to
+	TargetSchema         string            `json:"target_schema"`       // optional schema grants are scoped to (MSSQL only)
+	UsernameTemplate     string            `json:"username_template"`   // temp principal naming template; empty uses the default
+	MaxSessionMinutes    int               `json:"max_session_minutes"` // session lifetime; 0 uses the default
+	Tags                 map[string]string `json:"tags,omitempty"`      // free-form labels policies can match on
+	Owners               DatabaseOwners    `json:"owners"`              // users and groups who approve and audit access
+	IdentityTrigger      bool              `json:"identity_trigger"`    // MSSQL only: opt in to zGate's server-wide logon trigger
+	Levels               []PermissionLevel `json:"levels,omitempty"`    // admin-defined levels, loaded with the database
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+}
+
+// DatabaseOwners are the users and groups who approve access requests for a
+// database, audit its connections and manage the role permissions on it.
+type DatabaseOwners struct {
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+	// Webhooks receive a JSON POST for every new grant on the database
+	Webhooks []string `json:"webhooks,omitempty"`
+}
+
+// Includes reports whether username, a member of groups, owns the database.
+func (o DatabaseOwners) Includes(username string, groups []string) bool {
+	return slices.Contains(o.Users, username) ||
+		slices.ContainsFunc(o.Groups, func(group string) bool { return slices.Contains(groups, group) })
+}
+
+// Level returns the custom definition for a permission level, if the database has one.
+func (d *Database) Level(name string) (*PermissionLevel, bool) {
+	for i := range d.Levels {
+		if d.Levels[i].Name == name {
+			return &d.Levels[i], true
+		}
+	}
+	return nil, false
+}
+
+// Rank returns how privileged a level is on the database: its position in
+// AvailablePermissions, which lists levels from least to most privileged, or -1
+// when the level is not available.
+func (d *Database) Rank(level string) int {
+	return slices.Index(d.AvailablePermissions, level)
+}
+
+// HighestLevel returns the most privileged available level, or "" when none is.
+func (d *Database) HighestLevel() string {
+	if len(d.AvailablePermissions) == 0 {
+		return ""
+	}
+	return d.AvailablePermissions[len(d.AvailablePermissions)-1]
+}
+
+// PermissionLevel is an admin-defined permission level for one database.
+// Applying it runs, in order: its object grants, its native role memberships,
+// then its vendor SQL statements. Statements may use the placeholders
+// {{user}}, {{database}} and {{schema}}, which are replaced with quoted identifiers.
+// {{user}} is the level's native role, or the temp user when a permission narrows the level to objects.
+type PermissionLevel struct {
+	Database    string        `json:"database"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Statements  []string      `json:"statements,omitempty"`
+	NativeRoles []string      `json:"native_roles,omitempty"`
+	Objects     []ObjectGrant `json:"objects,omitempty"`
+	// Limits applies to every temp principal granted this level, on top of the grant's own limits
+	Limits    *ResourceLimits `json:"limits,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Role contains description and permissions.
+// A role inherits every permission of its parents, and of their parents in turn.
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Parents     []string     `json:"parents,omitempty"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Group assigns roles to every member at once. A group may belong to parent
+// groups: its members are then members of the parents too, and hold their roles.
+type Group struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Parents     []string  `json:"parents"`
+	Members     []string  `json:"members"`
+	Roles       []string  `json:"roles"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// User captures the credentials and role bindings.
+type User struct {
+	Username          string       `json:"username"`
+	PasswordHash      string       `json:"-"`
+	Roles             []string     `json:"roles"`
+	CustomPermissions []Permission `json:"custom_permissions"`
+	// RoleValidity limits when role assignments apply, keyed by role; roles not listed always apply
+	RoleValidity map[string]Validity `json:"role_validity,omitempty"`
+	// Attributes are free-form user properties, such as team or clearance, that policies can match on
+	Attributes map[string]string `json:"attributes,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// RefreshToken represents a stored refresh token for session management.
+type RefreshToken struct {
+	ID         int64      `json:"id"`
+	TokenHash  string     `json:"-"`
+	Username   string     `json:"username"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	Revoked    bool       `json:"revoked"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+}
+
+// Elevation records a time-bounded elevation of a live proxy session to a higher level.
+type Elevation struct {
+	ID            int64      `json:"id"`
+	SessionID     string     `json:"session_id"`
+	Username      string     `json:"username"`
+	Database      string     `json:"database"`
+	Level         string     `json:"level"`
+	Justification string     `json:"justification"`
+	StartedAt     time.Time  `json:"started_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+}
+
+// BreakGlassSession records an emergency session opened without a grant.
+// Every one stays unreviewed until an admin signs it off after the incident.
+type BreakGlassSession struct {
+	ID            int64      `json:"id"`
+	SessionID     string     `json:"session_id"`
+	Username      string     `json:"username"`
+	Database      string     `json:"database"`
+	Level         string     `json:"level"`
+	Incident      string     `json:"incident"`
+	Justification string     `json:"justification"`
+	ClientIP      string     `json:"client_ip,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	ReviewedBy    string     `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote    string     `json:"review_note,omitempty"`
+}
+
+// Connection records one proxy session: who connected to which database, at
+// what level and as which temp principal. SessionID and TempUsername are what
+// backend audit logs show for the statements run in it.
+type Connection struct {
+	ID           int64      `json:"id"`
+	SessionID    string     `json:"session_id"`
+	Username     string     `json:"username"`
+	Database     string     `json:"database"`
+	Level        string     `json:"level"`
+	Source       string     `json:"source"`
+	Ticket       string     `json:"ticket,omitempty"`
+	ClientIP     string     `json:"client_ip,omitempty"`
+	TempUsername string     `json:"temp_username"`
+	StartedAt    time.Time  `json:"started_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	// StatementsNote says why some of the session's statements were not recorded
+	StatementsNote string `json:"statements_note,omitempty"`
+}
+
+// Statement is one statement a client sent through a recorded proxy session.
+type Statement struct {
+	ID           int64     `json:"id"`
+	ConnectionID int64     `json:"connection_id"`
+	Statement    string    `json:"statement"`
+	ExecutedAt   time.Time `json:"executed_at"`
+}
+
+// ConnectionFilter narrows ListConnections; empty fields match everything.
+type ConnectionFilter struct {
+	Username string
+	Database string
+}
+
+// Policy is one version of a stored access policy: a CEL expression that must
+// evaluate to true for access that grants allow to take effect. Saving a policy
+// adds a version; the latest version is in force unless it records a deletion.
+type Policy struct {
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Expression  string    `json:"expression"`
+	Enabled     bool      `json:"enabled"`
+	Deleted     bool      `json:"deleted,omitempty"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SoDRule is a separation-of-duties rule: no user may hold two or more of its
+// roles and permissions at once, directly, through groups or through role inheritance.
+type SoDRule struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Roles       []string        `json:"roles"`
+	Permissions []SoDPermission `json:"permissions"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// SoDPermission matches allow entries on Database at Level; an empty Level matches any level.
+type SoDPermission struct {
+	Database string `json:"database"`
+	Level    string `json:"level,omitempty"`
+}
+
+// Matches reports whether grant, a database and level held by a user, falls under p.
+func (p SoDPermission) Matches(grant SoDPermission) bool {
+	return grant.Database == p.Database && (p.Level == "" || grant.Level == p.Level)
+}
+
+// String formats p as database/level, or just the database when any level matches.
+func (p SoDPermission) String() string {
+	if p.Level == "" {
+		return p.Database
+	}
+	return p.Database + "/" + p.Level
+}
+
+// SoDViolation reports a user holding conflicting entries of a rule.
+// Holds names them, e.g. "role:payments-audit" or "permission:payments-prod/write".
+type SoDViolation struct {
+	Rule     string   `json:"rule"`
+	Username string   `json:"username"`
+	Holds    []string `json:"holds"`
+}
+
+// Access request statuses.
+const (
+	AccessPending   = "pending"
+	AccessApproved  = "approved"
+	AccessDenied    = "denied"
+	AccessCancelled = "cancelled"
+	AccessRevoked   = "revoked"
+	AccessExpired   = "expired"
+)
+
+// AccessRequest is a user's request for temporary access to a database at a level.
+// Approval turns it into a grant that policy honors until ExpiresAt.
+type AccessRequest struct {
+	ID            int64      `json:"id"`
+	Username      string     `json:"username"`
+	Database      string     `json:"database"`
+	Level         string     `json:"level"`
+	Minutes       int        `json:"minutes"`
+	Justification string     `json:"justification"`
+	Status        string     `json:"status"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	DecidedBy     string     `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+	DecisionNote  string     `json:"decision_note,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // set on approval
+	EndedAt       *time.Time `json:"ended_at,omitempty"`   // when the request was cancelled or its grant revoked or expired
+}
+
+// AccessRequestFilter narrows ListAccessRequests; empty fields match everything.
+type AccessRequestFilter struct {
+	Username string
+	Database string
+	Status   string
+}
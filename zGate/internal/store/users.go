@@ -1,194 +1,260 @@
-package store
-
-import (
-	"context"
-	"fmt"
-)
-
-// SaveUser writes the user record (expects PasswordHash to be populated) and replaces roles/custom permissions.
-func (s *Store) SaveUser(user *User) error {
-	if user == nil {
-		return fmt.Errorf("user is nil")
-	}
-	if user.PasswordHash == "" {
-		return fmt.Errorf("user encrypted password is required")
-	}
-
-	tx, err := s.db.BeginTx(context.Background(), nil)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
-	if _, err = tx.Exec(`
-		INSERT INTO users (username, password_hash)
-		VALUES (?, ?)
-		ON CONFLICT(username) DO UPDATE SET password_hash=excluded.password_hash
-	`, user.Username, user.PasswordHash); err != nil {
-		return err
-	}
-
-	if _, err = tx.Exec(`DELETE FROM user_roles WHERE username = ?`, user.Username); err != nil {
-		return err
-	}
-	for _, role := range user.Roles {
-		if _, err = tx.Exec(`INSERT INTO user_roles (username, role_name) VALUES (?, ?)`, user.Username, role); err != nil {
-			return err
-		}
-	}
-
-	if _, err = tx.Exec(`DELETE FROM user_custom_permissions WHERE username = ?`, user.Username); err != nil {
-		return err
-	}
-	for _, perm := range user.CustomPermissions {
-		if _, err = tx.Exec(`INSERT INTO user_custom_permissions (username, database_name, level) VALUES (?, ?, ?)`, user.Username, perm.Database, perm.Level); err != nil {
-			return err
-		}
-	}
-
-	err = tx.Commit()
-	return err
-}
-
-// CreateUserWithPassword encrypts the password then delegates to SaveUser.
-func (s *Store) CreateUserWithPassword(username, plainPassword string, roles []string, custom []Permission) error {
-	encrypted, err := s.encrypt([]byte(plainPassword))
-	if err != nil {
-		return fmt.Errorf("encrypt password: %w", err)
-	}
-
-	user := &User{
-		Username:          username,
-		PasswordHash:      string(encrypted),
-		Roles:             roles,
-		CustomPermissions: custom,
-	}
-	return s.SaveUser(user)
-}
-
-// GetUser fetches the user with roles and custom permissions.
-func (s *Store) GetUser(username string) (*User, error) {
-	row := s.db.QueryRow(`SELECT username, password_hash, created_at FROM users WHERE username = ?`, username)
-	var user User
-	if err := row.Scan(&user.Username, &user.PasswordHash, &user.CreatedAt); err != nil {
-		return nil, fmt.Errorf("fetch user: %w", err)
-	}
-
-	roles, err := s.getUserRoles(username)
-	if err != nil {
-		return nil, err
-	}
-	user.Roles = roles
-
-	custom, err := s.getUserCustomPermissions(username)
-	if err != nil {
-		return nil, err
-	}
-	user.CustomPermissions = custom
-
-	return &user, nil
-}
-
-// ListUsers returns all users with their roles and custom permissions.
-func (s *Store) ListUsers() ([]User, error) {
-	rows, err := s.db.Query(`SELECT username, password_hash, created_at FROM users ORDER BY username`)
-	if err != nil {
-		return nil, fmt.Errorf("list users: %w", err)
-	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.Username, &user.PasswordHash, &user.CreatedAt); err != nil {
-			return nil, err
-		}
-
-		user.Roles, err = s.getUserRoles(user.Username)
-		if err != nil {
-			return nil, err
-		}
-
-		user.CustomPermissions, err = s.getUserCustomPermissions(user.Username)
-		if err != nil {
-			return nil, err
-		}
-
-		users = append(users, user)
-	}
-
-	return users, nil
-}
-
-// VerifyPassword decrypts and compares a user's password against the supplied password.
-func (s *Store) VerifyPassword(username, plainPassword string) error {
-	user, err := s.GetUser(username)
-	if err != nil {
-		return err
-	}
-
-	decrypted, err := s.decrypt([]byte(user.PasswordHash))
-	if err != nil {
-		return fmt.Errorf("decrypt password: %w", err)
-	}
-
-	if string(decrypted) != plainPassword {
-		return fmt.Errorf("invalid password")
-	}
-	return nil
-}
-
-// SetUserPassword updates an existing user's password.
-func (s *Store) SetUserPassword(username, plainPassword string) error {
-	encrypted, err := s.encrypt([]byte(plainPassword))
-	if err != nil {
-		return fmt.Errorf("encrypt password: %w", err)
-	}
-
-	if _, err := s.db.Exec(`UPDATE users SET password_hash = ? WHERE username = ?`, encrypted, username); err != nil {
-		return fmt.Errorf("update password: %w", err)
-	}
-	return nil
-}
-
-func (s *Store) getUserRoles(username string) ([]string, error) {
-	rows, err := s.db.Query(`SELECT role_name FROM user_roles WHERE username = ? ORDER BY role_name`, username)
-	if err != nil {
-		return nil, fmt.Errorf("user roles: %w", err)
-	}
-	defer rows.Close()
-
-	var roles []string
-	for rows.Next() {
-		var role string
-		if err := rows.Scan(&role); err != nil {
-			return nil, err
-		}
-		roles = append(roles, role)
-	}
-
-	return roles, nil
-}
-
-func (s *Store) getUserCustomPermissions(username string) ([]Permission, error) {
-	rows, err := s.db.Query(`SELECT database_name, level FROM user_custom_permissions WHERE username = ?`, username)
-	if err != nil {
-		return nil, fmt.Errorf("user custom perms: %w", err)
-	}
-	defer rows.Close()
-
-	var perms []Permission
-	for rows.Next() {
-		var perm Permission
-		if err := rows.Scan(&perm.Database, &perm.Level); err != nil {
-			return nil, err
-		}
-		perms = append(perms, perm)
-	}
-
-	return perms, nil
-}
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SaveUser writes the user record (expects PasswordHash to be populated) and replaces roles/custom permissions.
+// It fails with ErrSoDViolation, saving nothing, when the user would newly break a separation-of-duties rule.
+func (s *Store) SaveUser(user *User) error {
+	if user == nil {
+		return fmt.Errorf("user is nil")
+	}
+	if user.PasswordHash == "" {
+		return fmt.Errorf("user encrypted password is required")
+	}
+
+	attributes, err := encodeStringMap(user.Attributes)
+	if err != nil {
+		return fmt.Errorf("serialize attributes: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var baseline map[string][]string
+	if baseline, err = sodBaseline(tx, []string{user.Username}); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO users (username, password_hash, attributes)
+		VALUES (?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET password_hash=excluded.password_hash, attributes=excluded.attributes
+	`, user.Username, user.PasswordHash, attributes); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM user_roles WHERE username = ?`, user.Username); err != nil {
+		return err
+	}
+	for _, role := range user.Roles {
+		validity := user.RoleValidity[role].utc()
+		if _, err = tx.Exec(`INSERT INTO user_roles (username, role_name, valid_from, valid_until) VALUES (?, ?, ?, ?)`,
+			user.Username, role, validity.ValidFrom, validity.ValidUntil); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(`DELETE FROM user_custom_permissions WHERE username = ?`, user.Username); err != nil {
+		return err
+	}
+	for _, perm := range user.CustomPermissions {
+		var objects string
+		if objects, err = encodeObjects(perm.Objects); err != nil {
+			return err
+		}
+		var limits string
+		if limits, err = encodeLimits(perm.Limits); err != nil {
+			return err
+		}
+		var conditions string
+		if conditions, err = encodeConditions(perm.Conditions); err != nil {
+			return err
+		}
+		validity := perm.Validity.utc()
+		if _, err = tx.Exec(`INSERT INTO user_custom_permissions (username, database_name, level, objects, deny, limits, elevate_to, valid_from, valid_until, conditions) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			user.Username, perm.Database, perm.Level, objects, perm.Deny, limits, perm.ElevateTo, validity.ValidFrom, validity.ValidUntil, conditions); err != nil {
+			return err
+		}
+	}
+
+	if err = checkSoD(tx, []string{user.Username}, baseline); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	s.notify(Change{Kind: UserChanged, Name: user.Username})
+	return nil
+}
+
+// CreateUserWithPassword encrypts the password into user then delegates to SaveUser.
+func (s *Store) CreateUserWithPassword(user *User, plainPassword string) error {
+	return s.SaveUserWithPassword(user, plainPassword)
+}
+
+// SaveUserWithPassword saves user with a new password. The password is written in the
+// same transaction as the rest of the user, so a refused save leaves the old one in place.
+func (s *Store) SaveUserWithPassword(user *User, plainPassword string) error {
+	encrypted, err := s.encrypt([]byte(plainPassword))
+	if err != nil {
+		return fmt.Errorf("encrypt password: %w", err)
+	}
+
+	user.PasswordHash = string(encrypted)
+	return s.SaveUser(user)
+}
+
+// GetUser fetches the user with roles and custom permissions.
+func (s *Store) GetUser(username string) (*User, error) {
+	row := s.db.QueryRow(`SELECT username, password_hash, attributes, created_at FROM users WHERE username = ?`, username)
+	var user User
+	var attributes string
+	err := row.Scan(&user.Username, &user.PasswordHash, &attributes, &user.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("fetch user: %w", err)
+	}
+	if user.Attributes, err = decodeStringMap(attributes); err != nil {
+		return nil, fmt.Errorf("parse attributes: %w", err)
+	}
+
+	user.Roles, user.RoleValidity, err = s.getUserRoles(username)
+	if err != nil {
+		return nil, err
+	}
+
+	custom, err := s.getUserCustomPermissions(username)
+	if err != nil {
+		return nil, err
+	}
+	user.CustomPermissions = custom
+
+	return &user, nil
+}
+
+// ListUsers returns all users with their roles and custom permissions.
+func (s *Store) ListUsers() ([]User, error) {
+	rows, err := s.db.Query(`SELECT username, password_hash, attributes, created_at FROM users ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		var attributes string
+		if err := rows.Scan(&user.Username, &user.PasswordHash, &attributes, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		if user.Attributes, err = decodeStringMap(attributes); err != nil {
+			return nil, fmt.Errorf("parse attributes: %w", err)
+		}
+
+		user.Roles, user.RoleValidity, err = s.getUserRoles(user.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		user.CustomPermissions, err = s.getUserCustomPermissions(user.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// VerifyPassword decrypts and compares a user's password against the supplied password.
+func (s *Store) VerifyPassword(username, plainPassword string) error {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+
+	decrypted, err := s.decrypt([]byte(user.PasswordHash))
+	if err != nil {
+		return fmt.Errorf("decrypt password: %w", err)
+	}
+
+	if string(decrypted) != plainPassword {
+		return fmt.Errorf("invalid password")
+	}
+	return nil
+}
+
+// SetUserPassword updates an existing user's password.
+func (s *Store) SetUserPassword(username, plainPassword string) error {
+	encrypted, err := s.encrypt([]byte(plainPassword))
+	if err != nil {
+		return fmt.Errorf("encrypt password: %w", err)
+	}
+
+	if _, err := s.db.Exec(`UPDATE users SET password_hash = ? WHERE username = ?`, encrypted, username); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser removes a user; roles, custom permissions and refresh tokens cascade.
+func (s *Store) DeleteUser(username string) error {
+	result, err := s.db.Exec(`DELETE FROM users WHERE username = ?`, username)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("delete user: %w", sql.ErrNoRows)
+	}
+	s.notify(Change{Kind: UserChanged, Name: username})
+	return nil
+}
+
+// getUserRoles returns a user's roles and the validity windows of those that have one.
+func (s *Store) getUserRoles(username string) ([]string, map[string]Validity, error) {
+	rows, err := s.db.Query(`SELECT role_name, valid_from, valid_until FROM user_roles WHERE username = ? ORDER BY role_name`, username)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	var validity map[string]Validity
+	for rows.Next() {
+		var role string
+		var window Validity
+		if err := rows.Scan(&role, &window.ValidFrom, &window.ValidUntil); err != nil {
+			return nil, nil, err
+		}
+		roles = append(roles, role)
+		if window != (Validity{}) {
+			if validity == nil {
+				validity = make(map[string]Validity)
+			}
+			validity[role] = window
+		}
+	}
+
+	return roles, validity, nil
+}
+
+func (s *Store) getUserCustomPermissions(username string) ([]Permission, error) {
+	rows, err := s.db.Query(`SELECT `+permissionColumns+` FROM user_custom_permissions WHERE username = ?`, username)
+	if err != nil {
+		return nil, fmt.Errorf("user custom perms: %w", err)
+	}
+	defer rows.Close()
+
+	var perms []Permission
+	for rows.Next() {
+		perm, err := scanPermission(rows)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, perm)
+	}
+
+	return perms, nil
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSaveUserWithPassword(t *testing.T) {
+	s := newSoDStore(t)
+	saveTestUser(t, s, "alice", "requester")
+
+	alice := getTestUser(t, s, "alice")
+	alice.Attributes = map[string]string{"team": "payments"}
+	if err := s.SaveUserWithPassword(alice, "n3w"); err != nil {
+		t.Fatalf("SaveUserWithPassword: %v", err)
+	}
+	if err := s.VerifyPassword("alice", "n3w"); err != nil {
+		t.Fatalf("new password refused: %v", err)
+	}
+	if team := getTestUser(t, s, "alice").Attributes["team"]; team != "payments" {
+		t.Fatalf("team = %q; want payments", team)
+	}
+
+	// A refused save keeps the old password as well as the old roles
+	alice = getTestUser(t, s, "alice")
+	alice.Roles = []string{"requester", "approver"}
+	if err := s.SaveUserWithPassword(alice, "other"); !errors.Is(err, ErrSoDViolation) {
+		t.Fatalf("SaveUserWithPassword breaking a rule = %v; want ErrSoDViolation", err)
+	}
+	if err := s.VerifyPassword("alice", "n3w"); err != nil {
+		t.Fatalf("password changed by a refused save: %v", err)
+	}
+	if roles := getTestUser(t, s, "alice").Roles; len(roles) != 1 {
+		t.Fatalf("roles changed by a refused save: %q", roles)
+	}
+}